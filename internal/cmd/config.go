@@ -0,0 +1,310 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc/v2"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/n3tuk/dashboard/internal/config"
+	"github.com/n3tuk/dashboard/internal/logger"
+)
+
+var (
+	// configNames maps the commands which can be configured to the default name
+	// of their configuration file.
+	configNames = map[string]string{
+		"send":  sendConfigName,
+		"serve": serveConfigName,
+	}
+	// configCommands is the sorted list of the commands which can be configured.
+	configCommands = []string{"send", "serve"}
+	// outputFormats is the list of formats supported for the output.
+	outputFormats = []string{"yaml", "json"}
+
+	// errInvalidConfig is returned when one or more of the configuration files
+	// checked by `config validate` are not valid.
+	errInvalidConfig = errors.New("the configuration is not valid")
+	// errUnknownCommand is returned when the command a configuration file is
+	// for cannot be found.
+	errUnknownCommand = errors.New("unable to find the command for the configuration file")
+	// errUnknownFormat is returned when the output format is not supported.
+	errUnknownFormat = errors.New("unknown output format")
+
+	// configCmd represents the config command for the dashboard application,
+	// which groups together the commands for working with the configuration.
+	configCmd = &cobra.Command{
+		Use:   "config command [options]",
+		Short: "Inspect, validate, and create configuration files",
+		Long: heredoc.Doc(`
+		  dashboard config provides the commands to show the effective
+		  configuration for the send and serve commands, to validate the
+		  configuration files, and to create new configuration files.
+	  `),
+	}
+
+	// configShowCmd represents the config show command, which shows the
+	// effective configuration for a command, and where each value came from.
+	configShowCmd = &cobra.Command{
+		Use:   "show send|serve [options]",
+		Short: "Show the effective configuration for the send or serve commands",
+		Long: heredoc.Doc(`
+		  dashboard config show loads the configuration for the send or serve
+		  command in the same way the command would, and then shows the value of
+		  each setting and its source (default, file, env, or flag), with the
+		  values of any secrets masked.
+	  `),
+
+		// Add blank line at the top for enforced extra spacing in the output
+		Example: strings.TrimRight(heredoc.Doc(`
+
+	    $ dashboard config show serve --output json
+	    $ DASHBOARD_LOGGING_LEVEL=debug dashboard config show send
+	  `), "\n"),
+
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: configCommands,
+		RunE:      runConfigShow,
+	}
+
+	// configValidateCmd represents the config validate command, which checks a
+	// configuration file against its schema without starting anything.
+	configValidateCmd = &cobra.Command{
+		Use:   "validate [file] [options]",
+		Short: "Validate the configuration files for the send or serve commands",
+		Long: heredoc.Doc(`
+		  dashboard config validate checks the configuration file against the
+		  schema for its command, reporting all the settings which are not valid.
+		  If no file is provided, the default configuration files for each of
+		  the commands found in the configuration paths are checked instead.
+	  `),
+
+		// Add blank line at the top for enforced extra spacing in the output
+		Example: strings.TrimRight(heredoc.Doc(`
+
+	    $ dashboard config validate
+	    $ dashboard config validate /etc/dashboard/serve.yaml
+	    $ dashboard config validate --command send ./config.yaml --output json
+	  `), "\n"),
+
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE:         runConfigValidate,
+	}
+
+	// configInitCmd represents the config init command, which writes a starter
+	// configuration file for a command.
+	configInitCmd = &cobra.Command{
+		Use:   "init send|serve [options]",
+		Short: "Create a starter configuration file for the send or serve commands",
+		Long: heredoc.Doc(`
+		  dashboard config init writes a commented starter configuration file
+		  (in YAML) for the send or serve command into the first of the
+		  configuration paths which can be written to.
+	  `),
+
+		// Add blank line at the top for enforced extra spacing in the output
+		Example: strings.TrimRight(heredoc.Doc(`
+
+	    $ dashboard config init serve
+	    $ dashboard config init send --force
+	  `), "\n"),
+
+		Args:         cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs:    configCommands,
+		SilenceUsage: true,
+		RunE:         runConfigInit,
+	}
+)
+
+// configReport is the output from `config show`.
+type configReport struct {
+	Command  string            `json:"command"        yaml:"command"`
+	File     string            `json:"file,omitempty" yaml:"file,omitempty"`
+	Settings []*config.Setting `json:"settings"       yaml:"settings"`
+}
+
+// validateReport is the output from `config validate` for a single file.
+type validateReport struct {
+	Command  string              `json:"command"            yaml:"command"`
+	File     string              `json:"file"               yaml:"file"`
+	Valid    bool                `json:"valid"              yaml:"valid"`
+	Errors   []*config.Violation `json:"errors,omitempty"   yaml:"errors,omitempty"`
+	Warnings []*config.Violation `json:"warnings,omitempty" yaml:"warnings,omitempty"`
+}
+
+// init will initialise the command-line settings for the `configCmd` command
+// and its subcommands, including any command-specific flags.
+func init() {
+	flags := configShowCmd.Flags()
+	flags.StringP("output", "o", "yaml", "The format of the output ("+strings.Join(outputFormats, ", ")+")")
+
+	flags = configValidateCmd.Flags()
+	flags.StringP("output", "o", "yaml", "The format of the output ("+strings.Join(outputFormats, ", ")+")")
+	flags.String("command", "", "The command the file is for ("+strings.Join(configCommands, ", ")+"), if not set by the file name")
+
+	flags = configInitCmd.Flags()
+	flags.Bool("force", false, "Replace the configuration file if it already exists")
+
+	configCmd.AddCommand(configShowCmd, configValidateCmd, configInitCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+// runConfigShow will run when the config show command is provided to the
+// command-line application, loading the configuration for the command and
+// showing the effective value and source of each setting.
+func runConfigShow(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("output")
+	name := configNames[args[0]]
+
+	err := config.Load(name, configFile)
+
+	var invalid *config.ValidationError
+	if err != nil && !errors.As(err, &invalid) {
+		//nolint:revive,stylecheck // new-line is required to break error and usage
+		return fmt.Errorf("\n  %w\n", err)
+	}
+
+	logger.Start(nil)
+	config.LogWarnings()
+
+	if invalid != nil {
+		// Still show the configuration, as it may help to find the problem
+		fmt.Fprintf(cmd.ErrOrStderr(), "%s\n\n", invalid)
+	}
+
+	settings, err := config.Settings(name)
+	if err != nil {
+		return err
+	}
+
+	return render(cmd.OutOrStdout(), format, &configReport{
+		Command:  args[0],
+		File:     viper.ConfigFileUsed(),
+		Settings: settings,
+	})
+}
+
+// runConfigValidate will run when the config validate command is provided to
+// the command-line application, checking either the given configuration file,
+// or the default files for each command, against the schema for the command.
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("output")
+	if !slices.Contains(outputFormats, format) {
+		return fmt.Errorf("%w: %s", errUnknownFormat, format)
+	}
+
+	file := configFile
+	if len(args) > 0 {
+		file = args[0]
+	}
+
+	reports := []*validateReport{}
+
+	if file != "" {
+		command, _ := cmd.Flags().GetString("command")
+		if command == "" {
+			command = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		}
+
+		if _, ok := configNames[command]; !ok {
+			return fmt.Errorf("%w: %s (set it with --command)", errUnknownCommand, file)
+		}
+
+		reports = append(reports, validateConfig(command, file))
+	} else {
+		for _, command := range configCommands {
+			report := validateConfig(command, "")
+			if report.File == "" {
+				// The default configuration file could not be found
+				continue
+			}
+
+			reports = append(reports, report)
+		}
+	}
+
+	if err := render(cmd.OutOrStdout(), format, reports); err != nil {
+		return err
+	}
+
+	for _, report := range reports {
+		if !report.Valid {
+			return errInvalidConfig
+		}
+	}
+
+	return nil
+}
+
+// validateConfig loads the configuration `file` for the `command`, or its
+// default file if not set, and reports whether or not it is valid.
+func validateConfig(command, file string) *validateReport {
+	err := config.Load(configNames[command], file)
+
+	report := &validateReport{
+		Command:  command,
+		File:     viper.ConfigFileUsed(),
+		Valid:    err == nil,
+		Warnings: config.Warnings(),
+	}
+
+	var invalid *config.ValidationError
+
+	switch {
+	case errors.As(err, &invalid):
+		report.Errors = invalid.Violations
+	case err != nil:
+		report.File = file
+		report.Errors = []*config.Violation{{Message: err.Error()}}
+	}
+
+	return report
+}
+
+// runConfigInit will run when the config init command is provided to the
+// command-line application, writing the starter configuration file for the
+// command into the first writable configuration path.
+func runConfigInit(cmd *cobra.Command, args []string) error {
+	force, _ := cmd.Flags().GetBool("force")
+
+	file, err := config.Init(configNames[args[0]], force)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Created the configuration file for %s at %s\n", args[0], file)
+
+	return nil
+}
+
+// render writes the `value` to `w` in the `format` requested, either as YAML
+// or as JSON.
+func render(w io.Writer, format string, value any) error {
+	switch format {
+	case "yaml":
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+
+		if err := encoder.Encode(value); err != nil {
+			return err
+		}
+
+		return encoder.Close()
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(value)
+	}
+
+	return fmt.Errorf("%w: %s", errUnknownFormat, format)
+}
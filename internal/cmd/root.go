@@ -8,6 +8,8 @@ import (
 	"github.com/MakeNowJust/heredoc/v2"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"github.com/n3tuk/dashboard/internal/config"
 )
 
 var (
@@ -47,16 +49,17 @@ var (
 func init() {
 	flags := rootCmd.PersistentFlags()
 	flags.StringVarP(&configFile, "config", "c", "", "Path to the configuration file")
+	flags.BoolVar(&config.Strict, "strict-config", false, "Treat unknown settings in the configuration file as errors")
 
 	// Provide configuration for the logger, including setting JSON, structured
 	// output, and the level of logging output by default
 	viper.SetDefault("logging.level", "info")
 	flags.StringP("log-level", "l", "info", "Set the logging level (debug, info, warning, error)")
-	_ = viper.BindPFlag("logging.level", flags.Lookup("log-level"))
+	config.BindFlag("logging.level", flags.Lookup("log-level"))
 
 	viper.SetDefault("logging.json", false)
 	flags.BoolP("log-json", "j", false, "Output logs in JSON format")
-	_ = viper.BindPFlag("logging.json", flags.Lookup("log-json"))
+	config.BindFlag("logging.json", flags.Lookup("log-json"))
 }
 
 // Execute executes `rootCmd` and therefore starts the application, with all the
@@ -2,13 +2,17 @@ package cmd
 
 import (
 	"fmt"
+	"log/slog"
+	"os"
 	"strings"
 
 	"github.com/MakeNowJust/heredoc/v2"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 
 	"github.com/n3tuk/dashboard/internal/config"
+	"github.com/n3tuk/dashboard/internal/event"
 	"github.com/n3tuk/dashboard/internal/logger"
 	"github.com/n3tuk/dashboard/internal/send"
 )
@@ -19,6 +23,22 @@ const (
 	sendConfigName = "send.yaml"
 )
 
+var (
+	// endpointURI is the default URI of the dashboard endpoint to send events to.
+	endpointURI = "http://localhost:8080"
+	// sendTimeout is the maximum time (in seconds) to wait for the dashboard
+	// endpoint to respond to the request.
+	sendTimeout = 10
+	// retryAttempts is the maximum number of times a request is made again
+	// after it fails in a way which could succeed later.
+	retryAttempts = 5
+	// retryDelay is the time (in seconds) to wait before the first retry, which
+	// doubles with each retry after that.
+	retryDelay = 1
+	// retryMaxDelay is the maximum time (in seconds) to wait between retries.
+	retryMaxDelay = 30
+)
+
 // sendCmd represents the send command for the dashboard application, and will
 // provide the setup and arguments needed for the application to build an
 // event and send it to the dashboard endpoint for processing.
@@ -29,6 +49,23 @@ var sendCmd = &cobra.Command{
 		dashboard send provides a mechanism to construct and send an event to the
 		web service using either an input file or command-line arguments to build
 		and/or override the events.
+
+		The input file (or - for stdin) can be JSON or YAML, holding a single
+		event, a list of events, or multiple YAML documents, with each event
+		checked against the event schema before any of them are sent. Multiple
+		events are sent together to the web service as a single batch.
+
+		Requests which fail while the web service is unavailable are retried with
+		an exponential backoff, using the same idempotency key so each update is
+		only recorded once. If a spool directory is set, events which still
+		cannot be delivered are saved to it, and sent later with --flush.
+
+		When run within a GitHub Actions workflow (or with --github), the ID,
+		group, and labels of the events are filled from the GITHUB_* environment
+		variables, unless set in the input file or by the command-line arguments.
+		Warnings and errors are also shown as annotations on the workflow run,
+		the URLs of the events are set as outputs of the step, and a summary of
+		the events sent is added to the summary of the step.
 	`),
 
 	// Add blank line at the top for enforced extra spacing in the output
@@ -37,25 +74,98 @@ var sendCmd = &cobra.Command{
 	  $ dashboard send \
 	      --endpoint-uri https://development.dashboard.n3t.uk \
 	      --event-id this-is-a-test-message \
+	      --group dashboard/development/web \
 	      --status pass \
 	      --message 'This is a test message for the dashboard'
+	  $ dashboard send --file events.yaml --label pipeline=build
+	  $ dashboard send --spool-dir /var/spool/dashboard --flush
+	  $ dashboard send --github --status pass --message 'Deployed to production'
 	`), "\n"),
 
 	RunE: runSend,
 }
 
+// overrides maps the flags for building the event to the fields in the event
+// which they set.
+var overrides = map[string]string{
+	"event-id":  "event-id",
+	"status":    "status",
+	"message":   "message",
+	"source":    "source",
+	"group":     "group",
+	"label":     "labels",
+	"reopen":    "reopen",
+	"heartbeat": "heartbeat",
+	"ttl":       "ttl",
+}
+
 // init will initialise the command-line settings for `sendCmd` command,
 // including any command-specific flags.
 func init() {
-	viper.SetDefault("endpoint-uri", "http://localhost:8080")
+	flags := sendCmd.Flags()
+
+	// Flags and default configuration for connecting to the dashboard endpoint
+	viper.SetDefault("endpoint-uri", endpointURI)
+	flags.StringP("endpoint-uri", "e", endpointURI, "The URI of the dashboard endpoint to send events to")
+	config.BindFlag("endpoint-uri", flags.Lookup("endpoint-uri"))
+
+	flags.StringP("api-key", "k", "", "The API key used to authenticate with the dashboard endpoint")
+	config.BindFlag("api-key", flags.Lookup("api-key"))
+
+	viper.SetDefault("sign", false)
+	flags.Bool("sign", false, "Sign the request with the API key rather than sending the key itself")
+	config.BindFlag("sign", flags.Lookup("sign"))
+
+	viper.SetDefault("timeout", sendTimeout)
+	flags.Int("timeout", sendTimeout, "Timeout (in seconds) to wait for the dashboard endpoint to respond")
+	config.BindFlag("timeout", flags.Lookup("timeout"))
+
+	// Flags and default configuration for retrying and spooling events which
+	// could not be delivered to the dashboard endpoint
+	viper.SetDefault("retry.attempts", retryAttempts)
+	flags.Int("retry-attempts", retryAttempts, "Maximum number of times to retry a failed request (0 to disable)")
+	config.BindFlag("retry.attempts", flags.Lookup("retry-attempts"))
+
+	viper.SetDefault("retry.delay", retryDelay)
+	flags.Int("retry-delay", retryDelay, "Time (in seconds) to wait before the first retry, doubling for each retry")
+	config.BindFlag("retry.delay", flags.Lookup("retry-delay"))
+
+	viper.SetDefault("retry.max-delay", retryMaxDelay)
+	flags.Int("retry-max-delay", retryMaxDelay, "Maximum time (in seconds) to wait between each retry")
+	config.BindFlag("retry.max-delay", flags.Lookup("retry-max-delay"))
+
+	flags.String("spool-dir", "", "Directory to save events which could not be delivered, to be sent with --flush")
+	config.BindFlag("spool.directory", flags.Lookup("spool-dir"))
+
+	flags.Bool("flush", false, "Send the events saved in the spool directory, in the order they were saved")
+
+	flags.Bool("github", false, "Build the events from, and report back to, GitHub Actions (set by default within workflows)")
+
+	// Flags for building the event to be sent, which are not part of the
+	// configuration as they are expected to change on every call
+	flags.StringP("file", "f", "", "Read the events to send from a JSON or YAML file (or - for stdin)")
+	flags.StringP("event-id", "i", "", "The unique ID of the event to send")
+	flags.StringP("status", "s", "", "The current status of the event")
+	flags.StringP("message", "m", "", "A message describing the current status of the event")
+	flags.String("source", "", "The name of the system sending the event")
+	flags.StringP("group", "g", "", "The group of the event, as a path (e.g. service/production/web)")
+	flags.StringToString("label", nil, "Labels to attach to the event (key=value, can be repeated)")
+	flags.Bool("reopen", false, "Allow the status of an event which has finished to change again")
+	flags.Int("heartbeat", 0, "Time (in seconds) within which the next update is expected before the event is stale")
+	flags.Int("ttl", 0, "Time (in seconds) after this update when the event is removed from the dashboard")
+
+	sendCmd.MarkFlagsMutuallyExclusive("flush", "file")
+	sendCmd.MarkFlagsMutuallyExclusive("flush", "event-id")
+
 	rootCmd.AddCommand(sendCmd)
 }
 
 // runSend will run when the send command is provided to the command-line
 // application, providing the building and sending of an event to the dashboard
-// endpoint. If there was an error processing the configuration or the event, an
-// `error` will be returned.
-func runSend(_ *cobra.Command, _ []string) error {
+// endpoint, or the sending of the events saved in the spool directory. If there
+// was an error processing the configuration or the event, an `error` will be
+// returned.
+func runSend(cmd *cobra.Command, _ []string) error {
 	err := config.Load(sendConfigName, configFile)
 	if err != nil {
 		//nolint:revive,stylecheck // new-line is required to break error and usage
@@ -63,6 +173,139 @@ func runSend(_ *cobra.Command, _ []string) error {
 	}
 
 	logger.Start(nil)
+	config.LogWarnings()
+
+	agent := send.UserAgent(Application, Version)
+
+	github, err := githubMode(cmd.Flags())
+	if err != nil {
+		return err
+	}
+
+	if github {
+		logger.StartGitHub(os.Stdout)
+	}
+
+	if flush, _ := cmd.Flags().GetBool("flush"); flush {
+		cmd.SilenceUsage = true
+
+		return report(github, send.Flush(agent))
+	}
+
+	events, err := buildEvents(cmd.Flags(), github)
+	if err != nil {
+		return report(github, err)
+	}
 
-	return send.Run()
+	// The usage is only useful when there is an error in the arguments, so once
+	// the events have been built, do not show it for errors from sending them
+	cmd.SilenceUsage = true
+
+	err = send.Run(agent, events...)
+
+	if github {
+		if outputErr := send.WriteGitHubOutputs(os.Getenv, events, err); outputErr != nil {
+			slog.Warn(
+				"Failed to write the outputs for GitHub Actions",
+				slog.Group("error", slog.String("message", outputErr.Error())),
+			)
+		}
+	}
+
+	return report(github, err)
+}
+
+// githubMode checks whether the events should be built from, and reported back
+// to, GitHub Actions, either as set by the --github flag, or, if not set, by
+// running within a GitHub Actions workflow.
+func githubMode(flags *pflag.FlagSet) (bool, error) {
+	if !flags.Changed("github") {
+		return send.GitHubActions(os.Getenv), nil
+	}
+
+	return flags.GetBool("github")
+}
+
+// report logs the `err`, if set, when running within GitHub Actions, so that it
+// is shown as an annotation on the workflow run, and then returns it.
+func report(github bool, err error) error {
+	if github && err != nil {
+		slog.Error(
+			"Failed to send dashboard events",
+			slog.Group("error", slog.String("message", err.Error())),
+		)
+	}
+
+	return err
+}
+
+// buildEvents constructs the events to be sent from the input file, if set,
+// with the command-line flags overriding the fields from the file, or only
+// from the command-line flags if not. When `github` is set, the fields from
+// the GitHub Actions environment are used for any not otherwise set.
+func buildEvents(flags *pflag.FlagSet, github bool) ([]*event.Event, error) {
+	file, err := flags.GetString("file")
+	if err != nil {
+		return nil, err
+	}
+
+	documents := []map[string]any{{}}
+	if file != "" {
+		if documents, err = send.ReadFile(file, os.Stdin); err != nil {
+			return nil, err
+		}
+	}
+
+	if github {
+		defaults := send.GitHubFields(os.Getenv)
+		for i, document := range documents {
+			documents[i] = send.Merge(defaults, document)
+		}
+	}
+
+	fields := map[string]any{}
+
+	for name, field := range overrides {
+		flag := flags.Lookup(name)
+
+		// Without an input file, all the flags are used to build the event, but
+		// otherwise only those which were set override the fields in the file,
+		// or those from GitHub Actions
+		if (file != "" || github) && !flag.Changed {
+			continue
+		}
+
+		value, err := flagValue(flags, name, flag.Value.Type())
+		if err != nil {
+			return nil, err
+		}
+
+		fields[field] = value
+	}
+
+	return send.Decode(documents, fields, file)
+}
+
+// flagValue returns the value of the flag `name` with the `kind` of value.
+func flagValue(flags *pflag.FlagSet, name, kind string) (any, error) {
+	switch kind {
+	case "bool":
+		return flags.GetBool(name)
+	case "int":
+		return flags.GetInt(name)
+	case "stringToString":
+		labels, err := flags.GetStringToString(name)
+		if err != nil {
+			return nil, err
+		}
+
+		values := make(map[string]any, len(labels))
+		for key, value := range labels {
+			values[key] = value
+		}
+
+		return values, nil
+	default:
+		return flags.GetString(name)
+	}
 }
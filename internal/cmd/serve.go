@@ -15,9 +15,19 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/n3tuk/dashboard/internal/config"
+	"github.com/n3tuk/dashboard/internal/event"
 	"github.com/n3tuk/dashboard/internal/logger"
+	"github.com/n3tuk/dashboard/internal/serve/broker"
+	"github.com/n3tuk/dashboard/internal/serve/hub"
 	"github.com/n3tuk/dashboard/internal/serve/metrics"
+	"github.com/n3tuk/dashboard/internal/serve/middleware"
+	"github.com/n3tuk/dashboard/internal/serve/reaper"
+	"github.com/n3tuk/dashboard/internal/serve/reload"
 	"github.com/n3tuk/dashboard/internal/serve/web"
+	"github.com/n3tuk/dashboard/internal/serve/web/groups"
+	"github.com/n3tuk/dashboard/internal/store"
+
+	_ "github.com/n3tuk/dashboard/internal/store/dynamo"
 )
 
 const (
@@ -63,6 +73,64 @@ var (
 	// closed.
 	shutdownMetrics = 5
 
+	// eventsRate is the number of events each client can submit every second,
+	// once the burst of events has been used.
+	eventsRate = 10
+	// eventsBurst is the number of events each client can submit at once.
+	eventsBurst = 50
+
+	// idempotencyWindow is the time (in seconds) the responses to events are
+	// kept, so that repeated submissions are given the original response.
+	idempotencyWindow = 60 * 60
+
+	// batchMaxEvents is the maximum number of events which can be submitted in
+	// a single batch.
+	batchMaxEvents = 500
+	// batchMaxBytes is the maximum size (in bytes) of the body of a single batch
+	// of events.
+	batchMaxBytes = 1 << 20
+
+	// tlsMinVersion is the minimum version of TLS accepted by the web and
+	// metrics services, when TLS is enabled.
+	tlsMinVersion = "1.2"
+
+	// storeDriver is the name of the driver used to store events.
+	storeDriver = store.MemoryDriver
+	// storeInterval is the time (in seconds) between checks on the readiness of
+	// the event store.
+	storeInterval = 10
+	// groupsRollup is the rule used to roll up the status of each group from the
+	// statuses of its events.
+	groupsRollup = event.RollupWorstOf
+
+	// expiryInterval is the time (in seconds) between checks on the events for
+	// those which have missed their heartbeat or expired.
+	expiryInterval = 30
+	// expiryRetention is the time (in seconds) stale events are kept before
+	// being removed, or zero to keep them.
+	expiryRetention = 24 * 60 * 60
+
+	// streamHeartbeat is the time (in seconds) between the heart-beats sent to
+	// clients connected to the live event stream to keep the connection open.
+	streamHeartbeat = 15
+
+	// anonymousScopes is the list of scopes given to requests which do not
+	// provide an API key, allowing the dashboard to be viewed by default.
+	anonymousScopes = []string{middleware.ScopeEventsRead}
+	// signingSkew is the maximum difference (in seconds) allowed between the
+	// time a request was signed and the time it was received.
+	signingSkew = 300
+
+	// brokerAddress is the address of the message broker used to share events
+	// between dashboard instances in the cluster.
+	brokerAddress = "localhost:61616"
+
+	// dynamodbTable is the name of the DynamoDB table used to store events.
+	dynamodbTable = "dashboard"
+	// dynamodbTTL is the time (in seconds) after which events and their history
+	// will be expired from the DynamoDB table if they have not been updated.
+	dynamodbTTL = 30 * 24 * 60 * 60
+
 	// serveCmd represents the serve command for the dashboard application, and will
 	// provide the setup and arguments needed for the application to start the web
 	// service and start processing events.
@@ -73,12 +141,23 @@ var (
 		  dashboard serve provides the web service which runs the processing of
 		  events submitted to the dashboard, to be saved and pushed out to the
 		  clients.
+
+		  The configuration file is reloaded whenever it changes, or the SIGHUP
+		  signal is received, which will update the logging, trusted proxies, rate
+		  limits, and authentication settings, but all other settings need a
+		  restart.
+
+		  When started through systemd socket activation, the listeners passed
+		  by systemd are used for the web and metrics services, either by their
+		  names (web or metrics) or in that order, instead of binding to the
+		  configured addresses.
 	  `),
 
 		// Add blank line at the top for enforced extra spacing in the output
 		Example: strings.TrimRight(heredoc.Doc(`
 
 	    $ dashboard serve --address 0.0.0.0 --web-port 8080 --metrics-port 8081
+	    $ dashboard serve --address unix:/run/dashboard/web.sock --metrics-address 0.0.0.0
 	  `), "\n"),
 
 		RunE: runServe,
@@ -92,49 +171,125 @@ func init() {
 
 	// Flags and default configuration for binding the web service
 	viper.SetDefault("endpoints.bind.address", host)
-	flags.StringP("address", "a", host, "Address to bind the server to")
-	_ = viper.BindPFlag("endpoints.bind.address", flags.Lookup("address"))
+	flags.StringP("address", "a", host, "Address to bind the server to (or unix:/path for a Unix domain socket)")
+	config.BindFlag("endpoints.bind.address", flags.Lookup("address"))
+
+	flags.String("metrics-address", "", "Address to bind the metrics service to, if different from --address (localhost if that is a socket)")
+	config.BindFlag("endpoints.bind.metrics-address", flags.Lookup("metrics-address"))
 
 	viper.SetDefault("endpoints.bind.port.web", webPort)
 	flags.IntP("web-port", "p", webPort, "The port to bind the web service to")
-	_ = viper.BindPFlag("endpoints.bind.port.web", flags.Lookup("web-port"))
+	config.BindFlag("endpoints.bind.port.web", flags.Lookup("web-port"))
 
 	viper.SetDefault("endpoints.bind.port.metrics", metricsPort)
 	flags.IntP("metrics-port", "m", metricsPort, "The port to bind the metrics service to")
-	_ = viper.BindPFlag("endpoints.bind.port.metrics", flags.Lookup("metrics-port"))
+	config.BindFlag("endpoints.bind.port.metrics", flags.Lookup("metrics-port"))
 
 	viper.SetDefault("endpoints.proxies", trustedProxies)
 	flags.StringSlice("proxies", trustedProxies, "A comma-separated list of CIDRs where trusted proxies are used")
-	_ = viper.BindPFlag("endpoints.proxies", flags.Lookup("proxies"))
+	config.BindFlag("endpoints.proxies", flags.Lookup("proxies"))
+
+	// Default configuration for the rate limits on each group of routes
+	viper.SetDefault("endpoints.rate-limits.events.rate", eventsRate)
+	viper.SetDefault("endpoints.rate-limits.events.burst", eventsBurst)
+
+	viper.SetDefault("endpoints.idempotency.window", idempotencyWindow)
+	flags.Int("idempotency-window", idempotencyWindow, "Time (in seconds) to replay the response to repeated events (0 to disable)")
+	config.BindFlag("endpoints.idempotency.window", flags.Lookup("idempotency-window"))
+
+	viper.SetDefault("endpoints.batch.max-events", batchMaxEvents)
+	flags.Int("batch-max-events", batchMaxEvents, "Maximum number of events in a single batch (0 for no limit)")
+	config.BindFlag("endpoints.batch.max-events", flags.Lookup("batch-max-events"))
+
+	viper.SetDefault("endpoints.batch.max-bytes", batchMaxBytes)
+	flags.Int("batch-max-bytes", batchMaxBytes, "Maximum size (in bytes) of the body of a single batch (0 for no limit)")
+	config.BindFlag("endpoints.batch.max-bytes", flags.Lookup("batch-max-bytes"))
 
 	// Flags and default configurations for the web service timeouts
 	viper.SetDefault("endpoints.timeouts.headers", headersTimeout)
 	flags.Int("headers-timeout", headersTimeout, "Timeout (in seconds) to read the headers for the request")
-	_ = viper.BindPFlag("endpoints.timeouts.headers", flags.Lookup("headers-timeout"))
+	config.BindFlag("endpoints.timeouts.headers", flags.Lookup("headers-timeout"))
 
 	viper.SetDefault("endpoints.timeouts.read", readTimeout)
 	flags.Int("read-timeout", readTimeout, "Timeout (in seconds) to read the full request, after the headers")
-	_ = viper.BindPFlag("endpoints.timeouts.read", flags.Lookup("read-timeout"))
+	config.BindFlag("endpoints.timeouts.read", flags.Lookup("read-timeout"))
 
 	viper.SetDefault("endpoints.timeouts.write", writeTimeout)
 	flags.Int("write-timeout", writeTimeout, "Timeout (in seconds) to write the full response, including the body")
-	_ = viper.BindPFlag("endpoints.timeouts.write", flags.Lookup("write-timeout"))
+	config.BindFlag("endpoints.timeouts.write", flags.Lookup("write-timeout"))
 
 	viper.SetDefault("endpoints.timeouts.idle", idleTimeout)
 	flags.Int("idle-timeout", idleTimeout, "Timeout (in seconds) to keep a connection open between requests")
-	_ = viper.BindPFlag("endpoints.timeouts.idle", flags.Lookup("idle-timeout"))
+	config.BindFlag("endpoints.timeouts.idle", flags.Lookup("idle-timeout"))
 
 	viper.SetDefault("endpoints.timeouts.shutdown", shutdownTimeout)
 	flags.Int("shutdown-timeout", shutdownTimeout, "Timeout (in seconds) to wait for requests to finish")
-	_ = viper.BindPFlag("endpoints.timeouts.shutdown", flags.Lookup("shutdown-timeout"))
+	config.BindFlag("endpoints.timeouts.shutdown", flags.Lookup("shutdown-timeout"))
+
+	// Flags and default configurations for TLS on the web and metrics services
+	flags.String("tls-cert", "", "Path to the TLS certificate for the web service")
+	config.BindFlag("endpoints.tls.web.cert", flags.Lookup("tls-cert"))
+
+	flags.String("tls-key", "", "Path to the TLS private key for the web service")
+	config.BindFlag("endpoints.tls.web.key", flags.Lookup("tls-key"))
+
+	flags.String("metrics-tls-cert", "", "Path to the TLS certificate for the metrics service")
+	config.BindFlag("endpoints.tls.metrics.cert", flags.Lookup("metrics-tls-cert"))
+
+	flags.String("metrics-tls-key", "", "Path to the TLS private key for the metrics service")
+	config.BindFlag("endpoints.tls.metrics.key", flags.Lookup("metrics-tls-key"))
+
+	viper.SetDefault("endpoints.tls.web.min-version", tlsMinVersion)
+	viper.SetDefault("endpoints.tls.metrics.min-version", tlsMinVersion)
 
 	viper.SetDefault("logging.metrics", false)
 	flags.Bool("log-metrics", false, "Set whether to log metrics port requests")
-	_ = viper.BindPFlag("logging.metrics", flags.Lookup("log-metrics"))
+	config.BindFlag("logging.metrics", flags.Lookup("log-metrics"))
+
+	viper.SetDefault("store.driver", storeDriver)
+	flags.String("store-driver", storeDriver, "The driver used to store events ("+strings.Join(store.Drivers(), ", ")+")")
+	config.BindFlag("store.driver", flags.Lookup("store-driver"))
+
+	viper.SetDefault("store.dynamodb.table", dynamodbTable)
+	flags.String("dynamodb-table", dynamodbTable, "The name of the DynamoDB table to store events in")
+	config.BindFlag("store.dynamodb.table", flags.Lookup("dynamodb-table"))
+
+	flags.String("dynamodb-endpoint", "", "Override the endpoint for DynamoDB (e.g. http://localhost:8000)")
+	config.BindFlag("store.dynamodb.endpoint", flags.Lookup("dynamodb-endpoint"))
+
+	viper.SetDefault("store.dynamodb.create", true)
+	viper.SetDefault("store.dynamodb.ttl", dynamodbTTL)
+
+	viper.SetDefault("groups.rollup", groupsRollup)
+	flags.String("groups-rollup", groupsRollup, "The rule to roll up the status of each group ("+strings.Join(event.Rollups, ", ")+")")
+	config.BindFlag("groups.rollup", flags.Lookup("groups-rollup"))
+
+	viper.SetDefault("expiry.interval", expiryInterval)
+	flags.Int("expiry-interval", expiryInterval, "Interval (in seconds) between checks for stale and expired events")
+	config.BindFlag("expiry.interval", flags.Lookup("expiry-interval"))
+
+	viper.SetDefault("expiry.retention", expiryRetention)
+	flags.Int("expiry-retention", expiryRetention, "Time (in seconds) to keep stale events before removing them (0 to keep)")
+	config.BindFlag("expiry.retention", flags.Lookup("expiry-retention"))
+
+	viper.SetDefault("stream.heartbeat", streamHeartbeat)
+	flags.Int("stream-heartbeat", streamHeartbeat, "Interval (in seconds) between heart-beats on the live event stream")
+	config.BindFlag("stream.heartbeat", flags.Lookup("stream-heartbeat"))
+
+	viper.SetDefault("broker.enabled", false)
+	flags.Bool("broker", false, "Enable sharing events between instances through the message broker")
+	config.BindFlag("broker.enabled", flags.Lookup("broker"))
+
+	viper.SetDefault("broker.address", brokerAddress)
+	flags.String("broker-address", brokerAddress, "The address of the message broker (STOMP)")
+	config.BindFlag("broker.address", flags.Lookup("broker-address"))
+
+	viper.SetDefault("authentication.anonymous", anonymousScopes)
+	viper.SetDefault("authentication.signing.skew", signingSkew)
 
 	viper.SetDefault("cluster.name", name)
 	flags.StringP("cluster-name", "n", name, "The name of the cluster")
-	_ = viper.BindPFlag("cluster.name", flags.Lookup("cluster-name"))
+	config.BindFlag("cluster.name", flags.Lookup("cluster-name"))
 
 	rootCmd.AddCommand(serveCmd)
 }
@@ -154,21 +309,92 @@ func runServe(_ *cobra.Command, _ []string) error {
 	}
 
 	gin.SetMode(gin.ReleaseMode)
-	logger.Start(&map[string]string{
+
+	application := &map[string]string{
 		"name":       Name,
 		"version":    Version,
 		"commit":     Commit,
 		"arch":       Architecture,
 		"build-date": BuildDate,
-	})
+	}
+
+	logger.Start(application)
+	config.LogWarnings()
 
 	// Create a context that listens for the interrupt signal from the Operating
 	// System so we can capture it and then trigger a graceful shutdown
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	m := metrics.NewService()
-	w := web.NewService()
+	if err := middleware.LoadKeys(); err != nil {
+		return fmt.Errorf("unable to load the API keys: %w", err)
+	}
+
+	if err := middleware.LoadProxies(); err != nil {
+		return fmt.Errorf("unable to load the trusted proxies: %w", err)
+	}
+
+	if err := middleware.LoadRateLimits(); err != nil {
+		return fmt.Errorf("unable to load the rate limits: %w", err)
+	}
+
+	s, err := store.New(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to create the event store: %w", err)
+	}
+
+	defer func() {
+		if err := s.Close(); err != nil {
+			slog.Error(
+				"Failed to close the event store",
+				slog.Group("error",
+					slog.String("message", err.Error()),
+				),
+			)
+		}
+	}()
+
+	h := hub.NewHub(viper.GetString("cluster.name"))
+	b := broker.NewClient()
+
+	m, err := metrics.NewService()
+	if err != nil {
+		return fmt.Errorf("unable to create the metrics service: %w", err)
+	}
+
+	// The index of the groups is kept up to date from the events saved or
+	// removed, and from each sweep of the reaper, which lists all the events
+	i := groups.NewIndex(s)
+	p := event.Publishers{h, b, i}
+
+	w, err := web.NewService(s, h, p, i)
+	if err != nil {
+		return fmt.Errorf("unable to create the web service: %w", err)
+	}
+
+	// Events accepted by the other instances in the cluster only need to be
+	// pushed out to the clients connected to this instance, and to the index
+	b.Subscribe(event.Publishers{h, i}.Publish)
+
+	r := reaper.New(s, p)
+	r.Subscribe(i.Replace)
+
+	go store.Watch(ctx, s, time.Duration(storeInterval)*time.Second, m.SetStoreHealth)
+	go r.Run(ctx, time.Duration(viper.GetInt("expiry.interval"))*time.Second)
+	go reload.Watch(ctx, serveConfigName, func() error {
+		// Only change the logging once the other settings have been applied, as
+		// they can still fail, and the previous configuration will be restored
+		if err := middleware.Load(); err != nil {
+			return err
+		}
+
+		logger.Start(application)
+		config.LogWarnings()
+		m.SetLogging(viper.GetBool("logging.metrics"))
+
+		return nil
+	})
+	go b.Start(ctx, m.SetBrokerHealth)
 
 	e := make(chan error)
 
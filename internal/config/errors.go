@@ -1,6 +1,9 @@
 package config
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type (
 	// LoadError represents a general error in processing the configuration file,
@@ -19,6 +22,15 @@ type (
 		message string
 		err     error
 	}
+
+	// ValidationError represents that the configuration was loaded, but does
+	// not match the schema for the command, listing each of the `Violations`
+	// found in the configuration.
+	ValidationError struct {
+		file       string
+		message    string
+		Violations []*Violation
+	}
 )
 
 // Error returns the error message for this error.
@@ -51,3 +63,27 @@ func NewNotFoundError(file, message string, err error) error {
 		err:     err,
 	}
 }
+
+// Error returns the error message for this error, listing each violation on a
+// separate line.
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Violations)+1)
+	lines = append(lines, fmt.Sprintf("%s: %s", e.message, e.file))
+
+	for _, v := range e.Violations {
+		lines = append(lines, "    - "+v.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// NewValidationError creates a new `ValidationError` error type with the
+// provided `file` and `message` about the error, and the `violations` found in
+// the configuration.
+func NewValidationError(file, message string, violations []*Violation) error {
+	return &ValidationError{
+		file:       file,
+		message:    message,
+		Violations: violations,
+	}
+}
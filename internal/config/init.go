@@ -0,0 +1,85 @@
+package config
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	//go:embed templates/*.yaml
+	templates embed.FS
+
+	// ErrNoTemplate is returned when there is no starter file for the requested
+	// configuration file.
+	ErrNoTemplate = errors.New("no starter file exists for the configuration")
+	// ErrNoWritablePath is returned when none of the configuration paths can be
+	// written to.
+	ErrNoWritablePath = errors.New("none of the configuration paths can be written to")
+	// ErrFileExists is returned when the configuration file already exists and
+	// it should not be overwritten.
+	ErrFileExists = errors.New("the configuration file already exists")
+)
+
+// Template returns the commented starter file for the configuration file
+// `name`, such as `serve.yaml`.
+func Template(name string) ([]byte, error) {
+	data, err := templates.ReadFile("templates/" + name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNoTemplate, name)
+	}
+
+	return data, err
+}
+
+// Init writes the starter file for the configuration file `name` into the
+// first of the `Paths` which can be written to, returning the path to the new
+// file. If the file already exists it will only be replaced if `force` is set.
+func Init(name string, force bool) (string, error) {
+	data, err := Template(name)
+	if err != nil {
+		return "", err
+	}
+
+	mode := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		mode = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+
+	for _, path := range Paths {
+		dir := os.ExpandEnv(path)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			continue
+		}
+
+		file := filepath.Join(dir, name)
+
+		// The configuration may hold secrets, such as API keys, so only allow
+		// the owner to read it
+		f, err := os.OpenFile(file, mode, 0o600)
+		if errors.Is(err, fs.ErrExist) {
+			return "", fmt.Errorf("%w: %s", ErrFileExists, file)
+		}
+
+		if err != nil {
+			continue
+		}
+
+		_, err = f.Write(data)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("unable to write %s: %w", file, err)
+		}
+
+		return file, nil
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrNoWritablePath, strings.Join(Paths, ", "))
+}
@@ -0,0 +1,67 @@
+//nolint:paralleltest // these tests cannot operate in parallel
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/config"
+)
+
+// TestTemplates tests that the starter files for each of the commands are
+// valid against their schemas, even in strict mode.
+func TestTemplates(t *testing.T) {
+	config.Strict = true
+
+	t.Cleanup(func() { config.Strict = false })
+
+	for _, name := range []string{sendConfigName, serveConfigName} {
+		viper.Reset()
+
+		data, err := config.Template(name)
+		require.NoError(t, err)
+
+		file := filepath.Join(t.TempDir(), name)
+		require.NoError(t, os.WriteFile(file, data, 0o600))
+
+		err = config.Load(name, file)
+		assert.NoError(t, err, name)
+	}
+}
+
+// TestInit tests that the starter file is written into the first writable
+// path, and that an existing file is only replaced when forced.
+func TestInit(t *testing.T) {
+	blocked := filepath.Join(t.TempDir(), "blocked")
+	require.NoError(t, os.WriteFile(blocked, nil, 0o600))
+
+	dir := t.TempDir()
+
+	// A file in place of the first directory means it cannot be written to
+	config.Paths = []string{filepath.Join(blocked, "dashboard"), dir}
+
+	file, err := config.Init(sendConfigName, false)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, sendConfigName), file)
+
+	expected, err := config.Template(sendConfigName)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, expected, data)
+
+	_, err = config.Init(sendConfigName, false)
+	require.ErrorIs(t, err, config.ErrFileExists)
+
+	_, err = config.Init(sendConfigName, true)
+	require.NoError(t, err)
+
+	_, err = config.Init(missingConfigName, false)
+	require.ErrorIs(t, err, config.ErrNoTemplate)
+}
@@ -4,38 +4,56 @@
 package config
 
 import (
+	"bytes"
 	"errors"
+	"os"
 	"strings"
+	"sync"
 
 	"github.com/spf13/viper"
 )
 
-// Paths sets the default paths which will be used to look for the default
-// configuration file for each of the application commands.
-var Paths = []string{
-	"$HOME/.config/dashboard",
-	"/etc/dashboard",
-}
+// envPrefix is the prefix for all the environment variables which can be used
+// to configure the application.
+const envPrefix = "dashboard"
+
+var (
+	// Paths sets the default paths which will be used to look for the default
+	// configuration file for each of the application commands.
+	Paths = []string{
+		"$HOME/.config/dashboard",
+		"/etc/dashboard",
+	}
+
+	// envReplacer changes any dash or period in the configuration path to an
+	// underscore to simplify configuration, and so, for example, `web.log-path`
+	// would change from `DASHBOARD_WEB.LOG-PATH` to `DASHBOARD_WEB_LOG_PATH`
+	envReplacer = strings.NewReplacer(
+		// These must be set in pairs for each old/new replacement
+		"-", "_",
+		".", "_",
+	)
+
+	// ErrNoConfigFile is returned when the configuration is reloaded but no
+	// configuration file was loaded to begin with.
+	ErrNoConfigFile = errors.New("no configuration file has been loaded")
+
+	// loaded holds the contents of the configuration file when it was last
+	// successfully loaded, so it can be restored if a reload fails.
+	loaded []byte
+	// reloading ensures only one reload of the configuration runs at a time.
+	reloading sync.Mutex
+)
 
 // Load prepares the environment for processing the configuration file for the
 // application command, where `name` is the default filename to be searched for
 // in `Paths`, otherwise, if set, `file` will override the search and point, and
-// then reads the file into `Viper`.
+// then reads the file into `Viper`, before validating the merged configuration
+// against the schema for `name`.
 func Load(name, file string) error {
 	viper.AutomaticEnv()
-	viper.SetEnvPrefix("dashboard")
-
-	// Use SetEnvKeyReplacer to change any dash or period in the configuration
-	// path to an underscore to simplify configuration, and so, for example,
-	// `web.log-path` would change from `DASHBOARD_WEB.LOG-PATH` to
-	// `DASHBOARD_WEB_LOG_PATH` instead
-	viper.SetEnvKeyReplacer(
-		strings.NewReplacer(
-			// These must be set in pairs for each old/new replacement
-			"-", "_",
-			".", "_",
-		),
-	)
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(envReplacer)
 
 	if file != "" {
 		viper.SetConfigFile(file)
@@ -44,7 +62,7 @@ func Load(name, file string) error {
 			return err
 		}
 
-		return nil
+		return validate(name)
 	}
 
 	viper.SetConfigName(name)
@@ -58,19 +76,76 @@ func Load(name, file string) error {
 	if err != nil {
 		var expected *NotFoundError
 
-		if errors.As(err, &expected) {
-			return nil
+		if !errors.As(err, &expected) {
+			return err
 		}
 	}
 
-	return err
+	return validate(name)
+}
+
+// Reload reads the configuration file which was last loaded again, validates
+// it against the schema for `name`, and then calls `apply` to put it into use.
+// If the file cannot be read, the configuration is not valid, or it cannot be
+// applied, the previous configuration is restored and the error returned.
+func Reload(name string, apply func() error) error {
+	reloading.Lock()
+	defer reloading.Unlock()
+
+	file := viper.ConfigFileUsed()
+	if file == "" || loaded == nil {
+		return ErrNoConfigFile
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return NewLoadError(file, "unable to read configuration file", err)
+	}
+
+	if err := viper.ReadConfig(bytes.NewReader(data)); err != nil {
+		restore()
+
+		return NewLoadError(file, "unable to process configuration file", err)
+	}
+
+	if err := validate(name); err != nil {
+		restore()
+
+		return err
+	}
+
+	if err := apply(); err != nil {
+		restore()
+		// Validate the previous configuration again to restore its warnings, as
+		// it was valid when it was loaded
+		_ = validate(name)
+
+		return err
+	}
+
+	loaded = data
+
+	return nil
+}
+
+// restore replaces the configuration with the contents of the configuration
+// file when it was last successfully loaded.
+func restore() {
+	// This has already been read successfully, so it cannot fail
+	_ = viper.ReadConfig(bytes.NewReader(loaded))
 }
 
 // read loads the `file` to configure the dashboard send or serve service.
 func read() error {
+	loaded = nil
+
 	err := viper.ReadInConfig()
 	if err == nil {
-		return nil
+		// Keep a copy of the file so it can be restored if a later reload fails
+		loaded, err = os.ReadFile(viper.ConfigFileUsed())
+		if err == nil {
+			return nil
+		}
 	}
 
 	var check viper.ConfigFileNotFoundError
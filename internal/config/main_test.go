@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -18,6 +19,8 @@ const (
 	validConfigName   = "valid.yaml"
 	invalidConfigName = "invalid.yaml"
 	missingConfigName = "missing.yaml"
+	unknownConfigName = "unknown.yaml"
+	brokenConfigName  = "violations.yaml"
 )
 
 var noConfigFile string
@@ -100,3 +103,129 @@ func TestExplicitLoadInvalid(t *testing.T) {
 	err := config.Load(serveConfigName, file)
 	assert.Error(t, err)
 }
+
+// TestValidateUnknown tests that unknown settings in the configuration file
+// are only reported as warnings, with the line they were found on.
+func TestValidateUnknown(t *testing.T) {
+	viper.Reset()
+
+	config.Paths = []string{"."}
+	config.Strict = false
+	file := filepath.Join("testdata", unknownConfigName)
+
+	err := config.Load(serveConfigName, file)
+	require.NoError(t, err)
+
+	warnings := config.Warnings()
+	require.Len(t, warnings, 1)
+	assert.Equal(t, "endpoints.bind.ports", warnings[0].Path)
+	assert.Equal(t, 5, warnings[0].Line)
+	assert.True(t, warnings[0].Unknown)
+}
+
+// TestValidateUnknownStrict tests that unknown settings in the configuration
+// file are reported as errors when running in strict mode.
+func TestValidateUnknownStrict(t *testing.T) {
+	viper.Reset()
+
+	config.Paths = []string{"."}
+	config.Strict = true
+
+	t.Cleanup(func() { config.Strict = false })
+
+	file := filepath.Join("testdata", unknownConfigName)
+
+	err := config.Load(serveConfigName, file)
+	require.Error(t, err)
+
+	var invalid *config.ValidationError
+	require.ErrorAs(t, err, &invalid)
+	require.Len(t, invalid.Violations, 1)
+	assert.Equal(t, "endpoints.bind.ports", invalid.Violations[0].Path)
+	assert.Empty(t, config.Warnings())
+}
+
+// TestValidateViolations tests that all the settings which do not match the
+// schema are reported together, in order, with the line they were set on.
+func TestValidateViolations(t *testing.T) {
+	viper.Reset()
+
+	config.Paths = []string{"."}
+	file := filepath.Join("testdata", brokenConfigName)
+
+	err := config.Load(serveConfigName, file)
+	require.Error(t, err)
+
+	var invalid *config.ValidationError
+	require.ErrorAs(t, err, &invalid)
+
+	lines := map[string]int{}
+	for _, v := range invalid.Violations {
+		lines[v.Path] = v.Line
+	}
+
+	assert.Equal(t, map[string]int{
+		"endpoints.bind.port.web": 6,
+		"store.driver":            11,
+		"logging.level":           14,
+	}, lines)
+	assert.Contains(t, err.Error(), "logging.level (line 14)")
+}
+
+// TestValidateEnvironment tests that settings from the environment are
+// checked against the schema as if they had been set in the file.
+func TestValidateEnvironment(t *testing.T) {
+	viper.Reset()
+
+	config.Paths = []string{"."}
+	file := filepath.Join("testdata", serveConfigName)
+
+	// Only settings known to Viper (such as from the defaults) are read from
+	// the environment, as the commands would set before loading
+	viper.SetDefault("endpoints.timeouts.read", 5)
+	t.Setenv("DASHBOARD_ENDPOINTS_TIMEOUTS_READ", "10")
+
+	err := config.Load(serveConfigName, file)
+	require.NoError(t, err)
+
+	t.Setenv("DASHBOARD_ENDPOINTS_TIMEOUTS_READ", "slow")
+
+	err = config.Load(serveConfigName, file)
+	require.Error(t, err)
+
+	var invalid *config.ValidationError
+	require.ErrorAs(t, err, &invalid)
+	require.Len(t, invalid.Violations, 1)
+	assert.Equal(t, "endpoints.timeouts.read", invalid.Violations[0].Path)
+	assert.Zero(t, invalid.Violations[0].Line)
+}
+
+// TestValidateFlags tests that settings from the flags are checked against the
+// schema, but only when the flag has been set, rather than left as its default.
+func TestValidateFlags(t *testing.T) {
+	viper.Reset()
+
+	config.Paths = []string{"."}
+	file := filepath.Join("testdata", serveConfigName)
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("read-timeout", "slow", "")
+
+	viper.SetDefault("endpoints.timeouts.read", "slow")
+	config.BindFlag("endpoints.timeouts.read", flags.Lookup("read-timeout"))
+	t.Cleanup(func() { flags.Lookup("read-timeout").Changed = false })
+
+	err := config.Load(serveConfigName, file)
+	require.NoError(t, err)
+
+	require.NoError(t, flags.Parse([]string{"--read-timeout", "slower"}))
+
+	err = config.Load(serveConfigName, file)
+	require.Error(t, err)
+
+	var invalid *config.ValidationError
+	require.ErrorAs(t, err, &invalid)
+	require.Len(t, invalid.Violations, 1)
+	assert.Equal(t, "endpoints.timeouts.read", invalid.Violations[0].Path)
+	assert.Zero(t, invalid.Violations[0].Line)
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Source is where the value of a setting in the configuration was taken from.
+type Source string
+
+const (
+	// SourceDefault is used for settings which have not been changed from the
+	// default value provided by the application.
+	SourceDefault Source = "default"
+	// SourceFile is used for settings which have been set in the configuration
+	// file.
+	SourceFile Source = "file"
+	// SourceEnv is used for settings which have been set through a `DASHBOARD_*`
+	// environment variable.
+	SourceEnv Source = "env"
+	// SourceFlag is used for settings which have been set through a flag on the
+	// command-line.
+	SourceFlag Source = "flag"
+
+	// masked replaces the value of any secret setting when it is shown.
+	masked = "********"
+)
+
+var (
+	// flags holds the command-line flags bound to each setting, so that the
+	// source of the setting can be found.
+	flags = map[string]*pflag.Flag{}
+
+	// secrets is the list of names for settings which should never be shown,
+	// including the hashes of API keys, which could be attacked offline.
+	secrets = []string{"api-key", "hash", "password", "secret", "token"}
+)
+
+// Setting is the effective value of a single setting in the configuration,
+// along with where the value was taken from.
+type Setting struct {
+	Key    string `json:"key"    yaml:"key"`
+	Value  any    `json:"value"  yaml:"value"`
+	Source Source `json:"source" yaml:"source"`
+}
+
+// BindFlag binds the command-line `flag` to the setting `key` in Viper, and
+// keeps a record of it so the flag can be reported as the source of the value.
+func BindFlag(key string, flag *pflag.Flag) {
+	flags[key] = flag
+	_ = viper.BindPFlag(key, flag)
+}
+
+// Settings returns the effective value of each setting in the configuration
+// known to the schema for the configuration file `name`, sorted by their key,
+// with the values of any secrets masked. This should be called after `Load`.
+func Settings(name string) ([]*Setting, error) {
+	schema, err := compile(schemaName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := viper.AllKeys()
+	slices.Sort(keys)
+
+	settings := make([]*Setting, 0, len(keys))
+
+	for _, key := range keys {
+		if !known(schema, strings.Split(key, ".")) {
+			// Skip settings from the defaults or the flags of the other commands
+			continue
+		}
+
+		settings = append(settings, &Setting{
+			Key:    key,
+			Value:  mask(key, viper.Get(key)),
+			Source: source(key),
+		})
+	}
+
+	return settings, nil
+}
+
+// source returns where the value of the setting `key` was taken from, in the
+// same order of precedence used by Viper.
+func source(key string) Source {
+	if flag, ok := flags[key]; ok && flag.Changed {
+		return SourceFlag
+	}
+
+	if fromEnv(key) {
+		return SourceEnv
+	}
+
+	if viper.InConfig(key) {
+		return SourceFile
+	}
+
+	return SourceDefault
+}
+
+// known checks whether the setting at `path` is part of the `schema`.
+func known(schema *jsonschema.Schema, path []string) bool {
+	for schema.Ref != nil {
+		schema = schema.Ref
+	}
+
+	if len(path) == 0 {
+		return true
+	}
+
+	next, ok := schema.Properties[path[0]]
+	if !ok {
+		// Allow any names for the settings in maps, such as the groups of routes
+		// for the rate limits, which are checked against the same schema
+		if next, ok = schema.AdditionalProperties.(*jsonschema.Schema); !ok {
+			return false
+		}
+	}
+
+	return known(next, path[1:])
+}
+
+// mask returns the `value` of the setting `key`, replaced if the setting holds
+// a secret, or with any secrets within it replaced if it holds a list or a map
+// of settings, such as the API keys.
+func mask(key string, value any) any {
+	if secret(key) {
+		if value == "" || value == nil {
+			return value
+		}
+
+		return masked
+	}
+
+	switch v := value.(type) {
+	case []any:
+		values := make([]any, len(v))
+		for i, item := range v {
+			values[i] = mask(key, item)
+		}
+
+		return values
+	case []map[string]any:
+		values := make([]any, len(v))
+		for i, item := range v {
+			values[i] = mask(key, item)
+		}
+
+		return values
+	case map[string]any:
+		values := make(map[string]any, len(v))
+		for name, item := range v {
+			values[name] = mask(key+"."+name, item)
+		}
+
+		return values
+	}
+
+	return value
+}
+
+// secret checks whether the setting `key` holds a secret, based on its name.
+func secret(key string) bool {
+	name := key[strings.LastIndex(key, ".")+1:]
+
+	return slices.Contains(secrets, name)
+}
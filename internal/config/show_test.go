@@ -0,0 +1,95 @@
+//nolint:paralleltest // these tests cannot operate in parallel
+package config_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/config"
+)
+
+// TestSettings tests that the effective value of each setting is reported
+// along with its source, that secrets are masked, and that settings unknown to
+// the schema for the command are not included.
+func TestSettings(t *testing.T) {
+	viper.Reset()
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Int("timeout", 10, "")
+	flags.Bool("sign", false, "")
+
+	viper.SetDefault("timeout", 10)
+	config.BindFlag("timeout", flags.Lookup("timeout"))
+	viper.SetDefault("sign", false)
+	config.BindFlag("sign", flags.Lookup("sign"))
+	viper.SetDefault("logging.level", "info")
+	viper.SetDefault("store.driver", "memory")
+
+	require.NoError(t, flags.Parse([]string{"--timeout", "30"}))
+	t.Setenv("DASHBOARD_LOGGING_LEVEL", "debug")
+
+	config.Paths = []string{"."}
+	file := filepath.Join("testdata", validConfigName)
+
+	err := config.Load(sendConfigName, file)
+	require.NoError(t, err)
+
+	settings, err := config.Settings(sendConfigName)
+	require.NoError(t, err)
+
+	found := map[string]*config.Setting{}
+	for _, s := range settings {
+		found[s.Key] = s
+	}
+
+	assert.NotContains(t, found, "store.driver")
+
+	expected := map[string]config.Source{
+		"timeout":       config.SourceFlag,
+		"logging.level": config.SourceEnv,
+		"endpoint-uri":  config.SourceFile,
+		"logging.json":  config.SourceFile,
+		"sign":          config.SourceDefault,
+	}
+
+	for key, source := range expected {
+		require.Contains(t, found, key)
+		assert.Equal(t, source, found[key].Source, key)
+	}
+
+	require.Contains(t, found, "api-key")
+	assert.Equal(t, "********", found["api-key"].Value)
+	assert.Equal(t, "debug", found["logging.level"].Value)
+}
+
+// TestSettingsKeys tests that the hashes and the secrets of the API keys are
+// masked, while the other settings for each key are still shown.
+func TestSettingsKeys(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	viper.Set("authentication.keys", []any{
+		map[string]any{"name": "ci", "hash": "0123456789abcdef", "scopes": []any{"events:write"}},
+		map[string]any{"name": "signed", "secret": "a-long-enough-signing-secret", "signed": true},
+	})
+
+	settings, err := config.Settings(serveConfigName)
+	require.NoError(t, err)
+
+	var keys []any
+
+	for _, s := range settings {
+		if s.Key == "authentication.keys" {
+			keys, _ = s.Value.([]any)
+		}
+	}
+
+	require.Len(t, keys, 2)
+	assert.Equal(t, map[string]any{"name": "ci", "hash": "********", "scopes": []any{"events:write"}}, keys[0])
+	assert.Equal(t, map[string]any{"name": "signed", "secret": "********", "signed": true}, keys[1])
+}
@@ -0,0 +1,420 @@
+package config
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"github.com/spf13/viper"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"gopkg.in/yaml.v3"
+
+	"github.com/n3tuk/dashboard/schemas"
+)
+
+var (
+	// Strict sets whether unknown settings in the configuration are treated as
+	// an error, rather than only being reported as a warning.
+	Strict = false
+
+	// warnings holds the unknown settings found in the configuration when it
+	// was last loaded, if not running in strict mode.
+	warnings []*Violation
+
+	// printer formats the messages from the schema validation.
+	printer = message.NewPrinter(language.English)
+)
+
+// Violation is a single setting in the configuration which does not match the
+// schema for the configuration file.
+type Violation struct {
+	// Path is the path to the setting, such as `endpoints.bind.port`.
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+	// Line is the line in the configuration file where the setting was found,
+	// or zero if it was not set in the file (such as from the environment).
+	Line int `json:"line,omitempty" yaml:"line,omitempty"`
+	// Message describes why the setting does not match the schema.
+	Message string `json:"message" yaml:"message"`
+	// Unknown is set if the setting is not known to the schema.
+	Unknown bool `json:"-" yaml:"-"`
+}
+
+// String returns the description of the violation, including its line in the
+// configuration file, if known.
+func (v *Violation) String() string {
+	path := v.Path
+	if path == "" {
+		path = "(root)"
+	}
+
+	if v.Line > 0 {
+		return fmt.Sprintf("%s (line %d): %s", path, v.Line, v.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", path, v.Message)
+}
+
+// Warnings returns the unknown settings found in the configuration when it
+// was last loaded, which were not treated as errors as `Strict` was not set.
+func Warnings() []*Violation {
+	return warnings
+}
+
+// LogWarnings logs each of the unknown settings found in the configuration
+// when it was last loaded, which should be called once the logger is started.
+func LogWarnings() {
+	for _, v := range warnings {
+		slog.Warn(
+			"Unknown setting in the configuration file",
+			slog.Group("config",
+				slog.String("file", viper.ConfigFileUsed()),
+				slog.String("path", v.Path),
+				slog.Int("line", v.Line),
+			),
+		)
+	}
+}
+
+// validate checks the merged configuration, from the configuration file, the
+// environment, and the defaults, against the schema for the configuration file
+// `name`, returning a `ValidationError` listing all the settings which do not
+// match. Unknown settings are only included if `Strict` is set, otherwise they
+// are kept as warnings.
+func validate(name string) error {
+	warnings = nil
+
+	schema, err := compile(schemaName(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		// There is no schema for this configuration file to validate against
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	document, err := settings()
+	if err != nil {
+		return err
+	}
+
+	file := viper.ConfigFileUsed()
+	source := parse(file)
+
+	var result *jsonschema.ValidationError
+	if err := schema.Validate(document); !errors.As(err, &result) {
+		return err
+	}
+
+	violations := []*Violation{}
+
+	for _, v := range collect(result) {
+		v.Line = line(source, v.Path, v.Unknown)
+
+		// Only report on the settings which have been configured, rather than
+		// those from the defaults, or the flags of the other commands
+		if v.Line == 0 && !configured(v.Path) {
+			continue
+		}
+
+		if v.Unknown && !Strict {
+			warnings = append(warnings, v)
+
+			continue
+		}
+
+		violations = append(violations, v)
+	}
+
+	if len(violations) > 0 {
+		slices.SortFunc(violations, func(a, b *Violation) int {
+			return cmp.Or(cmp.Compare(a.Line, b.Line), strings.Compare(a.Path, b.Path))
+		})
+
+		return NewValidationError(file, "invalid configuration", violations)
+	}
+
+	return nil
+}
+
+// ValidateDocument checks the `document`, such as one decoded from a JSON or
+// YAML file, against the embedded schema `name` (such as `event.json`),
+// returning a `ValidationError` for the `source` of the document listing all
+// the fields which do not match.
+func ValidateDocument(name, source string, document any) error {
+	schema, err := compile(name)
+	if err != nil {
+		return err
+	}
+
+	// Convert the document to JSON first, so that any values decoded from YAML
+	// (such as times) are given to the schema as they would be sent
+	data, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	var result *jsonschema.ValidationError
+	if err := schema.Validate(instance); !errors.As(err, &result) {
+		return err
+	}
+
+	violations := collect(result)
+	for _, v := range violations {
+		if v.Unknown {
+			v.Message = "unknown field"
+		}
+	}
+
+	slices.SortFunc(violations, func(a, b *Violation) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	return NewValidationError(source, "invalid document", violations)
+}
+
+// schemaName returns the name of the embedded schema for the configuration
+// file `name`, such as `serve.json` for `serve.yaml`.
+func schemaName(name string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name)) + ".json"
+}
+
+// compile builds the embedded schema with the file `name`, along with all the
+// other embedded schemas it may reference.
+func compile(name string) (*jsonschema.Schema, error) {
+	if _, err := fs.Stat(schemas.Files(), name); err != nil {
+		return nil, err
+	}
+
+	compiler := jsonschema.NewCompiler()
+
+	files, err := fs.Glob(schemas.Files(), "*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		data, err := fs.ReadFile(schemas.Files(), file)
+		if err != nil {
+			return nil, err
+		}
+
+		doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the schema %s: %w", file, err)
+		}
+
+		if err := compiler.AddResource(schemas.BaseURL+file, doc); err != nil {
+			return nil, err
+		}
+	}
+
+	return compiler.Compile(schemas.BaseURL + name)
+}
+
+// settings returns the merged configuration from Viper as a JSON document.
+// Settings from the environment are always strings, so they are decoded as
+// YAML values first, as if they had been set in the configuration file.
+func settings() (any, error) {
+	merged := viper.AllSettings()
+
+	for _, key := range viper.AllKeys() {
+		value, ok := os.LookupEnv(envName(key))
+		if !ok {
+			continue
+		}
+
+		var decoded any
+		if err := yaml.Unmarshal([]byte(value), &decoded); err != nil {
+			decoded = value
+		}
+
+		set(merged, strings.Split(key, "."), decoded)
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonschema.UnmarshalJSON(bytes.NewReader(data))
+}
+
+// set replaces the value at the `path` in the nested `settings` with `value`.
+func set(settings map[string]any, path []string, value any) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := settings[key].(map[string]any)
+		if !ok {
+			return
+		}
+
+		settings = next
+	}
+
+	settings[path[len(path)-1]] = value
+}
+
+// collect flattens the tree of errors from the validation into a list of
+// violations, one for each setting which does not match the schema.
+func collect(err *jsonschema.ValidationError) []*Violation {
+	path := strings.Join(err.InstanceLocation, ".")
+
+	switch k := err.ErrorKind.(type) {
+	case *kind.AdditionalProperties:
+		violations := make([]*Violation, 0, len(k.Properties))
+
+		for _, property := range k.Properties {
+			violations = append(violations, &Violation{
+				Path:    strings.TrimPrefix(path+"."+property, "."),
+				Message: "unknown setting",
+				Unknown: true,
+			})
+		}
+
+		return violations
+	case *kind.AnyOf, *kind.OneOf:
+		// Report the alternatives as a single violation, rather than one for
+		// each of the alternatives which did not match
+		messages := []string{}
+		for _, cause := range err.Causes {
+			for _, v := range collect(cause) {
+				messages = append(messages, v.Message)
+			}
+		}
+
+		return []*Violation{{Path: path, Message: strings.Join(messages, "; or ")}}
+	}
+
+	if len(err.Causes) == 0 {
+		return []*Violation{{Path: path, Message: err.ErrorKind.LocalizedString(printer)}}
+	}
+
+	violations := []*Violation{}
+	for _, cause := range err.Causes {
+		violations = append(violations, collect(cause)...)
+	}
+
+	return violations
+}
+
+// parse reads the configuration `file` as a YAML document (which also
+// supports JSON), so that settings can be found in it, returning nil if the
+// file cannot be parsed.
+func parse(file string) *yaml.Node {
+	if file == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+
+	document := &yaml.Node{}
+	if err := yaml.Unmarshal(data, document); err != nil || len(document.Content) == 0 {
+		return nil
+	}
+
+	return document.Content[0]
+}
+
+// line returns the line in the `source` configuration where the setting at
+// `path` was set, either for its `key` or its value, or zero if not found.
+func line(source *yaml.Node, path string, key bool) int {
+	if source == nil {
+		return 0
+	}
+
+	if path == "" {
+		return source.Line
+	}
+
+	node := source
+	found := node
+
+	for _, segment := range strings.Split(path, ".") {
+		switch node.Kind {
+		case yaml.MappingNode:
+			next := (*yaml.Node)(nil)
+
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if strings.EqualFold(node.Content[i].Value, segment) {
+					found = node.Content[i]
+					next = node.Content[i+1]
+
+					break
+				}
+			}
+
+			if next == nil {
+				return 0
+			}
+
+			node = next
+		case yaml.SequenceNode:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index >= len(node.Content) {
+				return 0
+			}
+
+			node = node.Content[index]
+			found = node
+		default:
+			return 0
+		}
+	}
+
+	if key {
+		return found.Line
+	}
+
+	return node.Line
+}
+
+// configured checks whether the setting at `path`, or any of the settings
+// below it, has been set from a source other than the defaults, such as the
+// environment or a flag on the command-line.
+func configured(path string) bool {
+	for _, key := range viper.AllKeys() {
+		if key != path && path != "" && !strings.HasPrefix(key, path+".") {
+			continue
+		}
+
+		if source(key) != SourceDefault {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fromEnv checks whether the setting at `path` has been set in the
+// environment.
+func fromEnv(path string) bool {
+	_, ok := os.LookupEnv(envName(path))
+
+	return ok
+}
+
+// envName returns the name of the environment variable for the setting at
+// `path`, such as `DASHBOARD_ENDPOINTS_BIND_ADDRESS`.
+func envName(path string) string {
+	return strings.ToUpper(envPrefix + "_" + envReplacer.Replace(path))
+}
@@ -0,0 +1,75 @@
+package event
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidTransition is returned when the status of an event cannot change
+// to the new status.
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+type (
+	// ValidationError represents a single field within an event which has failed
+	// validation, along with the reason why.
+	ValidationError struct {
+		Field   string `json:"field"`
+		Message string `json:"message"`
+	}
+
+	// ValidationErrors represents the collection of all the fields within an
+	// event which have failed validation.
+	ValidationErrors []*ValidationError
+
+	// TransitionError represents an update to an event which would change its
+	// status from one which cannot change to the new status.
+	TransitionError struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+)
+
+// Error returns the error message for this error.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// NewValidationError creates a new `ValidationError` error type with the
+// provided `field` which failed validation and the `message` explaining why.
+func NewValidationError(field, message string) *ValidationError {
+	return &ValidationError{
+		Field:   field,
+		Message: message,
+	}
+}
+
+// Error returns the error message for this error, joining the messages for
+// each of the fields which failed validation.
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+
+	return "event failed validation: " + strings.Join(messages, "; ")
+}
+
+// NewTransitionError creates a new `TransitionError` error type for the change
+// of status `from` the current status `to` the new status.
+func NewTransitionError(from, to string) *TransitionError {
+	return &TransitionError{
+		From: from,
+		To:   to,
+	}
+}
+
+// Error returns the error message for this error.
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("the status cannot change from %s to %s unless the event is reopened", e.From, e.To)
+}
+
+// Is checks whether the `target` is `ErrInvalidTransition`.
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrInvalidTransition
+}
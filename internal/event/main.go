@@ -0,0 +1,269 @@
+// The `event` package provides the model for the events which are submitted to
+// the dashboard, such as the start or completion of a deployment, a job, or a
+// function, along with the rules used to check that an event is valid before it
+// is accepted for processing.
+package event
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// maxIDLength is the maximum number of characters allowed in an event ID.
+	maxIDLength = 128
+	// maxStatusLength is the maximum number of characters allowed in a status.
+	maxStatusLength = 32
+	// maxMessageLength is the maximum number of characters allowed in a message.
+	maxMessageLength = 4096
+	// maxSourceLength is the maximum number of characters allowed in a source.
+	maxSourceLength = 256
+	// maxGroupLength is the maximum number of characters allowed in a group.
+	maxGroupLength = 256
+	// maxLabels is the maximum number of labels which can be attached to an
+	// event.
+	maxLabels = 64
+	// maxLabelKeyLength is the maximum number of characters in a label key.
+	maxLabelKeyLength = 63
+	// maxLabelValueLength is the maximum number of characters in a label value.
+	maxLabelValueLength = 256
+	// maxInterval is the maximum time (in seconds) allowed for the heartbeat and
+	// the TTL of an event, which is 90 days.
+	maxInterval = 90 * 24 * 60 * 60
+)
+
+var (
+	// idPattern is the pattern which all event IDs must match, being a
+	// URL-safe identifier which always starts with an alphanumeric character.
+	idPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._:-]*$`)
+	// statusPattern is the pattern which all statuses must match.
+	statusPattern = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+	// groupPattern is the pattern which all groups must match, being one or more
+	// identifiers separated by a '/'.
+	groupPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*(/[a-zA-Z0-9][a-zA-Z0-9._-]*)*$`)
+	// labelPattern is the pattern which all label keys must match.
+	labelPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._/-]*$`)
+)
+
+// Event represents a single update about something happening within a system,
+// such as a deployment having started, or a job having completed, which is
+// identified by its `ID` so that later updates replace the current state of
+// that event on the dashboard.
+type Event struct {
+	// ID is the unique identifier of the event, which is shared by all the
+	// updates sent for the same deployment, job, or function.
+	ID string `json:"event-id"`
+	// Status is the current status of the event.
+	Status string `json:"status"`
+	// Message is an optional human-readable description of the current status.
+	Message string `json:"message,omitempty"`
+	// Source is an optional identifier for the system which sent the event.
+	Source string `json:"source,omitempty"`
+	// Group is an optional name for the group the event belongs to, allowing
+	// related events to be displayed and listed together.
+	Group string `json:"group,omitempty"`
+	// Labels is an optional set of arbitrary key/value pairs attached to the
+	// event for filtering and display.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Timestamp is the time the update to the event happened, as reported by the
+	// sender, and defaults to the time it was received if not provided.
+	Timestamp time.Time `json:"timestamp"`
+	// Received is the time the event was received by the dashboard, and is
+	// always set by the service rather than by the sender.
+	Received time.Time `json:"received"`
+	// Reopen is set by the sender to allow the status of an event which has
+	// finished to change again, such as when a job is run again.
+	Reopen bool `json:"reopen,omitempty"`
+	// Started is the time the event started running, and is always set by the
+	// service rather than by the sender.
+	Started *time.Time `json:"started,omitempty"`
+	// Duration is the time (in seconds) the event took from starting to
+	// finishing, and is always set by the service rather than by the sender.
+	Duration float64 `json:"duration,omitempty"`
+	// Heartbeat is the optional time (in seconds) within which the next update
+	// to the event is expected, after which the event is marked as stale.
+	Heartbeat int `json:"heartbeat,omitempty"`
+	// TTL is the optional time (in seconds) after the last update to the event
+	// when it is removed from the dashboard.
+	TTL int `json:"ttl,omitempty"`
+	// Removed is set by the service on the last update sent to clients for an
+	// event which has been removed from the dashboard, and is never stored.
+	Removed bool `json:"removed,omitempty"`
+}
+
+// Validate checks that the event has all the required fields set, and that all
+// fields are within the expected limits, returning `ValidationErrors` listing
+// every field which failed, or `nil` if the event is valid.
+func (e *Event) Validate() error {
+	var errs ValidationErrors
+
+	switch {
+	case e.ID == "":
+		errs = append(errs, NewValidationError("event-id", "must be provided"))
+	case len(e.ID) > maxIDLength:
+		errs = append(errs, NewValidationError("event-id", fmt.Sprintf("must be at most %d characters", maxIDLength)))
+	case !idPattern.MatchString(e.ID):
+		errs = append(errs, NewValidationError("event-id", "must only contain alphanumeric characters, '.', '_', ':', or '-'"))
+	}
+
+	switch {
+	case e.Status == "":
+		errs = append(errs, NewValidationError("status", "must be provided"))
+	case len(e.Status) > maxStatusLength:
+		errs = append(errs, NewValidationError("status", fmt.Sprintf("must be at most %d characters", maxStatusLength)))
+	case !statusPattern.MatchString(e.Status):
+		errs = append(errs, NewValidationError("status", "must only contain lowercase alphanumeric characters or '-'"))
+	case !IsStatus(e.Status):
+		errs = append(errs, NewValidationError("status", "must be one of "+strings.Join(Statuses, ", ")))
+	case Canonical(e.Status) == StatusStale:
+		errs = append(errs, NewValidationError("status", "can only be set by the service"))
+	}
+
+	if len(e.Message) > maxMessageLength {
+		errs = append(errs, NewValidationError("message", fmt.Sprintf("must be at most %d characters", maxMessageLength)))
+	}
+
+	if len(e.Source) > maxSourceLength {
+		errs = append(errs, NewValidationError("source", fmt.Sprintf("must be at most %d characters", maxSourceLength)))
+	}
+
+	switch {
+	case len(e.Group) > maxGroupLength:
+		errs = append(errs, NewValidationError("group", fmt.Sprintf("must be at most %d characters", maxGroupLength)))
+	case e.Group != "" && !groupPattern.MatchString(e.Group):
+		errs = append(errs, NewValidationError("group", "must be one or more names separated by '/'"))
+	}
+
+	if e.Heartbeat < 0 || e.Heartbeat > maxInterval {
+		errs = append(errs, NewValidationError("heartbeat", fmt.Sprintf("must be between 0 and %d seconds", maxInterval)))
+	}
+
+	if e.TTL < 0 || e.TTL > maxInterval {
+		errs = append(errs, NewValidationError("ttl", fmt.Sprintf("must be between 0 and %d seconds", maxInterval)))
+	}
+
+	errs = append(errs, validateLabels(e.Labels)...)
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// validateLabels checks that the number of labels, and each of the keys and
+// values, are within the expected limits.
+func validateLabels(labels map[string]string) ValidationErrors {
+	var errs ValidationErrors
+
+	if len(labels) > maxLabels {
+		errs = append(errs, NewValidationError("labels", fmt.Sprintf("must have at most %d labels", maxLabels)))
+	}
+
+	for key, value := range labels {
+		field := "labels." + key
+
+		if len(key) > maxLabelKeyLength || !labelPattern.MatchString(key) {
+			errs = append(errs, NewValidationError(field, "key must be a valid label name"))
+		}
+
+		if len(value) > maxLabelValueLength {
+			errs = append(errs, NewValidationError(field, fmt.Sprintf("value must be at most %d characters", maxLabelValueLength)))
+		}
+	}
+
+	return errs
+}
+
+// Normalise prepares the event for processing once it has been received by
+// setting the `Received` time to `now`, defaulting the `Timestamp` to it if the
+// sender did not provide one, and resolving the `Status` from any of the other
+// names commonly used for it.
+func (e *Event) Normalise(now time.Time) {
+	e.Received = now.UTC()
+	e.Removed = false
+
+	if e.Timestamp.IsZero() {
+		e.Timestamp = e.Received
+	} else {
+		e.Timestamp = e.Timestamp.UTC()
+	}
+
+	e.Status = strings.ToLower(e.Status)
+	if IsStatus(e.Status) {
+		e.Status = Canonical(e.Status)
+	}
+}
+
+// Clone returns a deep copy of the event so that it can be safely stored or
+// passed between goroutines without the original being modified.
+func (e *Event) Clone() *Event {
+	c := *e
+
+	if e.Started != nil {
+		started := *e.Started
+		c.Started = &started
+	}
+
+	if e.Labels != nil {
+		c.Labels = make(map[string]string, len(e.Labels))
+		for key, value := range e.Labels {
+			c.Labels[key] = value
+		}
+	}
+
+	return &c
+}
+
+// IdempotencyKey returns the default key used to identify repeated submissions
+// of the same update to the event, made from the ID and the `Timestamp` set by
+// the sender, or an empty string if the sender did not set a timestamp.
+func (e *Event) IdempotencyKey() string {
+	if e.Timestamp.IsZero() {
+		return ""
+	}
+
+	return e.ID + "@" + e.Timestamp.UTC().Format(time.RFC3339Nano)
+}
+
+// Digest returns the hex-encoded SHA-256 hash of the update to the event,
+// ignoring the time it was received, and the times set by the service, so that
+// the same update submitted more than once always has the same digest.
+func (e *Event) Digest() string {
+	c := e.Clone()
+	c.Timestamp = c.Timestamp.UTC()
+	c.Received = time.Time{}
+	c.Started, c.Duration = nil, 0
+
+	// Events always encode to JSON, and the keys of the labels are sorted when
+	// encoded, so the same update always produces the same document
+	data, _ := json.Marshal(c)
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Deadline returns the time by which the next update to the event is expected,
+// or the zero time if the event has no heartbeat.
+func (e *Event) Deadline() time.Time {
+	if e.Heartbeat <= 0 {
+		return time.Time{}
+	}
+
+	return e.Timestamp.Add(time.Duration(e.Heartbeat) * time.Second)
+}
+
+// Expires returns the time the event is removed from the dashboard, or the zero
+// time if the event has no TTL.
+func (e *Event) Expires() time.Time {
+	if e.TTL <= 0 {
+		return time.Time{}
+	}
+
+	return e.Timestamp.Add(time.Duration(e.TTL) * time.Second)
+}
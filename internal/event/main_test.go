@@ -0,0 +1,162 @@
+package event_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/event"
+)
+
+// TestValidateValid tests that a complete and valid event passes validation.
+func TestValidateValid(t *testing.T) {
+	t.Parallel()
+
+	e := &event.Event{
+		ID:      "this-is-a-test-message",
+		Status:  "pass",
+		Message: "This is a test message for the dashboard",
+		Source:  "github.com/n3tuk/dashboard",
+		Group:   "dashboard/development/web",
+		Labels:  map[string]string{"environment": "development"},
+	}
+
+	assert.NoError(t, e.Validate())
+}
+
+// TestValidateMissing tests that an event without the required fields fails
+// validation, reporting each of the missing fields.
+func TestValidateMissing(t *testing.T) {
+	t.Parallel()
+
+	e := &event.Event{}
+
+	err := e.Validate()
+	require.Error(t, err)
+
+	var errs event.ValidationErrors
+	require.ErrorAs(t, err, &errs)
+
+	fields := make([]string, 0, len(errs))
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+
+	assert.ElementsMatch(t, []string{"event-id", "status"}, fields)
+}
+
+// TestValidateInvalid tests that an event with fields outside of the expected
+// limits fails validation.
+func TestValidateInvalid(t *testing.T) {
+	t.Parallel()
+
+	e := &event.Event{
+		ID:      "-invalid id",
+		Status:  "pass",
+		Message: strings.Repeat("x", 5000),
+		Group:   "dashboard//web",
+		Labels:  map[string]string{"bad key": "value"},
+	}
+
+	err := e.Validate()
+
+	var errs event.ValidationErrors
+	require.True(t, errors.As(err, &errs))
+	assert.Len(t, errs, 4)
+}
+
+// TestValidateExpiry tests that events with a heartbeat or TTL outside of the
+// expected limits, or which are marked as stale by the sender, fail validation.
+func TestValidateExpiry(t *testing.T) {
+	t.Parallel()
+
+	e := &event.Event{
+		ID:        "this-is-a-test-message",
+		Status:    "stale",
+		Heartbeat: -1,
+		TTL:       100 * 24 * 60 * 60,
+	}
+
+	err := e.Validate()
+
+	var errs event.ValidationErrors
+	require.ErrorAs(t, err, &errs)
+
+	fields := make([]string, 0, len(errs))
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+
+	assert.ElementsMatch(t, []string{"status", "heartbeat", "ttl"}, fields)
+}
+
+// TestDeadline tests that the deadline for the next update, and the time the
+// event expires, are only set when the event has a heartbeat or TTL.
+func TestDeadline(t *testing.T) {
+	t.Parallel()
+
+	sent := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	e := &event.Event{Timestamp: sent}
+	assert.True(t, e.Deadline().IsZero())
+	assert.True(t, e.Expires().IsZero())
+
+	e.Heartbeat, e.TTL = 60, 3600
+	assert.Equal(t, sent.Add(time.Minute), e.Deadline())
+	assert.Equal(t, sent.Add(time.Hour), e.Expires())
+}
+
+// TestNormalise tests that the received time is always set, and the timestamp
+// is defaulted only when not already provided by the sender.
+func TestNormalise(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	sent := now.Add(-time.Minute)
+
+	e := &event.Event{Status: "PASS"}
+	e.Normalise(now)
+
+	assert.Equal(t, now, e.Received)
+	assert.Equal(t, now, e.Timestamp)
+	assert.Equal(t, "pass", e.Status)
+
+	e = &event.Event{Timestamp: sent}
+	e.Normalise(now)
+
+	assert.Equal(t, now, e.Received)
+	assert.Equal(t, sent, e.Timestamp)
+}
+
+// TestIdempotencyKey tests that the default idempotency key is only set when
+// the sender has set the timestamp of the update.
+func TestIdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	e := &event.Event{ID: "test"}
+	assert.Empty(t, e.IdempotencyKey())
+
+	e.Timestamp = time.Date(2024, 7, 1, 12, 0, 0, 500, time.UTC)
+	assert.Equal(t, "test@2024-07-01T12:00:00.0000005Z", e.IdempotencyKey())
+}
+
+// TestDigest tests that the digest of an update ignores the time it was
+// received, but not any other changes to the update.
+func TestDigest(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	e := &event.Event{ID: "test", Status: "pass", Labels: map[string]string{"a": "1", "b": "2"}, Timestamp: now}
+	c := e.Clone()
+	c.Received = now.Add(time.Minute)
+
+	assert.Equal(t, e.Digest(), c.Digest())
+
+	c.Labels["b"] = "3"
+	assert.NotEqual(t, e.Digest(), c.Digest())
+}
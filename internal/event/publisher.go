@@ -0,0 +1,24 @@
+package event
+
+type (
+	// Publisher is implemented by anything which needs to be notified once an
+	// event has been accepted and saved, such as to pass the event on to other
+	// dashboard instances, or to push it out to connected clients.
+	Publisher interface {
+		// Publish notifies the publisher of the event `e`. It must not block on
+		// slow or unavailable downstream services, and any errors should be
+		// handled or logged by the publisher itself.
+		Publish(e *Event)
+	}
+
+	// Publishers is a collection of `Publisher` which are all notified of each
+	// event in turn.
+	Publishers []Publisher
+)
+
+// Publish notifies each of the publishers in the collection of the event `e`.
+func (p Publishers) Publish(e *Event) {
+	for _, publisher := range p {
+		publisher.Publish(e)
+	}
+}
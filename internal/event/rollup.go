@@ -0,0 +1,84 @@
+package event
+
+import (
+	"slices"
+)
+
+const (
+	// RollupWorstOf is the rollup rule which gives a group the most severe
+	// status of any of its events.
+	RollupWorstOf = "worst-of"
+	// RollupMajority is the rollup rule which gives a group the status held by
+	// most of its events, using the most severe status for a tie.
+	RollupMajority = "majority"
+	// RollupAnyFail is the rollup rule which gives a group the `fail` status if
+	// any of its events have failed, otherwise the `pass` status.
+	RollupAnyFail = "any-fail"
+)
+
+var (
+	// Rollups is the list of all the rules which can be used to find the status
+	// of a group from the statuses of its events.
+	Rollups = []string{
+		RollupWorstOf,
+		RollupMajority,
+		RollupAnyFail,
+	}
+
+	// severity is the list of statuses in order of how much attention they
+	// need, from the least to the most severe.
+	severity = []string{
+		StatusPass,
+		StatusCancelled,
+		StatusQueued,
+		StatusRunning,
+		StatusUnknown,
+		StatusWarning,
+		StatusStale,
+		StatusFail,
+	}
+)
+
+// IsRollup checks whether `rule` is one of the rollup rules.
+func IsRollup(rule string) bool {
+	return slices.Contains(Rollups, rule)
+}
+
+// Rollup returns the status of a group from the `counts` of the events in the
+// group with each status, using the rollup `rule`, or `StatusUnknown` if there
+// are no events in the group.
+func Rollup(rule string, counts map[string]int) string {
+	canonical := map[string]int{}
+	for status, count := range counts {
+		canonical[Canonical(status)] += max(count, 0)
+	}
+
+	worst, majority := "", ""
+
+	// Check from the least to the most severe status, so the most severe one
+	// is used when statuses are held by the same number of events
+	for _, status := range severity {
+		if canonical[status] == 0 {
+			continue
+		}
+
+		worst = status
+
+		if majority == "" || canonical[status] >= canonical[majority] {
+			majority = status
+		}
+	}
+
+	switch {
+	case worst == "":
+		return StatusUnknown
+	case rule == RollupAnyFail && canonical[StatusFail] > 0:
+		return StatusFail
+	case rule == RollupAnyFail:
+		return StatusPass
+	case rule == RollupMajority:
+		return majority
+	default:
+		return worst
+	}
+}
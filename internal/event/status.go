@@ -0,0 +1,159 @@
+package event
+
+import (
+	"slices"
+	"time"
+)
+
+const (
+	// StatusQueued is the status of an event which is waiting to start.
+	StatusQueued = "queued"
+	// StatusRunning is the status of an event which has started, but not yet
+	// finished.
+	StatusRunning = "running"
+	// StatusPass is the status of an event which finished successfully.
+	StatusPass = "pass"
+	// StatusFail is the status of an event which finished unsuccessfully.
+	StatusFail = "fail"
+	// StatusWarning is the status of an event which finished, but with problems
+	// which should be looked at.
+	StatusWarning = "warning"
+	// StatusCancelled is the status of an event which was stopped before it
+	// could finish.
+	StatusCancelled = "cancelled"
+	// StatusUnknown is the status of an event whose state cannot be determined.
+	StatusUnknown = "unknown"
+	// StatusStale is the status set by the service on an event which has not
+	// been updated within its heartbeat.
+	StatusStale = "stale"
+)
+
+var (
+	// Statuses is the list of all the statuses an event can have.
+	Statuses = []string{
+		StatusQueued,
+		StatusRunning,
+		StatusPass,
+		StatusFail,
+		StatusWarning,
+		StatusCancelled,
+		StatusUnknown,
+		StatusStale,
+	}
+
+	// aliases maps the other names commonly used for the statuses by pipelines
+	// and monitoring systems to the status they represent.
+	aliases = map[string]string{
+		"pending":     StatusQueued,
+		"started":     StatusRunning,
+		"in-progress": StatusRunning,
+		"passed":      StatusPass,
+		"ok":          StatusPass,
+		"success":     StatusPass,
+		"succeeded":   StatusPass,
+		"resolved":    StatusPass,
+		"up":          StatusPass,
+		"failed":      StatusFail,
+		"failure":     StatusFail,
+		"error":       StatusFail,
+		"critical":    StatusFail,
+		"down":        StatusFail,
+		"warn":        StatusWarning,
+		"degraded":    StatusWarning,
+		"canceled":    StatusCancelled,
+		"aborted":     StatusCancelled,
+	}
+
+	// transitions maps each status to the statuses the event can then change
+	// to, where each of the finished statuses can only be repeated, unless the
+	// event is reopened, and an unknown or stale status can change to any other.
+	transitions = map[string][]string{
+		StatusQueued: {
+			StatusQueued,
+			StatusRunning,
+			StatusPass,
+			StatusFail,
+			StatusWarning,
+			StatusCancelled,
+			StatusUnknown,
+		},
+		StatusRunning: {
+			StatusRunning,
+			StatusPass,
+			StatusFail,
+			StatusWarning,
+			StatusCancelled,
+			StatusUnknown,
+		},
+		StatusPass:      {StatusPass},
+		StatusFail:      {StatusFail},
+		StatusWarning:   {StatusWarning},
+		StatusCancelled: {StatusCancelled},
+		StatusUnknown:   Statuses,
+		StatusStale:     Statuses,
+	}
+)
+
+// Canonical returns the status for the `status` given, resolving any of the
+// other names commonly used for it, or `StatusUnknown` if it is not known.
+func Canonical(status string) string {
+	if slices.Contains(Statuses, status) {
+		return status
+	}
+
+	if canonical, ok := aliases[status]; ok {
+		return canonical
+	}
+
+	return StatusUnknown
+}
+
+// IsStatus checks whether `status` is one of the statuses, or one of the other
+// names commonly used for them.
+func IsStatus(status string) bool {
+	_, ok := aliases[status]
+
+	return ok || slices.Contains(Statuses, status)
+}
+
+// IsFinished checks whether `status` is one of the statuses for an event which
+// has finished, and so cannot change again unless the event is reopened.
+func IsFinished(status string) bool {
+	switch Canonical(status) {
+	case StatusPass, StatusFail, StatusWarning, StatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// Transition checks that the status of the event can change from the status
+// of the `current` state of the event (or nil for a new event), returning a
+// `TransitionError` if not, and then sets the time the event started, and the
+// time it took to finish, from the current state and this update.
+func (e *Event) Transition(current *Event) error {
+	e.Started, e.Duration = nil, 0
+
+	if current != nil && !e.Reopen {
+		from := Canonical(current.Status)
+		if !slices.Contains(transitions[from], e.Status) {
+			return NewTransitionError(current.Status, e.Status)
+		}
+
+		if current.Started != nil {
+			started := *current.Started
+			e.Started = &started
+		}
+	}
+
+	if e.Status == StatusRunning && e.Started == nil {
+		started := e.Timestamp
+		e.Started = &started
+	}
+
+	if IsFinished(e.Status) && e.Started != nil {
+		e.Duration = e.Timestamp.Sub(*e.Started).Round(time.Millisecond).Seconds()
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package event_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/event"
+)
+
+// TestCanonical tests that the other names for the statuses are resolved, and
+// any other status is unknown.
+func TestCanonical(t *testing.T) {
+	t.Parallel()
+
+	for status, expected := range map[string]string{
+		"pass":        event.StatusPass,
+		"success":     event.StatusPass,
+		"failed":      event.StatusFail,
+		"in-progress": event.StatusRunning,
+		"canceled":    event.StatusCancelled,
+		"custom":      event.StatusUnknown,
+	} {
+		assert.Equal(t, expected, event.Canonical(status), status)
+	}
+
+	assert.True(t, event.IsStatus("success"))
+	assert.False(t, event.IsStatus("custom"))
+	assert.True(t, event.IsFinished("failed"))
+	assert.False(t, event.IsFinished("running"))
+}
+
+// TestTransition tests that the status of an event can only change to the
+// allowed statuses, unless the event is reopened.
+func TestTransition(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		from, to string
+		reopen   bool
+		allowed  bool
+	}{
+		{event.StatusQueued, event.StatusRunning, false, true},
+		{event.StatusQueued, event.StatusPass, false, true},
+		{event.StatusRunning, event.StatusFail, false, true},
+		{event.StatusRunning, event.StatusQueued, false, false},
+		{event.StatusPass, event.StatusPass, false, true},
+		{event.StatusPass, event.StatusRunning, false, false},
+		{event.StatusPass, event.StatusFail, false, false},
+		{event.StatusPass, event.StatusRunning, true, true},
+		{event.StatusUnknown, event.StatusPass, false, true},
+		{"custom", event.StatusRunning, false, true},
+	} {
+		current := &event.Event{ID: "test", Status: test.from}
+		e := &event.Event{ID: "test", Status: test.to, Reopen: test.reopen}
+
+		err := e.Transition(current)
+		if test.allowed {
+			require.NoError(t, err, "%s to %s", test.from, test.to)
+
+			continue
+		}
+
+		require.ErrorIs(t, err, event.ErrInvalidTransition, "%s to %s", test.from, test.to)
+
+		var transition *event.TransitionError
+		require.ErrorAs(t, err, &transition)
+		assert.Equal(t, test.from, transition.From)
+		assert.Equal(t, test.to, transition.To)
+	}
+}
+
+// TestTransitionDuration tests that the time the event started is kept from
+// the current state, and the duration is set once it has finished.
+func TestTransitionDuration(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	queued := &event.Event{ID: "test", Status: event.StatusQueued, Timestamp: start.Add(-time.Minute)}
+	require.NoError(t, queued.Transition(nil))
+	assert.Nil(t, queued.Started)
+
+	running := &event.Event{ID: "test", Status: event.StatusRunning, Timestamp: start}
+	require.NoError(t, running.Transition(queued))
+	require.NotNil(t, running.Started)
+	assert.Equal(t, start, *running.Started)
+
+	still := &event.Event{ID: "test", Status: event.StatusRunning, Timestamp: start.Add(time.Minute)}
+	require.NoError(t, still.Transition(running))
+	assert.Equal(t, start, *still.Started)
+
+	pass := &event.Event{ID: "test", Status: event.StatusPass, Timestamp: start.Add(90 * time.Second)}
+	require.NoError(t, pass.Transition(still))
+	assert.Equal(t, start, *pass.Started)
+	assert.InDelta(t, 90.0, pass.Duration, 0)
+
+	// Reopening the event starts it again
+	reopened := &event.Event{ID: "test", Status: event.StatusRunning, Timestamp: start.Add(time.Hour), Reopen: true}
+	require.NoError(t, reopened.Transition(pass))
+	assert.Equal(t, start.Add(time.Hour), *reopened.Started)
+	assert.Zero(t, reopened.Duration)
+}
+
+// TestRollup tests that the status of a group is found from the statuses of
+// its events using each of the rollup rules.
+func TestRollup(t *testing.T) {
+	t.Parallel()
+
+	counts := map[string]int{
+		event.StatusPass:    3,
+		event.StatusRunning: 3,
+		"failed":            1,
+	}
+
+	assert.Equal(t, event.StatusFail, event.Rollup(event.RollupWorstOf, counts))
+	assert.Equal(t, event.StatusRunning, event.Rollup(event.RollupMajority, counts))
+	assert.Equal(t, event.StatusFail, event.Rollup(event.RollupAnyFail, counts))
+
+	counts = map[string]int{event.StatusPass: 2, event.StatusWarning: 1}
+
+	assert.Equal(t, event.StatusWarning, event.Rollup(event.RollupWorstOf, counts))
+	assert.Equal(t, event.StatusPass, event.Rollup(event.RollupMajority, counts))
+	assert.Equal(t, event.StatusPass, event.Rollup(event.RollupAnyFail, counts))
+
+	assert.Equal(t, event.StatusUnknown, event.Rollup(event.RollupWorstOf, map[string]int{}))
+	assert.True(t, event.IsRollup(event.RollupMajority))
+	assert.False(t, event.IsRollup("best-of"))
+}
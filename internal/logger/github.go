@@ -0,0 +1,120 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// workflowEscapes replaces the characters which cannot be used in the message
+// of a GitHub Actions workflow command.
+var workflowEscapes = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+
+// propertyEscapes replaces the characters which cannot be used in the
+// properties of a GitHub Actions workflow command.
+var propertyEscapes = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+
+// WorkflowHandler wraps a `slog.Handler` so that, alongside the normal log
+// output, warnings and errors are also written as GitHub Actions workflow
+// commands, and so shown as annotations on the workflow run.
+type WorkflowHandler struct {
+	slog.Handler
+
+	out   io.Writer
+	mutex *sync.Mutex
+}
+
+// NewWorkflowHandler creates a new `WorkflowHandler` wrapping the `handler`,
+// writing the workflow commands to `out`.
+func NewWorkflowHandler(handler slog.Handler, out io.Writer) *WorkflowHandler {
+	return &WorkflowHandler{
+		Handler: handler,
+		out:     out,
+		mutex:   &sync.Mutex{},
+	}
+}
+
+// StartGitHub wraps the default logger, once set up by `Start`, so that
+// warnings and errors are also written as GitHub Actions workflow commands to
+// `out`.
+func StartGitHub(out io.Writer) {
+	slog.SetDefault(slog.New(NewWorkflowHandler(slog.Default().Handler(), out)))
+}
+
+// Handle passes the record `r` to the wrapped handler, and then writes it as a
+// workflow command if it is a warning or an error.
+func (h *WorkflowHandler) Handle(ctx context.Context, r slog.Record) error {
+	if err := h.Handler.Handle(ctx, r); err != nil {
+		return err
+	}
+
+	command := ""
+
+	switch {
+	case r.Level >= slog.LevelError:
+		command = "error"
+	case r.Level >= slog.LevelWarn:
+		command = "warning"
+	default:
+		return nil
+	}
+
+	details := []string{}
+	r.Attrs(func(a slog.Attr) bool {
+		details = append(details, flatten("", a)...)
+
+		return true
+	})
+
+	// The message is used as the title, so only repeat it if there are no
+	// details to show instead
+	message := r.Message
+	if len(details) > 0 {
+		message = strings.Join(details, " ")
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	_, err := fmt.Fprintf(h.out, "::%s title=%s::%s\n",
+		command, propertyEscapes.Replace(r.Message), workflowEscapes.Replace(message))
+
+	return err
+}
+
+// WithAttrs returns a new `WorkflowHandler` wrapping the handler with the
+// `attrs` added.
+func (h *WorkflowHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &WorkflowHandler{Handler: h.Handler.WithAttrs(attrs), out: h.out, mutex: h.mutex}
+}
+
+// WithGroup returns a new `WorkflowHandler` wrapping the handler with the
+// group `name` added.
+func (h *WorkflowHandler) WithGroup(name string) slog.Handler {
+	return &WorkflowHandler{Handler: h.Handler.WithGroup(name), out: h.out, mutex: h.mutex}
+}
+
+// flatten returns the attribute `a`, or each of the attributes within it if
+// it is a group, as `key=value` strings, with the keys of groups prefixed by
+// the `prefix`.
+func flatten(prefix string, a slog.Attr) []string {
+	key := a.Key
+	if prefix != "" {
+		key = prefix + "." + key
+	}
+
+	value := a.Value.Resolve()
+	if value.Kind() != slog.KindGroup {
+		return []string{key + "=" + value.String()}
+	}
+
+	details := []string{}
+	for _, attr := range value.Group() {
+		details = append(details, flatten(key, attr)...)
+	}
+
+	return details
+}
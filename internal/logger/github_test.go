@@ -0,0 +1,32 @@
+package logger_test
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/n3tuk/dashboard/internal/logger"
+)
+
+// TestWorkflowHandler tests that only warnings and errors are written as
+// workflow commands, with their attributes escaped as needed.
+func TestWorkflowHandler(t *testing.T) {
+	t.Parallel()
+
+	out := &bytes.Buffer{}
+	log := slog.New(logger.NewWorkflowHandler(slog.NewTextHandler(io.Discard, nil), out))
+
+	log.Info("Sending event")
+	log.Warn("Retrying request", slog.Group("retry", slog.Int("attempt", 1)))
+	log.Error("Failed: event", slog.String("message", "100%\nrejected"))
+	log.Warn("No details")
+
+	assert.Equal(t,
+		"::warning title=Retrying request::retry.attempt=1\n"+
+			"::error title=Failed%3A event::message=100%25%0Arejected\n"+
+			"::warning title=No details::No details\n",
+		out.String())
+}
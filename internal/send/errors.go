@@ -0,0 +1,115 @@
+package send
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// inProgressStatus is the status returned by the dashboard endpoint when the
+// original request with the same idempotency key is still being processed.
+const inProgressStatus = "request-in-progress"
+
+type (
+	// RequestError represents a failure to build or deliver the request to the
+	// dashboard endpoint, such as the endpoint being unreachable, rather than an
+	// error response being returned from it, which is handled by
+	// `ResponseError`.
+	RequestError struct {
+		endpoint  string
+		message   string
+		err       error
+		retryable bool
+	}
+
+	// ResponseError represents that the dashboard endpoint received the request
+	// but responded with a non-2xx status code, and so the event was not
+	// accepted for processing.
+	ResponseError struct {
+		Code    int
+		Status  string
+		Message string
+		// RetryAfter is the time the dashboard endpoint asked the client to wait
+		// before making the request again, if set.
+		RetryAfter time.Duration
+	}
+)
+
+// Retryable checks whether the request which failed with `err` could succeed
+// if it was made again, such as when the endpoint could not be reached, or
+// responded with a 5xx or 429 (Too Many Requests) status code, or while the
+// original request is still in progress.
+func Retryable(err error) bool {
+	var retryable interface{ Retryable() bool }
+
+	return errors.As(err, &retryable) && retryable.Retryable()
+}
+
+// Error returns the error message for this error.
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%s: %s", e.message, e.err)
+}
+
+// Unwrap returns the underlying error for this error.
+func (e *RequestError) Unwrap() error {
+	return e.err
+}
+
+// Retryable checks whether the request could succeed if it was made again,
+// which is only the case if it could not be delivered to the endpoint.
+func (e *RequestError) Retryable() bool {
+	return e.retryable
+}
+
+// NewRequestError creates a new `RequestError` error type with the provided
+// `endpoint` and `message` about the error, and the `err` from the upstream
+// library.
+func NewRequestError(endpoint, message string, err error) error {
+	return &RequestError{
+		endpoint: endpoint,
+		message:  message,
+		err:      err,
+	}
+}
+
+// NewDeliveryError creates a new `RequestError` error type, as with
+// `NewRequestError`, for a request which could not be delivered to the
+// endpoint, and so can be made again.
+func NewDeliveryError(endpoint, message string, err error) error {
+	return &RequestError{
+		endpoint:  endpoint,
+		message:   message,
+		err:       err,
+		retryable: true,
+	}
+}
+
+// Error returns the error message for this error.
+func (e *ResponseError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("event rejected by the dashboard (%d)", e.Code)
+	}
+
+	return fmt.Sprintf("event rejected by the dashboard (%d %s): %s", e.Code, e.Status, e.Message)
+}
+
+// Retryable checks whether the request could succeed if it was made again,
+// which is the case for 5xx and 429 (Too Many Requests) status codes, and for
+// 409 (Conflict) while the original request with the same idempotency key is
+// still in progress.
+func (e *ResponseError) Retryable() bool {
+	return e.Code >= http.StatusInternalServerError || e.Code == http.StatusTooManyRequests ||
+		(e.Code == http.StatusConflict && e.Status == inProgressStatus)
+}
+
+// NewResponseError creates a new `ResponseError` error type with the `code`
+// returned by the dashboard endpoint, along with the `status` and `message`
+// from the body of the response, if provided.
+func NewResponseError(code int, status, message string) error {
+	return &ResponseError{
+		Code:    code,
+		Status:  status,
+		Message: message,
+	}
+}
@@ -0,0 +1,167 @@
+package send
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/n3tuk/dashboard/internal/config"
+	"github.com/n3tuk/dashboard/internal/event"
+)
+
+const (
+	// Stdin is the name of the file used to read the events from the standard
+	// input.
+	Stdin = "-"
+	// eventSchema is the name of the embedded schema each event in an input
+	// file is validated against.
+	eventSchema = "event.json"
+)
+
+var (
+	// ErrInvalidFile is returned when the input file cannot be parsed as a JSON
+	// or YAML document holding one or more events.
+	ErrInvalidFile = errors.New("invalid events file")
+	// ErrNoEvents is returned when the input file does not hold any events.
+	ErrNoEvents = errors.New("no events found in the file")
+)
+
+// ReadFile reads the events from the `file`, or from `stdin` if the `file` is
+// `-`, as JSON or YAML, holding either a single event, a list of events, or
+// multiple YAML documents of either, returning each event as a document which
+// can be passed to `Decode`.
+func ReadFile(file string, stdin io.Reader) ([]map[string]any, error) {
+	var (
+		data []byte
+		err  error
+	)
+
+	if file == Stdin {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the events from %s: %w", name(file), err)
+	}
+
+	// JSON is a subset of YAML, so both can be parsed as YAML documents
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	documents := []map[string]any{}
+
+	for {
+		var document any
+
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidFile, name(file), err)
+		}
+
+		list, ok := document.([]any)
+		if !ok {
+			list = []any{document}
+		}
+
+		for _, item := range list {
+			if item == nil {
+				// Skip empty documents, such as from a leading `---`
+				continue
+			}
+
+			e, ok := item.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s: event %d is not an object", ErrInvalidFile, name(file), len(documents)+1)
+			}
+
+			documents = append(documents, e)
+		}
+	}
+
+	if len(documents) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoEvents, name(file))
+	}
+
+	return documents, nil
+}
+
+// Decode builds the events from the `documents`, with the fields set in the
+// `overrides` replacing those in each document (merging the labels), and, if
+// the documents were read from the `file`, checking each of them against the
+// schema for events first.
+func Decode(documents []map[string]any, overrides map[string]any, file string) ([]*event.Event, error) {
+	events := make([]*event.Event, 0, len(documents))
+
+	for i, document := range documents {
+		merged := Merge(document, overrides)
+
+		if file != "" {
+			source := name(file)
+			if len(documents) > 1 {
+				source = fmt.Sprintf("%s (event %d)", source, i+1)
+			}
+
+			if err := config.ValidateDocument(eventSchema, source, merged); err != nil {
+				return nil, err
+			}
+		}
+
+		data, err := json.Marshal(merged)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode event %d: %w", i+1, err)
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+
+		e := &event.Event{}
+		if err := decoder.Decode(e); err != nil {
+			return nil, fmt.Errorf("unable to decode event %d: %w", i+1, err)
+		}
+
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+// Merge returns a copy of the `document` with the fields set in `overrides`
+// replacing those in the document, except for objects, such as the labels,
+// which are merged, with the values from `overrides` replacing any in the
+// document.
+func Merge(document, overrides map[string]any) map[string]any {
+	merged := make(map[string]any, len(document)+len(overrides))
+	maps.Copy(merged, document)
+
+	for key, value := range overrides {
+		override, ok := value.(map[string]any)
+		if current, isMap := merged[key].(map[string]any); ok && isMap {
+			combined := maps.Clone(current)
+			maps.Copy(combined, override)
+			value = combined
+		}
+
+		merged[key] = value
+	}
+
+	return merged
+}
+
+// name returns the name of the `file` used in errors.
+func name(file string) string {
+	if file == Stdin {
+		return "(stdin)"
+	}
+
+	return file
+}
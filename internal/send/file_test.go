@@ -0,0 +1,111 @@
+package send_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/config"
+	"github.com/n3tuk/dashboard/internal/send"
+)
+
+// TestReadFile tests that events can be read from JSON and YAML files, holding
+// either single events, lists of events, or multiple YAML documents.
+func TestReadFile(t *testing.T) {
+	t.Parallel()
+
+	documents, err := send.ReadFile("testdata/events.yaml", nil)
+	require.NoError(t, err)
+	require.Len(t, documents, 3)
+	assert.Equal(t, "deploy", documents[0]["event-id"])
+	assert.Equal(t, "test", documents[2]["event-id"])
+
+	documents, err = send.ReadFile("testdata/events.json", nil)
+	require.NoError(t, err)
+	require.Len(t, documents, 2)
+	assert.Equal(t, "build", documents[1]["event-id"])
+
+	documents, err = send.ReadFile(send.Stdin, strings.NewReader(`{"event-id":"stdin","status":"pass"}`))
+	require.NoError(t, err)
+	require.Len(t, documents, 1)
+	assert.Equal(t, "stdin", documents[0]["event-id"])
+}
+
+// TestReadFileErrors tests that files which cannot be read, or do not hold
+// any events, are rejected.
+func TestReadFileErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := send.ReadFile("testdata/missing.yaml", nil)
+	require.Error(t, err)
+
+	for input, expected := range map[string]error{
+		"":                 send.ErrNoEvents,
+		"---\n":            send.ErrNoEvents,
+		"- one\n- two\n":   send.ErrInvalidFile,
+		"{\"event-id\": [": send.ErrInvalidFile,
+	} {
+		_, err := send.ReadFile(send.Stdin, strings.NewReader(input))
+		require.ErrorIs(t, err, expected, input)
+	}
+}
+
+// TestDecode tests that the events are built from the documents, with the
+// overrides replacing the fields from the file, and merging the labels.
+func TestDecode(t *testing.T) {
+	t.Parallel()
+
+	documents, err := send.ReadFile("testdata/events.yaml", nil)
+	require.NoError(t, err)
+
+	events, err := send.Decode(documents, map[string]any{
+		"source": "ci",
+		"labels": map[string]any{"pipeline": "build"},
+	}, "testdata/events.yaml")
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+
+	assert.Equal(t, "deploy", events[0].ID)
+	assert.Equal(t, "service/production", events[0].Group)
+	assert.Equal(t, time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC), events[0].Timestamp)
+	assert.Equal(t, map[string]string{"environment": "production", "pipeline": "build"}, events[0].Labels)
+
+	for _, e := range events {
+		assert.Equal(t, "ci", e.Source)
+		assert.Equal(t, "build", e.Labels["pipeline"])
+	}
+
+	assert.Equal(t, "Two tests failed", events[2].Message)
+}
+
+// TestDecodeInvalid tests that the events from a file are checked against the
+// schema for events, listing each of the fields which do not match, unless
+// they are fixed by the overrides.
+func TestDecodeInvalid(t *testing.T) {
+	t.Parallel()
+
+	documents, err := send.ReadFile("testdata/invalid.yaml", nil)
+	require.NoError(t, err)
+
+	_, err = send.Decode(documents, nil, "testdata/invalid.yaml")
+
+	var invalid *config.ValidationError
+	require.ErrorAs(t, err, &invalid)
+
+	paths := make([]string, 0, len(invalid.Violations))
+	for _, v := range invalid.Violations {
+		paths = append(paths, v.Path)
+	}
+
+	assert.Equal(t, []string{"labels.version", "mesage", "status"}, paths)
+
+	delete(documents[0], "mesage")
+	delete(documents[0], "labels")
+
+	events, err := send.Decode(documents, map[string]any{"status": "pass"}, "testdata/invalid.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "pass", events[0].Status)
+}
@@ -0,0 +1,198 @@
+package send
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/n3tuk/dashboard/internal/event"
+)
+
+const (
+	// GitHubSource is the source set on the events sent from GitHub Actions.
+	GitHubSource = "github-actions"
+	// gitHubServer is the default URL of the GitHub server, if not set in the
+	// environment.
+	gitHubServer = "https://github.com"
+	// gitHubGroup is the top-level group for the events sent from GitHub
+	// Actions.
+	gitHubGroup = "github"
+)
+
+var (
+	// invalidIDCharacters matches the characters which cannot be used in the ID
+	// of an event.
+	invalidIDCharacters = regexp.MustCompile(`[^a-zA-Z0-9._:-]+`)
+	// invalidGroupCharacters matches the characters which cannot be used in a
+	// part of the group of an event.
+	invalidGroupCharacters = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+)
+
+// GitHubActions checks whether the application is running within a GitHub
+// Actions workflow, based on the `GITHUB_ACTIONS` environment variable found
+// through `getenv`.
+func GitHubActions(getenv func(string) string) bool {
+	return getenv("GITHUB_ACTIONS") == "true"
+}
+
+// GitHubFields returns the fields for an event sent from a GitHub Actions
+// workflow, built from the `GITHUB_*` environment variables found through
+// `getenv`, with the ID identifying the job within the workflow run, the group
+// made from the repository and the workflow, and the details of the run and
+// the commit added as labels.
+func GitHubFields(getenv func(string) string) map[string]any {
+	repository := getenv("GITHUB_REPOSITORY")
+	run := getenv("GITHUB_RUN_ID")
+	job := getenv("GITHUB_JOB")
+	sha := getenv("GITHUB_SHA")
+
+	server := strings.TrimRight(getenv("GITHUB_SERVER_URL"), "/")
+	if server == "" {
+		server = gitHubServer
+	}
+
+	fields := map[string]any{"source": GitHubSource}
+
+	if repository != "" && run != "" && job != "" {
+		fields["event-id"] = strings.Join([]string{
+			clean(invalidIDCharacters, repository),
+			clean(invalidIDCharacters, run),
+			clean(invalidIDCharacters, job),
+		}, ":")
+	}
+
+	parts := []string{gitHubGroup}
+	for _, part := range append(strings.Split(repository, "/"), getenv("GITHUB_WORKFLOW")) {
+		if part = clean(invalidGroupCharacters, part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	if len(parts) > 1 {
+		fields["group"] = strings.Join(parts, "/")
+	}
+
+	labels := map[string]any{}
+	for key, value := range map[string]string{
+		"github.actor":    getenv("GITHUB_ACTOR"),
+		"github.ref":      getenv("GITHUB_REF"),
+		"github.sha":      sha,
+		"github.workflow": getenv("GITHUB_WORKFLOW"),
+		"github.job":      job,
+	} {
+		if value != "" {
+			labels[key] = value
+		}
+	}
+
+	if repository != "" && run != "" {
+		labels["github.run-url"] = fmt.Sprintf("%s/%s/actions/runs/%s", server, repository, run)
+	}
+
+	if repository != "" && sha != "" {
+		labels["github.commit-url"] = fmt.Sprintf("%s/%s/commit/%s", server, repository, sha)
+	}
+
+	if len(labels) > 0 {
+		fields["labels"] = labels
+	}
+
+	// Re-running a job which has already finished needs the event reopened, so
+	// that its status can change again
+	if attempt, err := strconv.Atoi(getenv("GITHUB_RUN_ATTEMPT")); err == nil && attempt > 1 {
+		fields["reopen"] = true
+	}
+
+	return fields
+}
+
+// EventURL returns the URL of the page for the event `e` on the dashboard,
+// based on the `endpoint-uri` setting in the configuration.
+func EventURL(e *event.Event) string {
+	uri, err := url.JoinPath(strings.TrimRight(viper.GetString("endpoint-uri"), "/"), "events", e.ID)
+	if err != nil {
+		return ""
+	}
+
+	return uri
+}
+
+// WriteGitHubOutputs writes the ID and the URL of the first of the `events`,
+// and the URLs of all the `events`, as outputs of the step in the GitHub
+// Actions workflow, and adds a summary of the `events` sent, and the `err`
+// from sending them if set, to the summary of the step, using the files found
+// through `getenv`, which are skipped if not set.
+func WriteGitHubOutputs(getenv func(string) string, events []*event.Event, err error) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	urls := make([]string, len(events))
+	for i, e := range events {
+		urls[i] = EventURL(e)
+	}
+
+	list, jsonErr := json.Marshal(urls)
+	if jsonErr != nil {
+		return fmt.Errorf("unable to encode the event URLs: %w", jsonErr)
+	}
+
+	outputs := fmt.Sprintf("event-id=%s\nevent-url=%s\nevent-urls=%s\n", events[0].ID, urls[0], list)
+
+	summary := &strings.Builder{}
+	summary.WriteString("### Dashboard Events\n\n")
+	summary.WriteString("| Event | Status | Group |\n| --- | --- | --- |\n")
+
+	for i, e := range events {
+		fmt.Fprintf(summary, "| [%s](%s) | `%s` | %s |\n", markdown(e.ID), urls[i], e.Status, markdown(e.Group))
+	}
+
+	if err != nil {
+		fmt.Fprintf(summary, "\n> [!CAUTION]\n> The events could not be sent: %s\n", markdown(err.Error()))
+	}
+
+	return errors.Join(
+		appendFile(getenv("GITHUB_OUTPUT"), outputs),
+		appendFile(getenv("GITHUB_STEP_SUMMARY"), summary.String()+"\n"),
+	)
+}
+
+// clean replaces each run of characters matched by `invalid` in `value` with a
+// `-`, removing any which are left at the start or the end.
+func clean(invalid *regexp.Regexp, value string) string {
+	return strings.Trim(invalid.ReplaceAllString(value, "-"), "._:-")
+}
+
+// markdown escapes the characters in `value` which would break the layout of
+// a table in the summary.
+func markdown(value string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ", "[", `\[`, "]", `\]`).Replace(value)
+}
+
+// appendFile adds the `content` to the end of the `file`, which is skipped if
+// the name of the file is empty.
+func appendFile(file, content string) error {
+	if file == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %w", file, err)
+	}
+
+	if _, err := f.WriteString(content); err != nil {
+		_ = f.Close()
+
+		return fmt.Errorf("unable to write to %s: %w", file, err)
+	}
+
+	return f.Close()
+}
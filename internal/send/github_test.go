@@ -0,0 +1,104 @@
+//nolint:paralleltest // these tests cannot operate in parallel
+package send_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/send"
+)
+
+// environment returns a lookup for the environment variables in `values`, in
+// place of `os.Getenv`.
+func environment(values map[string]string) func(string) string {
+	return func(name string) string {
+		return values[name]
+	}
+}
+
+// TestGitHubFields tests that the fields of an event are built from the
+// environment of a GitHub Actions workflow, and that the event is valid.
+func TestGitHubFields(t *testing.T) {
+	getenv := environment(map[string]string{
+		"GITHUB_ACTIONS":     "true",
+		"GITHUB_REPOSITORY":  "n3tuk/dashboard",
+		"GITHUB_RUN_ID":      "1234",
+		"GITHUB_RUN_ATTEMPT": "2",
+		"GITHUB_JOB":         "build",
+		"GITHUB_WORKFLOW":    "CI / Build",
+		"GITHUB_SHA":         "0a1b2c3d",
+		"GITHUB_REF":         "refs/heads/main",
+		"GITHUB_ACTOR":       "octocat",
+	})
+
+	assert.True(t, send.GitHubActions(getenv))
+
+	fields := send.GitHubFields(getenv)
+	assert.Equal(t, "n3tuk-dashboard:1234:build", fields["event-id"])
+	assert.Equal(t, "github/n3tuk/dashboard/CI-Build", fields["group"])
+	assert.Equal(t, true, fields["reopen"])
+
+	// Fields set in the document, including labels, replace those from GitHub
+	document := send.Merge(fields, map[string]any{
+		"status": "pass",
+		"labels": map[string]any{"github.actor": "someone"},
+	})
+
+	events, err := send.Decode([]map[string]any{document}, nil, "")
+	require.NoError(t, err)
+	require.NoError(t, events[0].Validate())
+
+	labels := events[0].Labels
+	assert.Equal(t, "someone", labels["github.actor"])
+	assert.Equal(t, "https://github.com/n3tuk/dashboard/actions/runs/1234", labels["github.run-url"])
+	assert.Equal(t, "https://github.com/n3tuk/dashboard/commit/0a1b2c3d", labels["github.commit-url"])
+
+	assert.False(t, send.GitHubActions(environment(nil)))
+	assert.Equal(t, map[string]any{"source": send.GitHubSource}, send.GitHubFields(environment(nil)))
+}
+
+// TestWriteGitHubOutputs tests that the URLs of the events are written as
+// outputs of the step, and that the events are added to its summary.
+func TestWriteGitHubOutputs(t *testing.T) {
+	directory := t.TempDir()
+	output := filepath.Join(directory, "output")
+	summary := filepath.Join(directory, "summary")
+
+	viper.Reset()
+	viper.Set("endpoint-uri", "https://dashboard.example.com/")
+
+	getenv := environment(map[string]string{
+		"GITHUB_OUTPUT":       output,
+		"GITHUB_STEP_SUMMARY": summary,
+	})
+
+	events := []*event.Event{
+		{ID: "one", Status: "pass", Group: "jobs"},
+		{ID: "two", Status: "fail"},
+	}
+
+	require.NoError(t, send.WriteGitHubOutputs(getenv, events, errors.New("events rejected")))
+
+	data, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t,
+		"event-id=one\n"+
+			"event-url=https://dashboard.example.com/events/one\n"+
+			`event-urls=["https://dashboard.example.com/events/one","https://dashboard.example.com/events/two"]`+"\n",
+		string(data))
+
+	data, err = os.ReadFile(summary)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "| [one](https://dashboard.example.com/events/one) | `pass` | jobs |")
+	assert.Contains(t, string(data), "events rejected")
+
+	// Without the files set, nothing is written
+	require.NoError(t, send.WriteGitHubOutputs(environment(nil), events, nil))
+}
@@ -1,10 +1,455 @@
+// The `send` package provides the client for the dashboard web service,
+// building the request for an event and delivering it to the configured
+// endpoint, and reporting back on whether or not it was accepted.
 package send
 
-import "log/slog"
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
-func Run() error {
-	slog.Info("Sending dashboard event")
-	slog.Debug("Debugging enabled")
+	"github.com/spf13/viper"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/signature"
+)
+
+const (
+	// eventsPath is the path on the endpoint to which events are submitted.
+	eventsPath = "/api/v1/events"
+	// batchPath is the path on the endpoint to which batches of events are
+	// submitted.
+	batchPath = "/api/v1/events:batch"
+	// maxResponseSize is the maximum size of the response body which will be
+	// read back from the endpoint.
+	maxResponseSize = 1 << 20
+	// idempotencyHeader is the header used to identify repeated submissions of
+	// the same event, so that the endpoint only processes it once.
+	idempotencyHeader = "Idempotency-Key"
+)
+
+var (
+	// ErrMissingEndpoint is returned when no endpoint has been configured.
+	ErrMissingEndpoint = errors.New("no endpoint-uri has been configured")
+	// ErrEventsRejected is returned when any of the events in a batch were
+	// rejected by the dashboard endpoint.
+	ErrEventsRejected = errors.New("events rejected by the dashboard")
+)
+
+// Client provides the connection details and the HTTP client needed to send
+// events to the dashboard endpoint.
+type Client struct {
+	endpoint string
+	apiKey   string
+	sign     bool
+	agent    string
+	retry    Retry
+	client   *http.Client
+}
+
+// Response represents the body of the response returned from the dashboard
+// endpoint after the event has been submitted.
+type Response struct {
+	Code    int          `json:"code"`
+	Status  string       `json:"status"`
+	Message string       `json:"message"`
+	Event   *event.Event `json:"event,omitempty"`
+}
+
+// BatchResponse represents the body of the response returned from the
+// dashboard endpoint after a batch of events has been submitted.
+type BatchResponse struct {
+	Response
+
+	// Accepted is the number of events in the batch which were accepted.
+	Accepted int `json:"accepted"`
+	// Rejected is the number of events in the batch which were rejected.
+	Rejected int `json:"rejected"`
+	// Results is the outcome for each event, in the order they were sent.
+	Results []*Result `json:"results"`
+}
+
+// Result represents the outcome for a single event within a batch of events.
+type Result struct {
+	Response
+
+	// Index is the position of the event in the batch, starting from zero.
+	Index int `json:"index"`
+	// ID is the ID of the event, if it could be parsed by the endpoint.
+	ID string `json:"event-id,omitempty"`
+}
+
+// decoder is implemented by the responses from the dashboard endpoint, giving
+// access to the fields common to all responses.
+type decoder interface {
+	base() *Response
+}
+
+// base returns the fields common to all responses from the dashboard endpoint.
+func (r *Response) base() *Response {
+	return r
+}
+
+// NewClient creates a new `Client` for sending events to the dashboard endpoint
+// based on the `endpoint-uri`, `api-key`, `sign`, `timeout`, and `retry`
+// settings in the configuration, with `agent` used to identify the application
+// in requests.
+func NewClient(agent string) *Client {
+	return &Client{
+		endpoint: strings.TrimRight(viper.GetString("endpoint-uri"), "/"),
+		apiKey:   viper.GetString("api-key"),
+		sign:     viper.GetBool("sign"),
+		agent:    agent,
+		retry:    NewRetry(),
+		client: &http.Client{
+			Timeout: time.Duration(viper.GetInt("timeout")) * time.Second,
+		},
+	}
+}
+
+// Run builds the client from the configuration and sends the `events` to the
+// dashboard endpoint, in order, returning an error if any of the events are
+// invalid (before any of them are sent), could not be delivered, or were
+// rejected. If a spool directory is configured, events which could not be
+// delivered are saved to it to be sent later by `Flush`, instead of failing.
+func Run(agent string, events ...*event.Event) error {
+	now := time.Now().UTC()
+
+	for i, e := range events {
+		if err := e.Validate(); err != nil {
+			if len(events) > 1 {
+				return fmt.Errorf("event %d: %w", i+1, err)
+			}
+
+			return err
+		}
+
+		// Always set the time of the update, so that any repeated submissions of
+		// the event are identified as the same update by the endpoint
+		if e.Timestamp.IsZero() {
+			e.Timestamp = now
+		}
+	}
+
+	err := NewClient(agent).deliver(context.Background(), events)
+
+	spool := NewSpool()
+	if err == nil || spool == nil || !Retryable(err) {
+		return err
+	}
+
+	file, spoolErr := spool.Write(events)
+	if spoolErr != nil {
+		return errors.Join(err, spoolErr)
+	}
+
+	slog.Warn(
+		"Saved dashboard events to the spool",
+		slog.Group("error", slog.String("message", err.Error())),
+		slog.Group("spool",
+			slog.String("file", file),
+			slog.Int("events", len(events)),
+		),
+	)
+
+	return nil
+}
+
+// deliver sends the `events` to the dashboard endpoint, either on its own if
+// there is only one event, or as a single batch, returning an error if the
+// events could not be delivered, or if any of them were rejected.
+func (c *Client) deliver(ctx context.Context, events []*event.Event) error {
+	// Send multiple events as a single batch, so that they are delivered with
+	// one request rather than one each
+	if len(events) > 1 {
+		return c.deliverBatch(ctx, events)
+	}
+
+	e := events[0]
+
+	slog.Info(
+		"Sending dashboard event",
+		slog.Group("event",
+			slog.String("id", e.ID),
+			slog.String("status", e.Status),
+		),
+		slog.String("endpoint", c.endpoint),
+	)
+
+	response, err := c.Send(ctx, e)
+	if err != nil {
+		return err
+	}
+
+	slog.Info(
+		"Dashboard event accepted",
+		slog.Group("response",
+			slog.Int("code", response.Code),
+			slog.String("status", response.Status),
+		),
+	)
 
 	return nil
 }
+
+// deliverBatch sends the `events` to the dashboard endpoint as a single batch,
+// logging the outcome for each event, and returning an error if the batch
+// could not be delivered, or if any events were rejected.
+func (c *Client) deliverBatch(ctx context.Context, events []*event.Event) error {
+	slog.Info(
+		"Sending dashboard events",
+		slog.Int("events", len(events)),
+		slog.String("endpoint", c.endpoint),
+	)
+
+	response, err := c.SendBatch(ctx, events)
+	if err != nil {
+		return err
+	}
+
+	for _, result := range response.Results {
+		if result.Code >= http.StatusOK && result.Code < http.StatusMultipleChoices {
+			continue
+		}
+
+		slog.Error(
+			"Dashboard event rejected",
+			slog.Group("event",
+				slog.String("id", result.ID),
+				slog.Int("index", result.Index),
+			),
+			slog.Group("response",
+				slog.Int("code", result.Code),
+				slog.String("status", result.Status),
+				slog.String("message", result.Message),
+			),
+		)
+	}
+
+	if response.Rejected > 0 {
+		return fmt.Errorf("%w: %d of %d events", ErrEventsRejected, response.Rejected, len(events))
+	}
+
+	slog.Info(
+		"Dashboard events accepted",
+		slog.Group("response",
+			slog.Int("code", response.Code),
+			slog.String("status", response.Status),
+			slog.Int("accepted", response.Accepted),
+		),
+	)
+
+	return nil
+}
+
+// Send submits the event `e` to the dashboard endpoint, returning the decoded
+// `Response` if the event was accepted, a `ResponseError` if the endpoint
+// responded with a non-2xx status code, or a `RequestError` if the request
+// could not be made at all.
+func (c *Client) Send(ctx context.Context, e *event.Event) (*Response, error) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return nil, NewRequestError(c.endpoint, "unable to encode the event", err)
+	}
+
+	response := &Response{}
+	if err := c.post(ctx, eventsPath, body, e.IdempotencyKey(), response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// SendBatch submits the `events` to the dashboard endpoint as a single batch,
+// returning the decoded `BatchResponse` with the outcome for each event if the
+// batch was processed, a `ResponseError` if the endpoint responded with a
+// non-2xx status code, or a `RequestError` if the request could not be made at
+// all.
+func (c *Client) SendBatch(ctx context.Context, events []*event.Event) (*BatchResponse, error) {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return nil, NewRequestError(c.endpoint, "unable to encode the events", err)
+	}
+
+	response := &BatchResponse{}
+	if err := c.post(ctx, batchPath, body, batchKey(events), response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// post submits the `body` to the `path` on the dashboard endpoint, with the
+// idempotency `key` if set, decoding the body of the response into `response`,
+// and making the request again, with the same idempotency key, if it fails in
+// a way which could succeed later.
+func (c *Client) post(ctx context.Context, path string, body []byte, key string, response decoder) error {
+	if c.endpoint == "" {
+		return ErrMissingEndpoint
+	}
+
+	uri, err := url.JoinPath(c.endpoint, path)
+	if err != nil {
+		return NewRequestError(c.endpoint, "unable to build the request URI", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := c.attempt(ctx, uri, body, key, response)
+
+		delay, retry := c.retry.next(attempt, err)
+		if !retry {
+			return err
+		}
+
+		slog.Warn(
+			"Retrying request to dashboard endpoint",
+			slog.Group("error", slog.String("message", err.Error())),
+			slog.Group("retry",
+				slog.Int("attempt", attempt+1),
+				slog.Int("attempts", c.retry.Attempts),
+				slog.Duration("delay", delay),
+			),
+		)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// attempt makes a single request to submit the `body` to the `uri`, building
+// and authenticating the request each time, so that signed requests are given
+// a new signature for each attempt.
+func (c *Client) attempt(ctx context.Context, uri string, body []byte, key string, response decoder) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, uri, bytes.NewReader(body))
+	if err != nil {
+		return NewRequestError(c.endpoint, "unable to build the request", err)
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/json")
+	request.Header.Set("User-Agent", c.agent)
+
+	if key != "" {
+		request.Header.Set(idempotencyHeader, key)
+	}
+
+	if err := c.authenticate(request, body); err != nil {
+		return NewRequestError(c.endpoint, "unable to sign the request", err)
+	}
+
+	slog.Debug(
+		"Submitting request to dashboard endpoint",
+		slog.Group("request",
+			slog.String("method", request.Method),
+			slog.String("uri", uri),
+			slog.Int("size", len(body)),
+		),
+	)
+
+	resp, err := c.client.Do(request)
+	if err != nil {
+		return NewDeliveryError(c.endpoint, "unable to send the event", err)
+	}
+	defer resp.Body.Close()
+
+	return decode(resp, response)
+}
+
+// batchKey returns the idempotency key for the batch of `events`, made from
+// the idempotency key of each event, so that repeated submissions of the same
+// batch are only processed once, or an empty string if any event has no key.
+func batchKey(events []*event.Event) string {
+	hash := sha256.New()
+
+	for _, e := range events {
+		key := e.IdempotencyKey()
+		if key == "" {
+			return ""
+		}
+
+		hash.Write([]byte(key + "\n"))
+	}
+
+	return "batch:" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// authenticate adds the API key to the `request`, either by signing it along
+// with the `body`, if signing is enabled, or by sending it as a bearer token.
+func (c *Client) authenticate(request *http.Request, body []byte) error {
+	if c.apiKey == "" {
+		return nil
+	}
+
+	if c.sign {
+		// Sign the request with the API key rather than sending it, so that the
+		// key cannot be captured and the request cannot be replayed
+		return signature.Apply(request, c.apiKey, body, time.Now())
+	}
+
+	request.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	return nil
+}
+
+// decode reads the body of the response `resp` into `response`, returning a
+// `ResponseError` with as much detail as can be found in the body instead if
+// the status code was not 2xx.
+func decode(resp *http.Response, response decoder) error {
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return NewRequestError(resp.Request.URL.String(), "unable to read the response", err)
+	}
+
+	// Only decode into the response once successful, so that the responses
+	// from any failed attempts are not mixed into it
+	failed := resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices
+	if failed {
+		response = &Response{}
+	}
+
+	if len(data) > 0 {
+		// Ignore any error as non-JSON bodies (such as from a proxy) can still be
+		// reported through the status code alone
+		_ = json.Unmarshal(data, response)
+	}
+
+	base := response.base()
+	if base.Code == 0 {
+		base.Code = resp.StatusCode
+	}
+
+	if failed {
+		status := base.Status
+		if status == "" {
+			status = http.StatusText(resp.StatusCode)
+		}
+
+		return &ResponseError{
+			Code:       resp.StatusCode,
+			Status:     status,
+			Message:    base.Message,
+			RetryAfter: retryAfter(resp, time.Now()),
+		}
+	}
+
+	return nil
+}
+
+// UserAgent builds the value of the User-Agent header sent with each request
+// from the `name` and `version` of the application.
+func UserAgent(name, version string) string {
+	return fmt.Sprintf("%s/%s", name, strings.TrimPrefix(version, "v"))
+}
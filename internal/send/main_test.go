@@ -0,0 +1,203 @@
+//nolint:paralleltest // these tests cannot operate in parallel
+package send_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/send"
+	"github.com/n3tuk/dashboard/internal/signature"
+)
+
+const apiKey = "d54813f8-9a23-470b-be06-d35b150f9fc1" // gitleaks:allow
+
+// newEndpoint creates a test server which will respond to requests with the
+// `code` and `body` provided, checking the request is authenticated.
+func newEndpoint(t *testing.T, code int, body string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/api/v1/events", r.URL.Path)
+		assert.Equal(t, "Bearer "+apiKey, r.Header.Get("Authorization"))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_, _ = w.Write([]byte(body))
+	}))
+
+	t.Cleanup(server.Close)
+
+	viper.Reset()
+	viper.Set("endpoint-uri", server.URL)
+	viper.Set("api-key", apiKey)
+	viper.Set("timeout", 5)
+
+	return server
+}
+
+// TestSendAccepted tests that an accepted event returns the response.
+func TestSendAccepted(t *testing.T) {
+	newEndpoint(t, http.StatusAccepted, `{"code":202,"status":"accepted","message":"ok"}`)
+
+	client := send.NewClient("dashboard/test")
+	response, err := client.Send(context.Background(), &event.Event{ID: "test", Status: "pass"})
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusAccepted, response.Code)
+	assert.Equal(t, "accepted", response.Status)
+}
+
+// TestSendIdempotencyKey tests that the idempotency key is sent for events
+// with a timestamp, so that repeated submissions are only processed once.
+func TestSendIdempotencyKey(t *testing.T) {
+	keys := []string{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(server.Close)
+
+	viper.Reset()
+	viper.Set("endpoint-uri", server.URL)
+
+	timestamp := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	client := send.NewClient("dashboard/test")
+
+	_, err := client.Send(context.Background(), &event.Event{ID: "test", Status: "pass", Timestamp: timestamp})
+	require.NoError(t, err)
+
+	_, err = client.Send(context.Background(), &event.Event{ID: "test", Status: "pass"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"test@2024-07-01T12:00:00Z", ""}, keys)
+}
+
+// TestSendSigned tests that, when signing is enabled, the request is signed
+// with the API key rather than sending the key itself.
+func TestSendSigned(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		assert.Empty(t, r.Header.Get("Authorization"))
+		assert.True(t, signature.Verify(
+			[]byte(apiKey),
+			r.Header.Get(signature.SignatureHeader),
+			r.Header.Get(signature.TimestampHeader),
+			r.Header.Get(signature.NonceHeader),
+			r.Method, r.URL.Path, body,
+		))
+
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"code":202,"status":"accepted"}`))
+	}))
+	defer server.Close()
+
+	viper.Reset()
+	viper.Set("endpoint-uri", server.URL)
+	viper.Set("api-key", apiKey)
+	viper.Set("sign", true)
+
+	_, err := send.NewClient("dashboard/test").Send(context.Background(), &event.Event{ID: "test", Status: "pass"})
+	require.NoError(t, err)
+}
+
+// TestSendRejected tests that a non-2xx response is returned as a
+// `ResponseError` with the details from the response.
+func TestSendRejected(t *testing.T) {
+	newEndpoint(t, http.StatusBadRequest, `{"code":400,"status":"invalid-event","message":"failed"}`)
+
+	client := send.NewClient("dashboard/test")
+	_, err := client.Send(context.Background(), &event.Event{ID: "test", Status: "pass"})
+
+	var expected *send.ResponseError
+	require.ErrorAs(t, err, &expected)
+	assert.Equal(t, http.StatusBadRequest, expected.Code)
+	assert.Equal(t, "invalid-event", expected.Status)
+}
+
+// TestSendUnreachable tests that a failure to connect is returned as a
+// `RequestError`.
+func TestSendUnreachable(t *testing.T) {
+	server := newEndpoint(t, http.StatusAccepted, "")
+	server.Close()
+
+	client := send.NewClient("dashboard/test")
+	_, err := client.Send(context.Background(), &event.Event{ID: "test", Status: "pass"})
+
+	var expected *send.RequestError
+	require.ErrorAs(t, err, &expected)
+}
+
+// TestRunInvalid tests that an invalid event is not sent.
+func TestRunInvalid(t *testing.T) {
+	newEndpoint(t, http.StatusAccepted, "")
+
+	err := send.Run("dashboard/test", &event.Event{})
+	assert.Error(t, err)
+}
+
+// TestRunMultiple tests that multiple events are sent together as a single
+// batch, but only once all of them are valid, and that any events rejected
+// within the batch are reported.
+func TestRunMultiple(t *testing.T) {
+	batches := [][]*event.Event{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/events:batch", r.URL.Path)
+		assert.NotEmpty(t, r.Header.Get("Idempotency-Key"))
+
+		var events []*event.Event
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&events))
+
+		batches = append(batches, events)
+
+		w.WriteHeader(http.StatusOK)
+
+		if len(events) > 2 {
+			_, _ = w.Write([]byte(`{"code":200,"status":"processed","accepted":2,"rejected":1,"results":[` +
+				`{"index":0,"code":202,"status":"accepted"},{"index":1,"code":202,"status":"accepted"},` +
+				`{"index":2,"event-id":"three","code":409,"status":"invalid-transition"}]}`))
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"code":200,"status":"processed","accepted":2,"rejected":0}`))
+	}))
+	t.Cleanup(server.Close)
+
+	viper.Reset()
+	viper.Set("endpoint-uri", server.URL)
+
+	err := send.Run("dashboard/test", &event.Event{ID: "one", Status: "pass"}, &event.Event{ID: "two"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "event 2")
+	assert.Empty(t, batches)
+
+	err = send.Run("dashboard/test", &event.Event{ID: "one", Status: "pass"}, &event.Event{ID: "two", Status: "fail"})
+	require.NoError(t, err)
+	require.Len(t, batches, 1)
+	assert.Equal(t, "one", batches[0][0].ID)
+	assert.Equal(t, "two", batches[0][1].ID)
+	assert.False(t, batches[0][1].Timestamp.IsZero())
+
+	err = send.Run("dashboard/test",
+		&event.Event{ID: "one", Status: "pass"},
+		&event.Event{ID: "two", Status: "pass"},
+		&event.Event{ID: "three", Status: "running"})
+	require.ErrorIs(t, err, send.ErrEventsRejected)
+	assert.Contains(t, err.Error(), "1 of 3 events")
+}
@@ -0,0 +1,102 @@
+package send
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// maxShift is the largest number of times the delay is doubled between
+// attempts, to stop the delay overflowing.
+const maxShift = 30
+
+// Retry holds the policy for making a request again when it fails in a way
+// which could succeed later, such as when the endpoint is briefly unavailable.
+type Retry struct {
+	// Attempts is the maximum number of times a request will be made again
+	// after it first fails, or zero to never make it again.
+	Attempts int
+	// Delay is the time to wait before the first retry, which doubles with each
+	// retry after that.
+	Delay time.Duration
+	// MaxDelay is the maximum time to wait between each retry, or zero for no
+	// maximum.
+	MaxDelay time.Duration
+}
+
+// NewRetry creates a new `Retry` policy based on the `retry.attempts`,
+// `retry.delay`, and `retry.max-delay` settings in the configuration.
+func NewRetry() Retry {
+	return Retry{
+		Attempts: viper.GetInt("retry.attempts"),
+		Delay:    time.Duration(viper.GetInt("retry.delay")) * time.Second,
+		MaxDelay: time.Duration(viper.GetInt("retry.max-delay")) * time.Second,
+	}
+}
+
+// next returns the time to wait before making the request again after the
+// `attempt` (starting from zero) failed with `err`, or false if it should not
+// be made again.
+func (r Retry) next(attempt int, err error) (time.Duration, bool) {
+	if err == nil || attempt >= r.Attempts || !Retryable(err) {
+		return 0, false
+	}
+
+	var after time.Duration
+
+	var response *ResponseError
+	if errors.As(err, &response) {
+		after = response.RetryAfter
+	}
+
+	return r.backoff(attempt, after), true
+}
+
+// backoff returns the time to wait before the retry after the `attempt`,
+// doubling the delay with each attempt, with a random jitter so that clients
+// which failed together do not all retry together, unless the endpoint asked
+// the client to wait for longer with the `after` time.
+func (r Retry) backoff(attempt int, after time.Duration) time.Duration {
+	delay := r.Delay << min(attempt, maxShift)
+	if r.MaxDelay > 0 && (delay > r.MaxDelay || delay < r.Delay) {
+		delay = r.MaxDelay
+	}
+
+	// Wait for between half and all of the delay
+	if half := delay / 2; half > 0 {
+		delay = half + rand.N(delay-half+1)
+	}
+
+	if after > delay {
+		delay = after
+		if r.MaxDelay > 0 {
+			delay = min(after, r.MaxDelay)
+		}
+	}
+
+	return delay
+}
+
+// retryAfter reads the `Retry-After` header from the response `resp`, either
+// as a number of seconds or as a time, returning zero if it is not set or
+// cannot be parsed.
+func retryAfter(resp *http.Response, now time.Time) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0)
+	}
+
+	return 0
+}
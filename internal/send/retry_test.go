@@ -0,0 +1,123 @@
+//nolint:paralleltest // these tests cannot operate in parallel
+package send_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/send"
+	"github.com/n3tuk/dashboard/internal/signature"
+)
+
+// newFlakyEndpoint creates a test server which responds to each request with
+// the next of the `codes`, and then with 202 (Accepted), recording each
+// request made to it.
+func newFlakyEndpoint(t *testing.T, codes ...int) *[]*http.Request {
+	t.Helper()
+
+	requests := []*http.Request{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+
+		code := http.StatusAccepted
+		if len(requests) <= len(codes) {
+			code = codes[len(requests)-1]
+		}
+
+		status := http.StatusText(code)
+
+		switch code {
+		case http.StatusTooManyRequests:
+			w.Header().Set("Retry-After", "1")
+		case http.StatusConflict:
+			// Only the original request still being in progress is a conflict
+			// which can be retried, so respond as the idempotency middleware does
+			w.Header().Set("Retry-After", "1")
+			status = "request-in-progress"
+		}
+
+		w.WriteHeader(code)
+		_, _ = w.Write([]byte(`{"status":"` + status + `"}`))
+	}))
+
+	t.Cleanup(server.Close)
+
+	viper.Reset()
+	viper.Set("endpoint-uri", server.URL)
+	viper.Set("api-key", apiKey)
+	viper.Set("sign", true)
+	viper.Set("retry.attempts", 2)
+	viper.Set("retry.max-delay", 5)
+
+	return &requests
+}
+
+// TestSendRetry tests that requests which fail within the endpoint are made
+// again with the same idempotency key, but signed again for each attempt.
+func TestSendRetry(t *testing.T) {
+	requests := newFlakyEndpoint(t, http.StatusServiceUnavailable, http.StatusBadGateway)
+
+	response, err := send.NewClient("dashboard/test").Send(context.Background(),
+		&event.Event{ID: "test", Status: "pass", Timestamp: time.Now()})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, response.Code)
+
+	require.Len(t, *requests, 3)
+
+	first := (*requests)[0]
+	for _, r := range (*requests)[1:] {
+		assert.Equal(t, first.Header.Get("Idempotency-Key"), r.Header.Get("Idempotency-Key"))
+		assert.NotEqual(t, first.Header.Get(signature.NonceHeader), r.Header.Get(signature.NonceHeader))
+	}
+}
+
+// TestSendRetryAfter tests that the time given in the Retry-After header is
+// waited for before the request is made again.
+func TestSendRetryAfter(t *testing.T) {
+	requests := newFlakyEndpoint(t, http.StatusTooManyRequests)
+
+	start := time.Now()
+	_, err := send.NewClient("dashboard/test").Send(context.Background(), &event.Event{ID: "test", Status: "pass"})
+	require.NoError(t, err)
+
+	assert.Len(t, *requests, 2)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+}
+
+// TestSendRetryInProgress tests that a request is made again when the original
+// request with the same idempotency key is still in progress.
+func TestSendRetryInProgress(t *testing.T) {
+	requests := newFlakyEndpoint(t, http.StatusConflict)
+
+	response, err := send.NewClient("dashboard/test").Send(context.Background(), &event.Event{ID: "test", Status: "pass"})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, response.Code)
+	assert.Len(t, *requests, 2)
+}
+
+// TestSendRetryExhausted tests that requests are only made again up to the
+// number of attempts, and that rejected requests are never made again.
+func TestSendRetryExhausted(t *testing.T) {
+	requests := newFlakyEndpoint(t, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+
+	_, err := send.NewClient("dashboard/test").Send(context.Background(), &event.Event{ID: "test", Status: "pass"})
+	require.Error(t, err)
+	assert.True(t, send.Retryable(err))
+	assert.Len(t, *requests, 3)
+
+	requests = newFlakyEndpoint(t, http.StatusBadRequest)
+
+	_, err = send.NewClient("dashboard/test").Send(context.Background(), &event.Event{ID: "test", Status: "pass"})
+	require.Error(t, err)
+	assert.False(t, send.Retryable(err))
+	assert.Len(t, *requests, 1)
+}
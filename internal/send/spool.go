@@ -0,0 +1,211 @@
+package send
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/n3tuk/dashboard/internal/event"
+)
+
+const (
+	// spoolExtension is the extension of the files in the spool directory which
+	// hold the events waiting to be sent.
+	spoolExtension = ".json"
+	// rejectedExtension is added to the files in the spool directory holding
+	// events which were rejected, so that they are kept but not sent again.
+	rejectedExtension = ".rejected"
+	// spoolPattern is the pattern for the temporary files used while writing
+	// the events to the spool directory.
+	spoolPattern = ".spool-*"
+)
+
+// ErrNoSpool is returned when the spooled events are to be sent, but no spool
+// directory has been configured.
+var ErrNoSpool = errors.New("no spool.directory has been configured")
+
+// Spool saves the events which could not be delivered to the dashboard
+// endpoint to a local directory, one file for each request, so that they can
+// be sent again later in the order they were saved.
+type Spool struct {
+	directory string
+}
+
+// NewSpool creates a new `Spool` based on the `spool.directory` setting in the
+// configuration, or returns nil if it is not set.
+func NewSpool() *Spool {
+	directory := viper.GetString("spool.directory")
+	if directory == "" {
+		return nil
+	}
+
+	return &Spool{directory: directory}
+}
+
+// Write saves the `events` to a new file in the spool directory, named so that
+// it sorts after all the files already saved, returning the name of the file.
+func (s *Spool) Write(events []*event.Event) (string, error) {
+	if err := os.MkdirAll(s.directory, 0o700); err != nil {
+		return "", fmt.Errorf("unable to create the spool directory: %w", err)
+	}
+
+	data, err := json.Marshal(events)
+	if err != nil {
+		return "", fmt.Errorf("unable to encode the events for the spool: %w", err)
+	}
+
+	// Write to a temporary file first, and rename it once complete, so that a
+	// partially written file is never sent
+	temp, err := os.CreateTemp(s.directory, spoolPattern)
+	if err != nil {
+		return "", fmt.Errorf("unable to write the events to the spool: %w", err)
+	}
+
+	if _, err := temp.Write(data); err != nil {
+		_ = temp.Close()
+		_ = os.Remove(temp.Name())
+
+		return "", fmt.Errorf("unable to write the events to the spool: %w", err)
+	}
+
+	if err := temp.Close(); err != nil {
+		_ = os.Remove(temp.Name())
+
+		return "", fmt.Errorf("unable to write the events to the spool: %w", err)
+	}
+
+	// Use the random part of the temporary file in the name, so that files
+	// saved at the same time by different processes do not clash
+	suffix := strings.TrimPrefix(filepath.Base(temp.Name()), strings.TrimSuffix(spoolPattern, "*"))
+	file := filepath.Join(s.directory, fmt.Sprintf("%020d-%s%s", time.Now().UnixNano(), suffix, spoolExtension))
+
+	if err := os.Rename(temp.Name(), file); err != nil {
+		_ = os.Remove(temp.Name())
+
+		return "", fmt.Errorf("unable to write the events to the spool: %w", err)
+	}
+
+	return file, nil
+}
+
+// Files returns the files in the spool directory holding events waiting to be
+// sent, in the order they were saved.
+func (s *Spool) Files() ([]string, error) {
+	entries, err := os.ReadDir(s.directory)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the spool directory: %w", err)
+	}
+
+	files := []string{}
+
+	// The entries are sorted by name, which starts with the time each file was
+	// saved, so they are returned in the order they were saved
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != spoolExtension {
+			continue
+		}
+
+		files = append(files, filepath.Join(s.directory, entry.Name()))
+	}
+
+	return files, nil
+}
+
+// Read returns the events saved in the spool `file`.
+func (s *Spool) Read(file string) ([]*event.Event, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the spooled events from %s: %w", file, err)
+	}
+
+	events := []*event.Event{}
+	if err := json.Unmarshal(data, &events); err != nil {
+		return nil, fmt.Errorf("unable to decode the spooled events from %s: %w", file, err)
+	}
+
+	if len(events) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoEvents, file)
+	}
+
+	return events, nil
+}
+
+// Flush builds the client from the configuration and sends the events saved
+// in the spool directory to the dashboard endpoint, in the order they were
+// saved, removing each file once sent. Sending stops at the first file which
+// could not be delivered, so that the order is kept, while files holding
+// events which were rejected are kept, but will not be sent again.
+func Flush(agent string) error {
+	spool := NewSpool()
+	if spool == nil {
+		return ErrNoSpool
+	}
+
+	files, err := spool.Files()
+	if err != nil {
+		return err
+	}
+
+	if len(files) == 0 {
+		slog.Info("No spooled dashboard events to send", slog.String("spool", spool.directory))
+
+		return nil
+	}
+
+	client := NewClient(agent)
+	rejected := 0
+
+	for i, file := range files {
+		slog.Info(
+			"Sending spooled dashboard events",
+			slog.Group("spool",
+				slog.String("file", file),
+				slog.Int("remaining", len(files)-i),
+			),
+		)
+
+		events, err := spool.Read(file)
+		if err == nil {
+			err = client.deliver(context.Background(), events)
+		}
+
+		switch {
+		case err == nil:
+			if err := os.Remove(file); err != nil {
+				return fmt.Errorf("unable to remove the spooled events: %w", err)
+			}
+		case Retryable(err):
+			return fmt.Errorf("unable to send the spooled events, with %d files remaining: %w", len(files)-i, err)
+		default:
+			rejected++
+
+			slog.Error(
+				"Failed to send spooled dashboard events",
+				slog.Group("error", slog.String("message", err.Error())),
+				slog.Group("spool", slog.String("file", file+rejectedExtension)),
+			)
+
+			if err := os.Rename(file, file+rejectedExtension); err != nil {
+				return fmt.Errorf("unable to set aside the rejected spooled events: %w", err)
+			}
+		}
+	}
+
+	if rejected > 0 {
+		return fmt.Errorf("%w: %d of %d spooled files", ErrEventsRejected, rejected, len(files))
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+//nolint:paralleltest // these tests cannot operate in parallel
+package send_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/send"
+)
+
+// TestRunSpool tests that events which cannot be delivered are saved to the
+// spool directory, and are then sent in order when flushed.
+func TestRunSpool(t *testing.T) {
+	directory := filepath.Join(t.TempDir(), "spool")
+
+	// Close the server straight away, so the events cannot be delivered
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	viper.Reset()
+	viper.Set("endpoint-uri", down.URL)
+	viper.Set("spool.directory", directory)
+
+	require.NoError(t, send.Run("dashboard/test", &event.Event{ID: "one", Status: "running"}))
+	require.NoError(t, send.Run("dashboard/test", &event.Event{ID: "one", Status: "pass"}, &event.Event{ID: "two", Status: "pass"}))
+
+	spool := send.NewSpool()
+	files, err := spool.Files()
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	paths := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"code":200,"status":"processed"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	viper.Set("endpoint-uri", server.URL)
+
+	require.NoError(t, send.Flush("dashboard/test"))
+	assert.Equal(t, []string{"/api/v1/events", "/api/v1/events:batch"}, paths)
+
+	files, err = spool.Files()
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	require.NoError(t, send.Flush("dashboard/test"))
+}
+
+// TestFlushRejected tests that spooled events which are rejected are set aside
+// rather than being sent again, and that a spool directory must be set.
+func TestFlushRejected(t *testing.T) {
+	directory := t.TempDir()
+
+	newEndpoint(t, http.StatusBadRequest, `{"code":400,"status":"invalid-event"}`)
+	viper.Set("spool.directory", directory)
+
+	file, err := send.NewSpool().Write([]*event.Event{{ID: "test", Status: "pass"}})
+	require.NoError(t, err)
+
+	require.ErrorIs(t, send.Flush("dashboard/test"), send.ErrEventsRejected)
+	assert.FileExists(t, file+".rejected")
+
+	entries, err := os.ReadDir(directory)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	viper.Set("spool.directory", "")
+	require.ErrorIs(t, send.Flush("dashboard/test"), send.ErrNoSpool)
+}
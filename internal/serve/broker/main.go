@@ -0,0 +1,288 @@
+// The `broker` package provides the client for the message broker (such as
+// ActiveMQ Artemis) used to fan out events between the dashboard instances in a
+// cluster, publishing each event accepted by this instance to a topic named
+// after the cluster, and consuming the events accepted by the other instances
+// from it, so each instance can push them out to its own connected clients.
+package broker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-stomp/stomp/v3"
+	"github.com/spf13/viper"
+
+	"github.com/n3tuk/dashboard/internal/event"
+)
+
+const (
+	// originHeader is the name of the header added to each message to identify
+	// the dashboard instance which published it.
+	originHeader = "dashboard-origin"
+	// topicPrefix is the prefix added to the name of the cluster to create the
+	// name of the topic the events are shared through.
+	topicPrefix = "/topic/"
+	// contentType is the MIME type of the body of each message.
+	contentType = "application/json"
+
+	// minBackoff is the initial time to wait before reconnecting to the broker.
+	minBackoff = time.Second
+	// maxBackoff is the maximum time to wait before reconnecting to the broker.
+	maxBackoff = time.Minute
+	// heartbeat is the interval for the heart-beats to and from the broker, used
+	// to detect when the connection has been lost.
+	heartbeat = 10 * time.Second
+)
+
+// ErrNotConnected is returned when an event cannot be published as there is no
+// connection to the broker.
+var ErrNotConnected = errors.New("not connected to the broker")
+
+// Client provides the connection to the message broker, reconnecting to it if
+// the connection is lost, and both publishing events to, and consuming events
+// from, the topic for the cluster.
+type Client struct {
+	attr        slog.Attr
+	enabled     bool
+	address     string
+	username    string
+	password    string
+	destination string
+	origin      string
+
+	mutex   sync.RWMutex
+	conn    *stomp.Conn
+	handler func(*event.Event)
+}
+
+// NewClient creates a new `Client` for the message broker based on the
+// `broker` and `cluster.name` settings in the configuration.
+func NewClient() *Client {
+	name := viper.GetString("cluster.name")
+	address := viper.GetString("broker.address")
+	destination := topicPrefix + name
+
+	return &Client{
+		enabled:     viper.GetBool("broker.enabled"),
+		address:     address,
+		username:    viper.GetString("broker.username"),
+		password:    viper.GetString("broker.password"),
+		destination: destination,
+		origin:      newOrigin(),
+
+		attr: slog.Group(
+			"broker",
+			slog.String("address", address),
+			slog.String("destination", destination),
+		),
+	}
+}
+
+// newOrigin creates a unique identifier for this instance so that events it
+// publishes can be ignored when they are consumed back from the topic.
+func newOrigin() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "dashboard"
+	}
+
+	suffix := make([]byte, 4) //nolint:mnd // ignore
+	_, _ = rand.Read(suffix)
+
+	return host + "-" + hex.EncodeToString(suffix)
+}
+
+// Subscribe sets the `handler` which is called with each event consumed from
+// the topic which was published by another dashboard instance.
+func (c *Client) Subscribe(handler func(*event.Event)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.handler = handler
+}
+
+// Start connects to the broker and consumes events from the topic until `ctx`
+// is cancelled, reconnecting with an exponential backoff each time the
+// connection is lost, and reporting the state of the connection to `health`.
+// If the broker has not been enabled, it is reported as healthy and returns
+// immediately.
+func (c *Client) Start(ctx context.Context, health func(bool)) {
+	if !c.enabled {
+		slog.Info("Message broker is not enabled, events will not be shared", c.attr)
+		health(true)
+
+		return
+	}
+
+	backoff := minBackoff
+
+	for {
+		health(false)
+
+		err := c.run(ctx, func() {
+			health(true)
+
+			backoff = minBackoff
+		})
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		slog.Error(
+			"Lost connection to the message broker",
+			slog.Group("error", slog.String("message", err.Error())),
+			slog.Duration("retry", backoff),
+			c.attr,
+		)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, maxBackoff) //nolint:mnd // ignore
+	}
+}
+
+// run connects and subscribes to the topic on the broker, calling `connected`
+// once successful, and then consumes messages until either the connection is
+// lost, returning the error, or `ctx` is cancelled.
+func (c *Client) run(ctx context.Context, connected func()) error {
+	conn, err := stomp.DialWithContext(ctx, "tcp", c.address,
+		stomp.ConnOpt.Login(c.username, c.password),
+		stomp.ConnOpt.HeartBeat(heartbeat, heartbeat),
+	)
+	if err != nil {
+		return err
+	}
+
+	subscription, err := conn.Subscribe(c.destination, stomp.AckAuto,
+		stomp.SubscribeOpt.Header("subscription-type", "MULTICAST"),
+	)
+	if err != nil {
+		_ = conn.MustDisconnect()
+
+		return err
+	}
+
+	c.mutex.Lock()
+	c.conn = conn
+	c.mutex.Unlock()
+
+	defer func() {
+		c.mutex.Lock()
+		c.conn = nil
+		c.mutex.Unlock()
+
+		_ = conn.Disconnect()
+	}()
+
+	slog.Info("Connected to the message broker", c.attr)
+	connected()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case message, ok := <-subscription.C:
+			if !ok {
+				return ErrNotConnected
+			}
+
+			if message.Err != nil {
+				return message.Err
+			}
+
+			c.receive(message)
+		}
+	}
+}
+
+// receive decodes the event from the `message` and passes it to the handler,
+// unless it was published by this instance.
+func (c *Client) receive(message *stomp.Message) {
+	if message.Header.Get(originHeader) == c.origin {
+		return
+	}
+
+	e := &event.Event{}
+	if err := json.Unmarshal(message.Body, e); err != nil {
+		slog.Warn(
+			"Unable to decode event from the message broker",
+			slog.Group("error", slog.String("message", err.Error())),
+			c.attr,
+		)
+
+		return
+	}
+
+	c.mutex.RLock()
+	handler := c.handler
+	c.mutex.RUnlock()
+
+	slog.Debug(
+		"Received event from the message broker",
+		slog.Group("event",
+			slog.String("id", e.ID),
+			slog.String("status", e.Status),
+			slog.String("origin", message.Header.Get(originHeader)),
+		),
+	)
+
+	if handler != nil {
+		handler(e)
+	}
+}
+
+// Publish sends the event `e` to the topic for the cluster, so it can be
+// consumed by the other dashboard instances. If the broker is not enabled this
+// does nothing, and if there is no connection to the broker, the event is not
+// sent and a warning is logged, as the event has already been saved.
+func (c *Client) Publish(e *event.Event) {
+	if !c.enabled {
+		return
+	}
+
+	err := c.publish(e)
+	if err != nil {
+		slog.Warn(
+			"Unable to publish event to the message broker",
+			slog.Group("event",
+				slog.String("id", e.ID),
+				slog.String("status", e.Status),
+			),
+			slog.Group("error", slog.String("message", err.Error())),
+			c.attr,
+		)
+	}
+}
+
+// publish encodes and sends the event `e` to the topic for the cluster.
+func (c *Client) publish(e *event.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.RLock()
+	conn := c.conn
+	c.mutex.RUnlock()
+
+	if conn == nil {
+		return ErrNotConnected
+	}
+
+	return conn.Send(c.destination, contentType, body,
+		stomp.SendOpt.Header("destination-type", "MULTICAST"),
+		stomp.SendOpt.Header(originHeader, c.origin),
+	)
+}
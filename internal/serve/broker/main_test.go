@@ -0,0 +1,99 @@
+//nolint:paralleltest // these tests cannot operate in parallel
+package broker_test
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-stomp/stomp/v3/server"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/serve/broker"
+)
+
+// newServer starts an in-process STOMP server for the clients to connect to,
+// and configures the broker settings to use it.
+func newServer(t *testing.T) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() { _ = server.Serve(listener) }()
+
+	viper.Reset()
+	viper.Set("cluster.name", "test")
+	viper.Set("broker.enabled", true)
+	viper.Set("broker.address", listener.Addr().String())
+}
+
+// start connects the client `c` and waits until it reports that it is healthy.
+func start(ctx context.Context, t *testing.T, c *broker.Client) {
+	t.Helper()
+
+	var healthy atomic.Bool
+
+	go c.Start(ctx, healthy.Store)
+
+	require.Eventually(t, healthy.Load, 5*time.Second, 10*time.Millisecond)
+}
+
+// TestFanOut tests that events published by one client are received by another
+// client, but not by the client which published it.
+func TestFanOut(t *testing.T) {
+	newServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	publisher := broker.NewClient()
+	consumer := broker.NewClient()
+
+	own := make(chan *event.Event, 1)
+	received := make(chan *event.Event, 1)
+
+	publisher.Subscribe(func(e *event.Event) { own <- e })
+	consumer.Subscribe(func(e *event.Event) { received <- e })
+
+	start(ctx, t, publisher)
+	start(ctx, t, consumer)
+
+	publisher.Publish(&event.Event{ID: "fan-out", Status: "pass"})
+
+	select {
+	case e := <-received:
+		assert.Equal(t, "fan-out", e.ID)
+		assert.Equal(t, "pass", e.Status)
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not received by the consumer")
+	}
+
+	select {
+	case <-own:
+		t.Fatal("event was received by the publisher")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestDisabled tests that a disabled client reports as healthy without
+// connecting, and that publishing does nothing.
+func TestDisabled(t *testing.T) {
+	viper.Reset()
+	viper.Set("broker.enabled", false)
+
+	c := broker.NewClient()
+
+	healthy := false
+	c.Start(context.Background(), func(status bool) { healthy = status })
+
+	assert.True(t, healthy)
+	c.Publish(&event.Event{ID: "disabled", Status: "pass"})
+}
@@ -0,0 +1,312 @@
+// The `certificate` package provides the TLS configuration for the listeners
+// of the web and metrics services, including optional verification of client
+// certificates (mutual TLS), and reloads the certificate files whenever they
+// change, so that rotated certificates are used without a restart.
+package certificate
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/spf13/viper"
+)
+
+var (
+	expiry = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: "tls",
+		Name:      "certificate_expiry_timestamp_seconds",
+		Help:      "Time the certificate served by the listener expires.",
+	}, []string{"cluster", "component"})
+
+	// ErrMissingKey is returned when only one of the certificate and the key
+	// has been configured for a listener.
+	ErrMissingKey = errors.New("both the certificate and the key must be set")
+	// ErrMissingClientCA is returned when client certificates must be verified
+	// but no client CA has been configured for a listener.
+	ErrMissingClientCA = errors.New("the client-ca must be set to verify client certificates")
+	// ErrInvalidClientCA is returned when no certificates could be read from
+	// the client CA file.
+	ErrInvalidClientCA = errors.New("no certificates found in the client-ca")
+	// ErrInvalidVersion is returned when the minimum version of TLS is unknown.
+	ErrInvalidVersion = errors.New("unknown minimum TLS version")
+	// ErrInvalidClientAuth is returned when the client authentication mode is
+	// unknown.
+	ErrInvalidClientAuth = errors.New("unknown client-auth mode")
+
+	// versions maps the supported minimum versions to the TLS versions.
+	versions = map[string]uint16{
+		"1.2": tls.VersionTLS12,
+		"1.3": tls.VersionTLS13,
+	}
+
+	// modes maps the client authentication modes to the TLS client auth types.
+	modes = map[string]tls.ClientAuthType{
+		"none":               tls.NoClientCert,
+		"request":            tls.RequestClientCert,
+		"require":            tls.RequireAnyClientCert,
+		"verify-if-given":    tls.VerifyClientCertIfGiven,
+		"require-and-verify": tls.RequireAndVerifyClientCert,
+	}
+)
+
+// Reloader provides the TLS configuration for a listener, keeping the
+// certificate and the client CAs up to date with the files they are read from.
+type Reloader struct {
+	cert     string
+	key      string
+	clientCA string
+
+	minVersion uint16
+	clientAuth tls.ClientAuthType
+
+	certificate atomic.Pointer[tls.Certificate]
+	clients     atomic.Pointer[x509.CertPool]
+
+	watcher *fsnotify.Watcher
+	attr    slog.Attr
+	expiry  prometheus.Gauge
+}
+
+// New creates a `Reloader` from the `endpoints.tls.<component>` settings in
+// the configuration, loading the certificate files for the first time. If no
+// certificate has been configured for the listener, nil is returned, and the
+// listener should not use TLS.
+func New(cluster, component string) (*Reloader, error) {
+	prefix := "endpoints.tls." + component + "."
+
+	r := &Reloader{
+		cert:     viper.GetString(prefix + "cert"),
+		key:      viper.GetString(prefix + "key"),
+		clientCA: viper.GetString(prefix + "client-ca"),
+
+		attr: slog.Group(
+			"cluster",
+			slog.String("name", cluster),
+			slog.String("service", component),
+		),
+		expiry: expiry.WithLabelValues(cluster, component),
+	}
+
+	if r.cert == "" && r.key == "" {
+		return nil, nil //nolint:nilnil // TLS is not enabled for this listener
+	}
+
+	if r.cert == "" || r.key == "" {
+		return nil, fmt.Errorf("%w for the %s service", ErrMissingKey, component)
+	}
+
+	version, ok := versions[viper.GetString(prefix+"min-version")]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidVersion, viper.GetString(prefix+"min-version"))
+	}
+
+	mode := viper.GetString(prefix + "client-auth")
+	if mode == "" {
+		// Verify client certificates by default only if a client CA was set
+		mode = "none"
+		if r.clientCA != "" {
+			mode = "require-and-verify"
+		}
+	}
+
+	auth, ok := modes[mode]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidClientAuth, mode)
+	}
+
+	if (auth == tls.VerifyClientCertIfGiven || auth == tls.RequireAndVerifyClientCert) && r.clientCA == "" {
+		return nil, fmt.Errorf("%w for the %s service", ErrMissingClientCA, component)
+	}
+
+	r.minVersion = version
+	r.clientAuth = auth
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	if err := r.watch(); err != nil {
+		return nil, fmt.Errorf("unable to watch the certificate for changes: %w", err)
+	}
+
+	return r, nil
+}
+
+// TLSConfig returns the configuration for the TLS listener, which always uses
+// the latest certificate and client CAs loaded.
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: r.minVersion,
+		GetConfigForClient: func(_ *tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.config(), nil
+		},
+	}
+}
+
+// config builds the configuration for a single connection from the latest
+// certificate and client CAs loaded.
+func (r *Reloader) config() *tls.Config {
+	return &tls.Config{
+		MinVersion:   r.minVersion,
+		ClientAuth:   r.clientAuth,
+		ClientCAs:    r.clients.Load(),
+		Certificates: []tls.Certificate{*r.certificate.Load()},
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+}
+
+// watch starts watching the certificate files for changes, reloading them
+// each time they do, until `Close` is called.
+func (r *Reloader) watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	// Watch the directories rather than the files, as the files are often
+	// replaced (such as through symbolic links for Kubernetes Secrets) rather
+	// than written to, which would otherwise stop them being watched
+	dirs := []string{}
+	for _, file := range []string{r.cert, r.key, r.clientCA} {
+		if dir := filepath.Dir(file); file != "" && !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	for _, dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+
+			return err
+		}
+	}
+
+	r.watcher = watcher
+
+	go r.reload()
+
+	return nil
+}
+
+// reload reloads the certificate files on each change in the directories
+// being watched, until the watcher is closed.
+func (r *Reloader) reload() {
+	for {
+		select {
+		case _, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+
+			if err := r.load(); err != nil {
+				// The files may be part way through being replaced, so keep the
+				// current certificate until the next change is seen
+				slog.Warn(
+					"Unable to reload the TLS certificate",
+					slog.Group("error", slog.String("message", err.Error())),
+					r.attr,
+				)
+			}
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+
+			slog.Error(
+				"Failed watching the TLS certificate for changes",
+				slog.Group("error", slog.String("message", err.Error())),
+				r.attr,
+			)
+		}
+	}
+}
+
+// Close stops watching the certificate files for changes.
+func (r *Reloader) Close() error {
+	if r == nil || r.watcher == nil {
+		return nil
+	}
+
+	return r.watcher.Close()
+}
+
+// Serve accepts connections for the `server` on the listener `l`, using TLS
+// with the configuration from the reloader `r` if it is set, otherwise without
+// TLS.
+func Serve(server *http.Server, l net.Listener, r *Reloader) error {
+	if r == nil {
+		return server.Serve(l)
+	}
+
+	server.TLSConfig = r.TLSConfig()
+
+	return server.ServeTLS(l, "", "")
+}
+
+// Expires returns the time the current certificate expires.
+func (r *Reloader) Expires() time.Time {
+	return r.certificate.Load().Leaf.NotAfter
+}
+
+// load reads the certificate, the key, and the client CAs (if set), replacing
+// the current ones only if all of them could be read, and only logging when
+// the certificate has changed.
+func (r *Reloader) load() error {
+	certificate, err := tls.LoadX509KeyPair(r.cert, r.key)
+	if err != nil {
+		return fmt.Errorf("unable to load the certificate: %w", err)
+	}
+
+	// The leaf is parsed by LoadX509KeyPair since Go 1.23, so only parse it if
+	// it has not already been done
+	if certificate.Leaf == nil {
+		certificate.Leaf, err = x509.ParseCertificate(certificate.Certificate[0])
+		if err != nil {
+			return fmt.Errorf("unable to parse the certificate: %w", err)
+		}
+	}
+
+	var clients *x509.CertPool
+
+	if r.clientCA != "" {
+		data, err := os.ReadFile(r.clientCA)
+		if err != nil {
+			return fmt.Errorf("unable to load the client-ca: %w", err)
+		}
+
+		clients = x509.NewCertPool()
+		if !clients.AppendCertsFromPEM(data) {
+			return fmt.Errorf("%w: %s", ErrInvalidClientCA, r.clientCA)
+		}
+	}
+
+	if current := r.certificate.Load(); current == nil || !current.Leaf.Equal(certificate.Leaf) {
+		slog.Info(
+			"Loaded the TLS certificate",
+			slog.Group("certificate",
+				slog.String("subject", certificate.Leaf.Subject.String()),
+				slog.Time("expires", certificate.Leaf.NotAfter),
+			),
+			r.attr,
+		)
+	}
+
+	r.certificate.Store(&certificate)
+	r.clients.Store(clients)
+	r.expiry.Set(float64(certificate.Leaf.NotAfter.Unix()))
+
+	return nil
+}
@@ -0,0 +1,262 @@
+//nolint:paralleltest // these tests cannot operate in parallel
+package certificate_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/serve/certificate"
+)
+
+// issued is a certificate and its private key, along with the certificate of
+// the issuer, which is itself if self-signed.
+type issued struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// issue creates a new certificate for `name`, which expires after `validity`,
+// signed by the `issuer`, or self-signed if the issuer is nil.
+func issue(t *testing.T, name string, validity time.Duration, issuer *issued) *issued {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validity).Truncate(time.Second),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+
+		BasicConstraintsValid: true,
+		IsCA:                  issuer == nil,
+	}
+
+	parent, signer := template, key
+	if issuer != nil {
+		parent, signer = issuer.cert, issuer.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &issued{cert: cert, key: key}
+}
+
+// write saves the certificate and the key of `i` as PEM files in `dir`.
+func (i *issued) write(t *testing.T, dir string) {
+	t.Helper()
+
+	der, err := x509.MarshalECPrivateKey(i.key)
+	require.NoError(t, err)
+
+	// Write the files elsewhere first and then move them into place, in the
+	// same way the files would be replaced when the certificate is rotated
+	tmp := t.TempDir()
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: i.cert.Raw})
+	key := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+
+	require.NoError(t, os.WriteFile(filepath.Join(tmp, "tls.crt"), cert, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(tmp, "tls.key"), key, 0o600))
+	require.NoError(t, os.Rename(filepath.Join(tmp, "tls.key"), filepath.Join(dir, "tls.key")))
+	require.NoError(t, os.Rename(filepath.Join(tmp, "tls.crt"), filepath.Join(dir, "tls.crt")))
+}
+
+// configure sets up the TLS settings for the `web` component with the files
+// in `dir`.
+func configure(dir string) {
+	viper.Reset()
+	viper.Set("endpoints.tls.web.cert", filepath.Join(dir, "tls.crt"))
+	viper.Set("endpoints.tls.web.key", filepath.Join(dir, "tls.key"))
+	viper.Set("endpoints.tls.web.min-version", "1.2")
+}
+
+// serve starts a test server using the TLS configuration from `r`.
+func serve(t *testing.T, r *certificate.Reloader) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	server.TLS = r.TLSConfig()
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// served connects to the `server`, returning the certificate it served.
+func served(t *testing.T, server *httptest.Server, config *tls.Config) (*x509.Certificate, error) {
+	t.Helper()
+
+	conn, err := tls.Dial("tcp", server.Listener.Addr().String(), config)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Client certificates are verified after the handshake with TLS 1.3, so
+	// make sure the server has accepted the connection before returning
+	request, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if err := request.Write(conn); err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		return nil, err
+	}
+
+	return conn.ConnectionState().PeerCertificates[0], nil
+}
+
+// withClient returns the configuration for a client connecting with the
+// certificate from `c`.
+func withClient(c *issued) *tls.Config {
+	//nolint:gosec // these are test certificates
+	return &tls.Config{
+		InsecureSkipVerify: true,
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{c.cert.Raw},
+			PrivateKey:  c.key,
+		}},
+	}
+}
+
+// TestDisabled tests that TLS is not enabled when no certificate is set.
+func TestDisabled(t *testing.T) {
+	viper.Reset()
+
+	r, err := certificate.New("test", "web")
+	require.NoError(t, err)
+	assert.Nil(t, r)
+	assert.NoError(t, r.Close())
+}
+
+// TestInvalid tests that the TLS configuration is checked when it is loaded.
+func TestInvalid(t *testing.T) {
+	dir := t.TempDir()
+	issue(t, "server", time.Hour, nil).write(t, dir)
+
+	configure(dir)
+	viper.Set("endpoints.tls.web.key", "")
+
+	_, err := certificate.New("test", "web")
+	require.ErrorIs(t, err, certificate.ErrMissingKey)
+
+	configure(dir)
+	viper.Set("endpoints.tls.web.min-version", "1.0")
+
+	_, err = certificate.New("test", "web")
+	require.ErrorIs(t, err, certificate.ErrInvalidVersion)
+
+	configure(dir)
+	viper.Set("endpoints.tls.web.client-auth", "always")
+
+	_, err = certificate.New("test", "web")
+	require.ErrorIs(t, err, certificate.ErrInvalidClientAuth)
+
+	configure(dir)
+	viper.Set("endpoints.tls.web.client-auth", "require-and-verify")
+
+	_, err = certificate.New("test", "web")
+	require.ErrorIs(t, err, certificate.ErrMissingClientCA)
+
+	configure(filepath.Join(dir, "missing"))
+
+	_, err = certificate.New("test", "web")
+	require.Error(t, err)
+}
+
+// TestReload tests that the certificate served is replaced when the files
+// are changed, without restarting the server.
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	first := issue(t, "first", time.Hour, nil)
+	first.write(t, dir)
+
+	configure(dir)
+
+	r, err := certificate.New("test", "web")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = r.Close() })
+
+	assert.Equal(t, first.cert.NotAfter, r.Expires())
+
+	server := serve(t, r)
+	config := &tls.Config{InsecureSkipVerify: true} //nolint:gosec // these are test certificates
+
+	cert, err := served(t, server, config)
+	require.NoError(t, err)
+	assert.Equal(t, "first", cert.Subject.CommonName)
+
+	second := issue(t, "second", 2*time.Hour, nil)
+	second.write(t, dir)
+
+	assert.Eventually(t, func() bool {
+		cert, err := served(t, server, config)
+
+		return err == nil && cert.Subject.CommonName == "second"
+	}, 5*time.Second, 50*time.Millisecond)
+
+	assert.Equal(t, second.cert.NotAfter, r.Expires())
+}
+
+// TestClientAuth tests that client certificates are required and verified
+// against the client CA when it is set.
+func TestClientAuth(t *testing.T) {
+	dir := t.TempDir()
+	issue(t, "server", time.Hour, nil).write(t, dir)
+
+	ca := issue(t, "ca", time.Hour, nil)
+	client := issue(t, "client", time.Hour, ca)
+	other := issue(t, "other", time.Hour, issue(t, "other-ca", time.Hour, nil))
+
+	file := filepath.Join(dir, "ca.crt")
+	require.NoError(t, os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0o600))
+
+	configure(dir)
+	viper.Set("endpoints.tls.web.client-ca", file)
+
+	r, err := certificate.New("test", "web")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = r.Close() })
+
+	server := serve(t, r)
+
+	//nolint:gosec // these are test certificates
+	_, err = served(t, server, &tls.Config{InsecureSkipVerify: true})
+	require.Error(t, err)
+
+	_, err = served(t, server, withClient(client))
+	require.NoError(t, err)
+
+	_, err = served(t, server, withClient(other))
+	require.Error(t, err)
+}
@@ -0,0 +1,234 @@
+// The `hub` package provides the in-process fan-out of events to the clients
+// connected to this dashboard instance, such as through Server-Sent Events,
+// keeping a short buffer of the most recent events so clients which reconnect
+// can resume from the last event they received.
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/n3tuk/dashboard/internal/event"
+)
+
+const (
+	// bufferSize is the number of recent events kept for clients to resume from.
+	bufferSize = 256
+	// queueSize is the number of events which can be waiting to be sent to a
+	// single client before it is considered too slow and is disconnected.
+	queueSize = 64
+)
+
+var (
+	clients = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: "stream",
+		Name:      "clients",
+		Help:      "Number of clients connected to the live event stream.",
+	}, []string{"cluster", "component", "type"})
+
+	dropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: "stream",
+		Name:      "dropped_total",
+		Help:      "Count of clients disconnected from the live event stream for being too slow.",
+	}, []string{"cluster", "component", "type"})
+
+	// ErrInvalidLabel is returned when a label filter is not in the format of
+	// `key=value`.
+	ErrInvalidLabel = errors.New("label must be in the format key=value")
+)
+
+// Message is a single event sent through the hub, along with the sequence ID
+// assigned to it by the hub, which clients can use to resume the stream.
+type Message struct {
+	ID    uint64
+	Event *event.Event
+}
+
+// Filter restricts the events which are sent to a subscription.
+type Filter struct {
+	// Groups is a list of groups to restrict the events to, where an event
+	// matches if it is in the group, or in any group below it (for example,
+	// `service` matches events in both `service` and `service/production`).
+	// If empty, events from all groups match.
+	Groups []string
+	// Labels is a set of labels which must all be present on the event, and
+	// have the same value, for the event to match.
+	Labels map[string]string
+}
+
+// Subscription is a single client subscribed to the events from the hub.
+type Subscription struct {
+	// C is the channel on which the events are delivered, which is closed once
+	// the subscription is closed, or if the client was too slow to receive the
+	// events sent to it.
+	C <-chan *Message
+
+	// Dropped is set if the subscription was closed by the hub because the
+	// client was too slow to receive the events sent to it.
+	Dropped bool
+
+	queue  chan *Message
+	filter Filter
+	kind   string
+	closed bool
+}
+
+// Hub distributes each event published to it to all the matching subscribers.
+type Hub struct {
+	cluster     string
+	mutex       sync.RWMutex
+	sequence    uint64
+	buffer      []*Message
+	subscribers map[*Subscription]struct{}
+}
+
+// NewHub creates a new, empty, hub for the cluster `name`.
+func NewHub(name string) *Hub {
+	return &Hub{
+		cluster:     name,
+		buffer:      make([]*Message, 0, bufferSize),
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// Publish assigns the next sequence ID to the event `e` and sends it to each of
+// the subscribers with a matching filter. Any subscriber which cannot accept
+// the event without blocking is disconnected.
+func (h *Hub) Publish(e *event.Event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.sequence++
+	message := &Message{ID: h.sequence, Event: e.Clone()}
+
+	if len(h.buffer) == bufferSize {
+		h.buffer = append(h.buffer[:0], h.buffer[1:]...)
+	}
+
+	h.buffer = append(h.buffer, message)
+
+	for s := range h.subscribers {
+		if !s.filter.Matches(e) {
+			continue
+		}
+
+		select {
+		case s.queue <- message:
+		default:
+			s.Dropped = true
+			dropped.WithLabelValues(h.cluster, "web", s.kind).Inc()
+
+			h.close(s)
+		}
+	}
+}
+
+// Subscribe creates a new subscription of type `kind` (such as `sse`) for the
+// events matching the `filter`, first replaying any buffered events which
+// match and have a sequence ID after `last`, if set.
+func (h *Hub) Subscribe(kind string, filter Filter, last uint64) *Subscription {
+	queue := make(chan *Message, queueSize+bufferSize)
+
+	s := &Subscription{
+		C:      queue,
+		queue:  queue,
+		filter: filter,
+		kind:   kind,
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if last > 0 {
+		for _, message := range h.buffer {
+			if message.ID > last && filter.Matches(message.Event) {
+				s.queue <- message
+			}
+		}
+	}
+
+	h.subscribers[s] = struct{}{}
+	clients.WithLabelValues(h.cluster, "web", kind).Inc()
+
+	return s
+}
+
+// Unsubscribe removes the subscription `s` from the hub, closing its channel.
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.close(s)
+}
+
+// close removes the subscription `s` from the hub and closes its channel, if
+// it has not already been closed. The hub must be locked by the caller.
+func (h *Hub) close(s *Subscription) {
+	if s.closed {
+		return
+	}
+
+	s.closed = true
+
+	delete(h.subscribers, s)
+	close(s.queue)
+
+	clients.WithLabelValues(h.cluster, "web", s.kind).Dec()
+}
+
+// Matches checks whether the event `e` matches the groups and labels in the
+// filter.
+func (f Filter) Matches(e *event.Event) bool {
+	if len(f.Groups) > 0 {
+		found := false
+
+		for _, group := range f.Groups {
+			if e.Group == group || strings.HasPrefix(e.Group, group+"/") {
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	for key, value := range f.Labels {
+		if e.Labels[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ParseFilter builds a filter from a list of `groups`, and a list of `labels`,
+// each in the format of `key=value`, such as from the query parameters of a
+// request, returning `ErrInvalidLabel` if any of the labels are not valid.
+func ParseFilter(groups, labels []string) (Filter, error) {
+	filter := Filter{
+		Groups: groups,
+	}
+
+	for _, label := range labels {
+		key, value, ok := strings.Cut(label, "=")
+		if !ok || key == "" {
+			return filter, fmt.Errorf("%w: %q", ErrInvalidLabel, label)
+		}
+
+		if filter.Labels == nil {
+			filter.Labels = map[string]string{}
+		}
+
+		filter.Labels[key] = value
+	}
+
+	return filter, nil
+}
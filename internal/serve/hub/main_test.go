@@ -0,0 +1,97 @@
+package hub_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/serve/hub"
+)
+
+// TestPublish tests that published events are delivered to the subscribers
+// with a matching filter, in order, with increasing IDs.
+func TestPublish(t *testing.T) {
+	t.Parallel()
+
+	h := hub.NewHub("test")
+
+	all := h.Subscribe("test", hub.Filter{}, 0)
+	production := h.Subscribe("test", hub.Filter{Groups: []string{"service/production"}}, 0)
+
+	h.Publish(&event.Event{ID: "one", Group: "service/production/web"})
+	h.Publish(&event.Event{ID: "two", Group: "service/development"})
+
+	first := <-all.C
+	second := <-all.C
+
+	assert.Equal(t, "one", first.Event.ID)
+	assert.Equal(t, "two", second.Event.ID)
+	assert.Less(t, first.ID, second.ID)
+
+	message := <-production.C
+	assert.Equal(t, "one", message.Event.ID)
+	assert.Empty(t, production.C)
+
+	h.Unsubscribe(all)
+	h.Unsubscribe(production)
+
+	_, ok := <-all.C
+	assert.False(t, ok)
+}
+
+// TestResume tests that a new subscription receives the buffered events after
+// the last ID it received.
+func TestResume(t *testing.T) {
+	t.Parallel()
+
+	h := hub.NewHub("test")
+
+	for i := range 5 {
+		h.Publish(&event.Event{ID: fmt.Sprintf("event-%d", i)})
+	}
+
+	s := h.Subscribe("test", hub.Filter{}, 3)
+	defer h.Unsubscribe(s)
+
+	require.Len(t, s.C, 2)
+	assert.Equal(t, "event-3", (<-s.C).Event.ID)
+	assert.Equal(t, "event-4", (<-s.C).Event.ID)
+}
+
+// TestSlowConsumer tests that a subscriber which does not receive its events
+// is disconnected rather than blocking the hub.
+func TestSlowConsumer(t *testing.T) {
+	t.Parallel()
+
+	h := hub.NewHub("test")
+	s := h.Subscribe("test", hub.Filter{}, 0)
+
+	for i := range 1000 {
+		h.Publish(&event.Event{ID: fmt.Sprintf("event-%d", i)})
+	}
+
+	closed := false
+	for range s.C {
+		closed = true
+	}
+
+	assert.True(t, closed)
+	assert.True(t, s.Dropped)
+
+	// Unsubscribing after being disconnected must be safe
+	h.Unsubscribe(s)
+}
+
+// TestFilterLabels tests that all the labels in a filter must match.
+func TestFilterLabels(t *testing.T) {
+	t.Parallel()
+
+	filter := hub.Filter{Labels: map[string]string{"env": "production", "team": "web"}}
+
+	assert.True(t, filter.Matches(&event.Event{Labels: map[string]string{"env": "production", "team": "web", "x": "y"}}))
+	assert.False(t, filter.Matches(&event.Event{Labels: map[string]string{"env": "production"}}))
+	assert.False(t, filter.Matches(&event.Event{}))
+}
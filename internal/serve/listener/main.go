@@ -0,0 +1,252 @@
+// The `listener` package provides the network listeners for the web and
+// metrics services, either binding to a TCP address, or a Unix domain socket,
+// or using the listeners passed to the application through systemd socket
+// activation (`LISTEN_FDS`).
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// UnixPrefix is the prefix for addresses which are the path to a Unix domain
+	// socket, rather than a hostname or an IP address.
+	UnixPrefix = "unix:"
+
+	// firstFD is the first file descriptor used for the listeners passed to the
+	// application by systemd, after stdin, stdout, and stderr.
+	firstFD = 3
+)
+
+var (
+	// ErrInvalidMode is returned when the mode for the Unix domain sockets is
+	// not an octal file mode.
+	ErrInvalidMode = errors.New("the socket mode must be an octal file mode")
+	// ErrNotSocket is returned when the path for a Unix domain socket exists,
+	// but it is not a socket, so it should not be removed.
+	ErrNotSocket = errors.New("the socket path already exists and is not a socket")
+	// ErrSocketInUse is returned when the path for a Unix domain socket is
+	// already being used by another service in the application.
+	ErrSocketInUse = errors.New("the socket path is already used by another service")
+
+	// components is the order of the listeners passed by systemd when they are
+	// not named, where the first is used for the web service, and the second is
+	// used for the metrics service.
+	components = []string{"web", "metrics"}
+
+	activated     map[string]net.Listener
+	activatedErr  error
+	activatedOnce sync.Once
+
+	// sockets holds the paths to the Unix domain sockets bound by the services
+	// in this application, so that a stale socket is never confused with one
+	// which is in use.
+	sockets      = map[string]bool{}
+	socketsMutex sync.Mutex
+)
+
+// Listen returns the listener for the service `component`, using a listener
+// passed to the application by systemd if there is one for the service, or
+// otherwise binding to `address` and `port`, where an address prefixed with
+// `unix:` is the path to a Unix domain socket.
+func Listen(component, address, port string) (net.Listener, error) {
+	listeners, err := Activated()
+	if err != nil {
+		return nil, err
+	}
+
+	if l, ok := listeners[component]; ok {
+		slog.Info(
+			"Using the listener passed through socket activation",
+			slog.Group("listener",
+				slog.String("service", component),
+				slog.String("network", l.Addr().Network()),
+				slog.String("address", l.Addr().String()),
+			),
+		)
+
+		return l, nil
+	}
+
+	if path, ok := strings.CutPrefix(address, UnixPrefix); ok {
+		return listenUnix(path)
+	}
+
+	return net.Listen("tcp", net.JoinHostPort(address, port))
+}
+
+// Activated returns the listeners passed to the application by systemd, keyed
+// by the name of the service they are for, which are taken from the names set
+// in `LISTEN_FDNAMES`, or, if not named, from their order. The environment
+// variables are then removed, so they are not passed to any other processes.
+func Activated() (map[string]net.Listener, error) {
+	activatedOnce.Do(func() {
+		activated, activatedErr = activate()
+	})
+
+	return activated, activatedErr
+}
+
+// activate finds and opens the listeners passed by systemd, if any.
+func activate() (map[string]net.Listener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	listeners := map[string]net.Listener{}
+
+	// Only use the listeners if they were passed to this process, and not to
+	// the parent process, which failed to remove the environment variables
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return listeners, nil
+	}
+
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return listeners, nil //nolint:nilerr // there are no listeners passed
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	for i := range count {
+		name := ""
+		if i < len(names) && names[i] != "" && names[i] != "unknown" {
+			name = names[i]
+		} else if i < len(components) {
+			name = components[i]
+		}
+
+		file := os.NewFile(uintptr(firstFD+i), "LISTEN_FD_"+strconv.Itoa(firstFD+i))
+
+		// The file descriptor is duplicated for the listener, so the original
+		// can be closed once the listener has been created
+		l, err := net.FileListener(file)
+		file.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to use the listener %d passed by systemd: %w", firstFD+i, err)
+		}
+
+		if _, ok := listeners[name]; name == "" || ok {
+			// There is no service to use this listener for
+			l.Close()
+
+			continue
+		}
+
+		listeners[name] = l
+	}
+
+	return listeners, nil
+}
+
+// listenUnix binds to the Unix domain socket at `path`, replacing any stale
+// socket left from a previous run, and then sets the mode and the ownership
+// of the socket from the `endpoints.bind.socket` settings.
+func listenUnix(path string) (net.Listener, error) {
+	socketsMutex.Lock()
+	defer socketsMutex.Unlock()
+
+	if sockets[path] {
+		return nil, fmt.Errorf("%w: %s", ErrSocketInUse, path)
+	}
+
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("%w: %s", ErrNotSocket, path)
+		}
+
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := permissions(path); err != nil {
+		l.Close()
+
+		return nil, err
+	}
+
+	sockets[path] = true
+
+	return &unixListener{Listener: l, path: path}, nil
+}
+
+// permissions sets the mode, owner, and group of the Unix domain socket at
+// `path`, from the `endpoints.bind.socket` settings, if they are set.
+func permissions(path string) error {
+	if mode := viper.GetString("endpoints.bind.socket.mode"); mode != "" {
+		value, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil || value > uint64(fs.ModePerm) {
+			return fmt.Errorf("%w: %s", ErrInvalidMode, mode)
+		}
+
+		if err := os.Chmod(path, fs.FileMode(value)); err != nil {
+			return err
+		}
+	}
+
+	uid, gid := -1, -1
+
+	if owner := viper.GetString("endpoints.bind.socket.owner"); owner != "" {
+		u, err := user.Lookup(owner)
+		if err != nil {
+			if u, err = user.LookupId(owner); err != nil {
+				return fmt.Errorf("unable to find the socket owner %s: %w", owner, err)
+			}
+		}
+
+		uid, _ = strconv.Atoi(u.Uid)
+	}
+
+	if group := viper.GetString("endpoints.bind.socket.group"); group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			if g, err = user.LookupGroupId(group); err != nil {
+				return fmt.Errorf("unable to find the socket group %s: %w", group, err)
+			}
+		}
+
+		gid, _ = strconv.Atoi(g.Gid)
+	}
+
+	if uid == -1 && gid == -1 {
+		return nil
+	}
+
+	return os.Chown(path, uid, gid)
+}
+
+// unixListener is a listener on a Unix domain socket, which releases the path
+// to the socket once it is closed.
+type unixListener struct {
+	net.Listener
+	path string
+}
+
+// Close closes the listener, which also removes the socket.
+func (l *unixListener) Close() error {
+	socketsMutex.Lock()
+	delete(sockets, l.path)
+	socketsMutex.Unlock()
+
+	return l.Listener.Close()
+}
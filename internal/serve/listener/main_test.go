@@ -0,0 +1,126 @@
+//nolint:paralleltest // these tests cannot operate in parallel
+package listener_test
+
+import (
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/serve/listener"
+)
+
+// socketDir creates a temporary directory for the Unix domain sockets, which
+// is kept short, as the paths to sockets are limited in length.
+func socketDir(t *testing.T) string {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "listener")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return dir
+}
+
+// TestActivatedNone tests that no listeners are used when they were passed to
+// another process. This must run first, as the listeners are only found once.
+func TestActivatedNone(t *testing.T) {
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "2")
+
+	listeners, err := listener.Activated()
+	require.NoError(t, err)
+	assert.Empty(t, listeners)
+}
+
+// TestListenTCP tests that the listener binds to the TCP address and port.
+func TestListenTCP(t *testing.T) {
+	l, err := listener.Listen("web", "127.0.0.1", "0")
+	require.NoError(t, err)
+
+	defer l.Close()
+
+	assert.Equal(t, "tcp", l.Addr().Network())
+}
+
+// TestListenUnix tests that the listener binds to the Unix domain socket with
+// the mode set in the configuration, and removes the socket once closed.
+func TestListenUnix(t *testing.T) {
+	viper.Reset()
+	viper.Set("endpoints.bind.socket.mode", "0660")
+
+	path := filepath.Join(socketDir(t), "web.sock")
+
+	l, err := listener.Listen("web", listener.UnixPrefix+path, "8080")
+	require.NoError(t, err)
+
+	assert.Equal(t, "unix", l.Addr().Network())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, fs.ModeSocket, info.Mode().Type())
+	assert.Equal(t, fs.FileMode(0o660), info.Mode().Perm())
+
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	conn.Close()
+
+	require.NoError(t, l.Close())
+
+	_, err = os.Stat(path)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+// TestListenUnixStale tests that a stale socket left from a previous run is
+// replaced, but a socket in use by another service, or a path which is not a
+// socket, is never removed.
+func TestListenUnixStale(t *testing.T) {
+	viper.Reset()
+
+	dir := socketDir(t)
+	path := filepath.Join(dir, "web.sock")
+
+	// Leave a stale socket behind, as it would be after a crash
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	require.NoError(t, err)
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	l, err := listener.Listen("web", listener.UnixPrefix+path, "8080")
+	require.NoError(t, err)
+
+	defer l.Close()
+
+	_, err = listener.Listen("metrics", listener.UnixPrefix+path, "8888")
+	require.ErrorIs(t, err, listener.ErrSocketInUse)
+
+	file := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(file, []byte("data"), 0o600))
+
+	_, err = listener.Listen("metrics", listener.UnixPrefix+file, "8888")
+	require.ErrorIs(t, err, listener.ErrNotSocket)
+
+	_, err = os.Stat(file)
+	require.NoError(t, err)
+}
+
+// TestListenUnixInvalidMode tests that an invalid mode for the socket is
+// rejected.
+func TestListenUnixInvalidMode(t *testing.T) {
+	viper.Reset()
+
+	for _, mode := range []string{"rw-rw----", "0999", "17777"} {
+		viper.Set("endpoints.bind.socket.mode", mode)
+
+		path := filepath.Join(socketDir(t), "web.sock")
+
+		_, err := listener.Listen("web", listener.UnixPrefix+path, "8080")
+		require.ErrorIs(t, err, listener.ErrInvalidMode, mode)
+	}
+}
@@ -12,6 +12,8 @@ import (
 type Health struct {
 	Web         bool
 	Metrics     bool
+	Store       bool
+	Broker      bool
 	Terminating bool
 }
 
@@ -27,6 +29,8 @@ func NewHealth() *Health {
 	return &Health{
 		Web:         false,
 		Metrics:     false,
+		Store:       false,
+		Broker:      false,
 		Terminating: false,
 	}
 }
@@ -48,6 +52,8 @@ func healthz(c *gin.Context) {
 	status := healthy
 	web := healthy
 	metrics := healthy
+	store := healthy
+	broker := healthy
 
 	if !health.Web {
 		code = http.StatusServiceUnavailable
@@ -61,6 +67,18 @@ func healthz(c *gin.Context) {
 		metrics = unhealthy
 	}
 
+	if !health.Store {
+		code = http.StatusServiceUnavailable
+		status = unhealthy
+		store = unhealthy
+	}
+
+	if !health.Broker {
+		code = http.StatusServiceUnavailable
+		status = unhealthy
+		broker = unhealthy
+	}
+
 	if health.Terminating {
 		code = http.StatusGone
 		status = terminating
@@ -72,6 +90,8 @@ func healthz(c *gin.Context) {
 			slog.String("status", status),
 			slog.String("web", web),
 			slog.String("metrics", metrics),
+			slog.String("store", store),
+			slog.String("broker", broker),
 		),
 	)
 
@@ -79,5 +99,7 @@ func healthz(c *gin.Context) {
 		"status":  status,
 		"web":     web,
 		"metrics": metrics,
+		"store":   store,
+		"broker":  broker,
 	})
 }
@@ -6,63 +6,66 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/viper"
 
+	"github.com/n3tuk/dashboard/internal/serve/certificate"
+	"github.com/n3tuk/dashboard/internal/serve/listener"
 	"github.com/n3tuk/dashboard/internal/serve/metrics/alive"
 	"github.com/n3tuk/dashboard/internal/serve/metrics/healthz"
 	"github.com/n3tuk/dashboard/internal/serve/middleware"
 )
 
 type Service struct {
-	attr   slog.Attr
-	router *gin.Engine
-	server *http.Server
-	health *healthz.Health
+	attr    slog.Attr
+	router  *gin.Engine
+	server  *http.Server
+	health  *healthz.Health
+	certs   *certificate.Reloader
+	listen  net.Listener
+	logging atomic.Bool
 }
 
 var ErrServiceNotConfigured = errors.New("service not configured")
 
-func NewService() *Service {
+// defaultAddress is the address the metrics service is bound to when the web
+// service is bound to a Unix domain socket, and no other address is set.
+const defaultAddress = "localhost"
+
+func NewService() (*Service, error) {
 	router := gin.New()
 
 	name := viper.GetString("cluster.name")
-	address := viper.GetString("endpoints.bind.address")
+	address := Address()
 	port := viper.GetString("endpoints.bind.port.metrics")
 
-	if viper.GetBool("logging.metrics") {
-		router.Use(middleware.Logger())
+	certs, err := certificate.New(name, "metrics")
+	if err != nil {
+		return nil, err
 	}
 
-	router.Use(middleware.Prometheus(name, "metrics"))
-	router.Use(gin.Recovery())
+	l, err := listener.Listen("metrics", address, port)
+	if err != nil {
+		_ = certs.Close()
 
-	proxies := viper.GetStringSlice("endpoints.proxies")
-	if len(proxies) > 0 {
-		err := router.SetTrustedProxies(proxies)
-		if err != nil {
-			slog.Error(
-				"Unable to configure trusted proxies",
-				slog.Group(
-					"error",
-					slog.String("message", err.Error()),
-				),
-			)
-		}
+		return nil, err
 	}
 
 	service := &Service{
 		router: router,
+		certs:  certs,
+		listen: l,
 		server: &http.Server{
 			ReadTimeout:       time.Duration(viper.GetInt("endpoints.timeouts.read")) * time.Second,
 			WriteTimeout:      time.Duration(viper.GetInt("endpoints.timeouts.write")) * time.Second,
 			IdleTimeout:       time.Duration(viper.GetInt("endpoints.timeouts.idle")) * time.Second,
-			ReadHeaderTimeout: time.Duration(viper.GetInt("endpoints.timeouts.header")) * time.Second,
+			ReadHeaderTimeout: time.Duration(viper.GetInt("endpoints.timeouts.headers")) * time.Second,
 
-			Addr:    net.JoinHostPort(address, port),
 			Handler: router,
 		},
 
@@ -74,9 +77,19 @@ func NewService() *Service {
 			slog.String("service", "metrics"),
 			slog.String("address", address),
 			slog.String("port", port),
+			slog.Bool("tls", certs != nil),
 		),
 	}
 
+	service.SetLogging(viper.GetBool("logging.metrics"))
+
+	// Find the address of the client first, so it can be used by all the
+	// other middleware, such as for logging
+	middleware.TrustProxies(router)
+	router.Use(service.logger(middleware.Logger()))
+	router.Use(middleware.Prometheus(name, "metrics"))
+	router.Use(gin.Recovery())
+
 	Attach(router)
 	alive.Attach(router)
 	healthz.Attach(router, service.health)
@@ -84,7 +97,24 @@ func NewService() *Service {
 	// Set up the default 404 handler
 	router.NoRoute(notFound)
 
-	return service
+	return service, nil
+}
+
+// Address returns the address to bind the metrics service to, which is the
+// `endpoints.bind.metrics-address` setting, if set, or otherwise the same
+// address as the web service, unless that is a Unix domain socket, as both
+// services cannot share the same socket, when `localhost` is used instead.
+func Address() string {
+	if address := viper.GetString("endpoints.bind.metrics-address"); address != "" {
+		return address
+	}
+
+	address := viper.GetString("endpoints.bind.address")
+	if strings.HasPrefix(address, listener.UnixPrefix) {
+		return defaultAddress
+	}
+
+	return address
 }
 
 func (s *Service) Start(e chan error) {
@@ -102,7 +132,7 @@ func (s *Service) Start(e chan error) {
 
 	s.health.Metrics = true
 
-	err := s.server.ListenAndServe()
+	err := certificate.Serve(s.server, s.listen, s.certs)
 	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		s.health.Metrics = false
 		slog.Error(
@@ -127,6 +157,34 @@ func (s *Service) SetMetricsHealth(status bool) {
 	s.health.Metrics = status
 }
 
+func (s *Service) SetStoreHealth(status bool) {
+	s.health.Store = status
+}
+
+func (s *Service) SetBrokerHealth(status bool) {
+	s.health.Broker = status
+}
+
+// SetLogging sets whether or not the requests to the metrics service are
+// logged, which can be changed at any time.
+func (s *Service) SetLogging(enabled bool) {
+	s.logging.Store(enabled)
+}
+
+// logger wraps the `handler` for logging requests so it is only used when
+// logging of the requests to the metrics service is enabled.
+func (s *Service) logger(handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.logging.Load() {
+			handler(c)
+
+			return
+		}
+
+		c.Next()
+	}
+}
+
 func (s *Service) Shutdown(timeout time.Duration) error {
 	slog.Info("Shutting down the metrics service", s.attr)
 
@@ -141,6 +199,10 @@ func (s *Service) Shutdown(timeout time.Duration) error {
 		return err
 	}
 
+	if err := s.certs.Close(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
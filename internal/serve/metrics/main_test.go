@@ -1 +1,43 @@
+//nolint:paralleltest // these tests cannot operate in parallel
 package metrics_test
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/n3tuk/dashboard/internal/serve/metrics"
+)
+
+// TestAddress tests that the metrics service is bound to the same address as
+// the web service, unless another address is set, or the web service is bound
+// to a Unix domain socket.
+func TestAddress(t *testing.T) {
+	tests := map[string]struct {
+		web      string
+		metrics  string
+		expected string
+	}{
+		"same address":  {web: "0.0.0.0", expected: "0.0.0.0"},
+		"other address": {web: "0.0.0.0", metrics: "127.0.0.1", expected: "127.0.0.1"},
+		"socket":        {web: "unix:/run/dashboard/web.sock", expected: "localhost"},
+		"other socket": {
+			web:      "unix:/run/dashboard/web.sock",
+			metrics:  "unix:/run/dashboard/metrics.sock",
+			expected: "unix:/run/dashboard/metrics.sock",
+		},
+	}
+
+	t.Cleanup(viper.Reset)
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			viper.Reset()
+			viper.Set("endpoints.bind.address", test.web)
+			viper.Set("endpoints.bind.metrics-address", test.metrics)
+
+			assert.Equal(t, test.expected, metrics.Address())
+		})
+	}
+}
@@ -0,0 +1,397 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+
+	"github.com/n3tuk/dashboard/internal/signature"
+
+	slogg "github.com/samber/slog-gin"
+)
+
+const (
+	// ScopeEventsRead allows the client to read events from the dashboard,
+	// including through the live event streams.
+	ScopeEventsRead = "events:read"
+	// ScopeEventsWrite allows the client to submit new or updated events to the
+	// dashboard.
+	ScopeEventsWrite = "events:write"
+
+	// keyContext is the name of the value in the Gin context which holds the API
+	// key used to authenticate the request.
+	keyContext = "dashboard.api-key"
+	// keyHeader is the alternative header to `Authorization` which can be used
+	// to provide the API key.
+	keyHeader = "X-API-Key"
+	// minSecretLength is the minimum length of the secret for a signed API key,
+	// so that it cannot be guessed from the signatures of its requests.
+	minSecretLength = 32
+)
+
+var (
+	// Scopes is the list of all the scopes which can be given to an API key.
+	Scopes = []string{ScopeEventsRead, ScopeEventsWrite}
+
+	// ErrInvalidKey is returned when the API key provided is not known.
+	ErrInvalidKey = errors.New("the API key provided is not valid")
+	// ErrExpiredKey is returned when the API key provided has expired.
+	ErrExpiredKey = errors.New("the API key provided has expired")
+	// ErrSignatureRequired is returned when an API key which can only be used
+	// to sign requests is sent with the request instead.
+	ErrSignatureRequired = errors.New("the API key provided can only be used to sign requests")
+	// ErrInvalidKeyConfig is returned when an API key in the configuration is
+	// not valid.
+	ErrInvalidKeyConfig = errors.New("invalid API key configuration")
+
+	// keyring holds the API keys currently in use, allowing them to be replaced
+	// without needing to restart the service.
+	keyring atomic.Pointer[Keyring]
+)
+
+// APIKey is a single key which can be used to authenticate with the dashboard,
+// where only the SHA-256 hash of the key is stored for keys sent with each
+// request, while signed keys must store the key itself as the secret needed to
+// verify the signatures.
+type APIKey struct {
+	// Name is the name used to identify the key in the logs.
+	Name string `mapstructure:"name"`
+	// Hash is the hex-encoded SHA-256 hash of the key.
+	Hash string `mapstructure:"hash"`
+	// Secret is the key itself, used to verify the signatures of requests made
+	// with a signed key.
+	Secret string `mapstructure:"secret"`
+	// Scopes is the list of scopes the key has been given.
+	Scopes []string `mapstructure:"scopes"`
+	// Expires is the time after which the key can no longer be used, if set.
+	Expires time.Time `mapstructure:"expires"`
+	// Signed sets whether the key can only be used to sign requests, with its
+	// secret, rather than being sent with the request itself.
+	Signed bool `mapstructure:"signed"`
+}
+
+// Keyring holds the set of API keys which can be used to authenticate with the
+// dashboard, along with the scopes given to requests without a key.
+type Keyring struct {
+	keys         map[string]*APIKey
+	fingerprints map[string]*APIKey
+	anonymous    []string
+	skew         time.Duration
+	maxBody      int64
+}
+
+// NewKeyring creates a new `Keyring` from the list of `keys`, giving the
+// `anonymous` scopes to all requests which do not provide a key, and returning
+// `ErrInvalidKeyConfig` if any of the keys or scopes are not valid.
+func NewKeyring(keys []*APIKey, anonymous []string) (*Keyring, error) {
+	k := &Keyring{
+		keys:         make(map[string]*APIKey, len(keys)),
+		fingerprints: make(map[string]*APIKey, len(keys)),
+		anonymous:    anonymous,
+		skew:         DefaultSkew,
+		maxBody:      DefaultMaxBody,
+	}
+
+	if err := checkScopes(anonymous); err != nil {
+		return nil, fmt.Errorf("%w: anonymous %w", ErrInvalidKeyConfig, err)
+	}
+
+	for i, key := range keys {
+		if key.Name == "" {
+			return nil, fmt.Errorf("%w: key %d must have a name", ErrInvalidKeyConfig, i)
+		}
+
+		if err := checkScopes(key.Scopes); err != nil {
+			return nil, fmt.Errorf("%w: key %q %w", ErrInvalidKeyConfig, key.Name, err)
+		}
+
+		if err := k.addSecret(key); err != nil {
+			return nil, err
+		}
+
+		// Signed keys only need a hash to report that they cannot be sent with
+		// the request, so it is optional for them
+		if key.Hash == "" && key.Signed {
+			continue
+		}
+
+		hash := strings.ToLower(key.Hash)
+
+		if sum, err := hex.DecodeString(hash); err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("%w: key %q must have a hex-encoded SHA-256 hash", ErrInvalidKeyConfig, key.Name)
+		}
+
+		if _, ok := k.keys[hash]; ok {
+			return nil, fmt.Errorf("%w: key %q has the same hash as another key", ErrInvalidKeyConfig, key.Name)
+		}
+
+		k.keys[hash] = key
+	}
+
+	return k, nil
+}
+
+// addSecret adds the `key` to the keys which can sign requests if it is a
+// signed key, checking that only signed keys have a secret, and that it is long
+// enough and not shared with another key.
+func (k *Keyring) addSecret(key *APIKey) error {
+	if !key.Signed {
+		if key.Secret != "" {
+			return fmt.Errorf("%w: key %q must be signed to have a secret", ErrInvalidKeyConfig, key.Name)
+		}
+
+		return nil
+	}
+
+	if len(key.Secret) < minSecretLength {
+		return fmt.Errorf("%w: key %q must have a secret of at least %d characters",
+			ErrInvalidKeyConfig, key.Name, minSecretLength)
+	}
+
+	fingerprint := signature.Fingerprint([]byte(key.Secret))
+	if _, ok := k.fingerprints[fingerprint]; ok {
+		return fmt.Errorf("%w: key %q has the same secret as another key", ErrInvalidKeyConfig, key.Name)
+	}
+
+	k.fingerprints[fingerprint] = key
+
+	return nil
+}
+
+// checkScopes checks that each of the `scopes` is known.
+func checkScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return fmt.Errorf("has an unknown scope %q", scope)
+		}
+	}
+
+	return nil
+}
+
+// Lookup finds the API key which matches the `token` provided by the client,
+// returning `ErrInvalidKey` if it is not known, `ErrSignatureRequired` if it can
+// only be used to sign requests, or `ErrExpiredKey` if it has expired at the
+// time `now`.
+func (k *Keyring) Lookup(token string, now time.Time) (*APIKey, error) {
+	sum := sha256.Sum256([]byte(token))
+
+	key, ok := k.keys[hex.EncodeToString(sum[:])]
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	if key.Signed {
+		return nil, ErrSignatureRequired
+	}
+
+	if !key.Expires.IsZero() && now.After(key.Expires) {
+		return nil, ErrExpiredKey
+	}
+
+	return key, nil
+}
+
+// Allows checks whether the `key` has been given the `scope`, or if the `key`
+// is nil, whether anonymous requests have been given it.
+func (k *Keyring) Allows(key *APIKey, scope string) bool {
+	if key == nil {
+		return slices.Contains(k.anonymous, scope)
+	}
+
+	return slices.Contains(key.Scopes, scope)
+}
+
+// HashKey returns the hex-encoded SHA-256 hash of the API `key`, as expected in
+// the configuration.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// LoadKeys creates a new `Keyring` from the `authentication` settings in the
+// configuration and sets it as the keyring used to authenticate requests.
+func LoadKeys() error {
+	k, err := readKeyring()
+	if err != nil {
+		return err
+	}
+
+	SetKeyring(k)
+
+	return nil
+}
+
+// readKeyring creates a new `Keyring` from the `authentication` settings in
+// the configuration, without setting it as the keyring in use.
+func readKeyring() (*Keyring, error) {
+	keys := []*APIKey{}
+
+	err := viper.UnmarshalKey("authentication.keys", &keys, viper.DecodeHook(
+		mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeHookFunc(time.RFC3339),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKeyConfig, err)
+	}
+
+	k, err := NewKeyring(keys, viper.GetStringSlice("authentication.anonymous"))
+	if err != nil {
+		return nil, err
+	}
+
+	if skew := viper.GetInt("authentication.signing.skew"); skew > 0 {
+		k.skew = time.Duration(skew) * time.Second
+	}
+
+	// Signed requests are limited to the same size as a batch of events, as
+	// the largest body which any of the endpoints will accept
+	if viper.IsSet("endpoints.batch.max-bytes") {
+		k.maxBody = viper.GetInt64("endpoints.batch.max-bytes")
+	}
+
+	return k, nil
+}
+
+// SetKeyring sets the keyring `k` as the one used to authenticate requests.
+func SetKeyring(k *Keyring) {
+	keyring.Store(k)
+}
+
+// currentKeyring returns the keyring used to authenticate requests, which, if
+// not yet set, has no keys and only allows anonymous requests to read events.
+func currentKeyring() *Keyring {
+	if k := keyring.Load(); k != nil {
+		return k
+	}
+
+	return &Keyring{
+		anonymous: []string{ScopeEventsRead},
+		skew:      DefaultSkew,
+		maxBody:   DefaultMaxBody,
+	}
+}
+
+// Key returns the API key used to authenticate the request, or nil if the
+// request did not provide one.
+func Key(c *gin.Context) *APIKey {
+	if value, ok := c.Get(keyContext); ok {
+		if key, ok := value.(*APIKey); ok {
+			return key
+		}
+	}
+
+	return nil
+}
+
+// Authorize provides the authentication of requests using the API key, if any,
+// provided through either the `Authorization: Bearer` or `X-API-Key` headers,
+// and then checks that the key (or anonymous requests, if no key is provided)
+// has been given the `scope` required to access the endpoint, responding with
+// either a 401 (Unauthorized) or 403 (Forbidden) if not.
+func Authorize(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		k := currentKeyring()
+
+		key := Key(c)
+		if key == nil {
+			var err error
+
+			key, err = authenticate(c, k)
+			if err != nil {
+				slog.Warn(
+					"Request made with an unusable API key",
+					slog.Group("error", slog.String("message", err.Error())),
+					slog.String("path", c.Request.URL.Path),
+					slog.String("client", c.ClientIP()),
+				)
+
+				unauthorized(c, "The API key or signature provided is either not valid or has expired")
+
+				return
+			}
+
+			if key != nil {
+				c.Set(keyContext, key)
+				slogg.AddCustomAttributes(c, slog.String("api-key", key.Name))
+			}
+		}
+
+		if !k.Allows(key, scope) {
+			if key == nil {
+				unauthorized(c, fmt.Sprintf("An API key with the %s scope is required", scope))
+			} else {
+				forbidden(c, scope)
+			}
+
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// authenticate finds the API key used by the request, either from the signature
+// of the request, if signed, or from the key provided in the headers, returning
+// nil if neither were provided, or an error if the key cannot be used.
+func authenticate(c *gin.Context, k *Keyring) (*APIKey, error) {
+	if c.GetHeader(signature.SignatureHeader) != "" {
+		return k.verify(c, time.Now())
+	}
+
+	token := credentials(c)
+	if token == "" {
+		return nil, nil //nolint:nilnil // no key is valid for anonymous requests
+	}
+
+	return k.Lookup(token, time.Now())
+}
+
+// credentials returns the API key provided by the client in the request, or an
+// empty string if none was provided.
+func credentials(c *gin.Context) string {
+	if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+
+	return strings.TrimSpace(c.GetHeader(keyHeader))
+}
+
+// unauthorized provides the response for requests which did not provide a
+// valid API key, with the `message` explaining why, necessitating a 401
+// (Unauthorized) response back to the client.
+func unauthorized(c *gin.Context, message string) {
+	c.Header("WWW-Authenticate", `Bearer realm="dashboard"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+		"code":    http.StatusUnauthorized,
+		"status":  "unauthorized",
+		"message": message,
+		"path":    c.Request.URL.Path,
+	})
+}
+
+// forbidden provides the response for requests with an API key which has not
+// been given the `scope` required to access the path, necessitating a 403
+// (Forbidden) response back to the client.
+func forbidden(c *gin.Context, scope string) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+		"code":    http.StatusForbidden,
+		"status":  "forbidden",
+		"message": fmt.Sprintf("The API key provided does not have the %s scope", scope),
+		"path":    c.Request.URL.Path,
+	})
+}
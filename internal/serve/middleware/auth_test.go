@@ -0,0 +1,167 @@
+//nolint:paralleltest // these tests cannot operate in parallel
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/serve/middleware"
+)
+
+// newRouter creates a new Gin engine with an endpoint for each scope, using
+// the keys `write`, `read`, and `expired`.
+func newRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	keyring, err := middleware.NewKeyring([]*middleware.APIKey{
+		{
+			Name:   "write",
+			Hash:   middleware.HashKey("write"),
+			Scopes: []string{middleware.ScopeEventsWrite, middleware.ScopeEventsRead},
+		},
+		{
+			Name:   "read",
+			Hash:   middleware.HashKey("read"),
+			Scopes: []string{middleware.ScopeEventsRead},
+		},
+		{
+			Name:    "expired",
+			Hash:    middleware.HashKey("expired"),
+			Scopes:  []string{middleware.ScopeEventsWrite},
+			Expires: time.Now().Add(-time.Hour),
+		},
+	}, []string{middleware.ScopeEventsRead})
+	require.NoError(t, err)
+
+	middleware.SetKeyring(keyring)
+
+	router := gin.New()
+	router.GET("/read", middleware.Authorize(middleware.ScopeEventsRead), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.POST("/write", middleware.Authorize(middleware.ScopeEventsWrite), func(c *gin.Context) {
+		c.String(http.StatusOK, middleware.Key(c).Name)
+	})
+
+	return router
+}
+
+// TestAuthorize tests that requests are allowed or rejected based on the API
+// key provided, and the scopes it has been given.
+func TestAuthorize(t *testing.T) {
+	router := newRouter(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header string
+		value  string
+		code   int
+	}{
+		{"anonymous read", http.MethodGet, "/read", "", "", http.StatusOK},
+		{"anonymous write", http.MethodPost, "/write", "", "", http.StatusUnauthorized},
+		{"bearer write", http.MethodPost, "/write", "Authorization", "Bearer write", http.StatusOK},
+		{"header write", http.MethodPost, "/write", "X-API-Key", "write", http.StatusOK},
+		{"read-only write", http.MethodPost, "/write", "Authorization", "Bearer read", http.StatusForbidden},
+		{"unknown key", http.MethodGet, "/read", "Authorization", "Bearer unknown", http.StatusUnauthorized},
+		{"expired key", http.MethodPost, "/write", "X-API-Key", "expired", http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(test.method, test.path, nil)
+
+			if test.header != "" {
+				r.Header.Set(test.header, test.value)
+			}
+
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, test.code, w.Code)
+
+			if test.code == http.StatusUnauthorized {
+				assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+				assert.Contains(t, w.Body.String(), `"status":"unauthorized"`)
+			}
+		})
+	}
+}
+
+// TestLoadKeys tests that the keys are loaded from the configuration.
+func TestLoadKeys(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	viper.Set("authentication.anonymous", []string{})
+	viper.Set("authentication.keys", []map[string]any{
+		{
+			"name":    "ci",
+			"hash":    middleware.HashKey("secret"),
+			"scopes":  []string{middleware.ScopeEventsWrite},
+			"expires": time.Now().Add(time.Hour).Format(time.RFC3339),
+		},
+		{
+			"name":   "signed",
+			"secret": strings.Repeat("s", 32),
+			"scopes": []string{middleware.ScopeEventsWrite},
+			"signed": true,
+		},
+	})
+
+	require.NoError(t, middleware.LoadKeys())
+
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/read", middleware.Authorize(middleware.ScopeEventsRead), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.POST("/write", middleware.Authorize(middleware.ScopeEventsWrite), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/read", nil)
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/write", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+// TestNewKeyringInvalid tests that keys with invalid settings are rejected.
+func TestNewKeyringInvalid(t *testing.T) {
+	tests := map[string]*middleware.APIKey{
+		"missing name":  {Hash: middleware.HashKey("key")},
+		"invalid hash":  {Name: "key", Hash: "not-a-hash"},
+		"unknown scope": {Name: "key", Hash: middleware.HashKey("key"), Scopes: []string{"events:delete"}},
+		"missing hash":  {Name: "key"},
+		"unsigned with secret": {
+			Name: "key", Hash: middleware.HashKey("key"), Secret: strings.Repeat("s", 32),
+		},
+		"signed without secret": {Name: "key", Hash: middleware.HashKey("key"), Signed: true},
+		"signed short secret":   {Name: "key", Secret: "secret", Signed: true},
+	}
+
+	for name, key := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := middleware.NewKeyring([]*middleware.APIKey{key}, nil)
+			require.ErrorIs(t, err, middleware.ErrInvalidKeyConfig)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 
@@ -9,11 +10,32 @@ import (
 	slogg "github.com/samber/slog-gin"
 )
 
+// writerKey is the key in the Gin context holding the writer for the response
+// from before it was wrapped by the logger.
+const writerKey = "middleware.writer"
+
+// deferred is a `slog.Handler` which passes each record to the handler of the
+// default logger at the time the record is logged, rather than when the Gin
+// logger was created, so that changes to the logging configuration (such as
+// after a reload) are used for the requests as well.
+type deferred struct {
+	wrap func(slog.Handler) slog.Handler
+}
+
+// unwrapper wraps the writer for the response once wrapped by the logger, which
+// hides the features of the original writer (such as setting deadlines), so
+// that `http.ResponseController` can find them through `Unwrap`.
+type unwrapper struct {
+	gin.ResponseWriter
+
+	original http.ResponseWriter
+}
+
 // Logger provides a structured logging logger which can be used by Gin using
 // the new slog package, allowing for easy processing of log data.
 func Logger() gin.HandlerFunc {
-	return slogg.NewWithConfig(
-		slog.Default().WithGroup("gin"),
+	logger := slogg.NewWithConfig(
+		slog.New(&deferred{wrap: func(h slog.Handler) slog.Handler { return h }}).WithGroup("gin"),
 		slogg.Config{
 			WithRequestID: true,
 			Filters: []slogg.Filter{
@@ -24,4 +46,61 @@ func Logger() gin.HandlerFunc {
 			},
 		},
 	)
+
+	return func(c *gin.Context) {
+		// Keep the original writer, as the logger replaces it with one which
+		// cannot be unwrapped, so that `Unwrap` can restore access to it
+		c.Set(writerKey, c.Writer)
+
+		logger(c)
+	}
+}
+
+// Unwrap provides the middleware which allows the features of the original
+// writer for the response, such as removing the write deadline, to be used
+// through `http.ResponseController` once the writer has been wrapped by the
+// logger, for endpoints which need them, such as long-lived streams.
+func Unwrap(c *gin.Context) {
+	if value, ok := c.Get(writerKey); ok {
+		if original, ok := value.(gin.ResponseWriter); ok && original != c.Writer {
+			c.Writer = &unwrapper{ResponseWriter: c.Writer, original: original}
+		}
+	}
+
+	c.Next()
+}
+
+// Unwrap returns the original writer for the response, from before it was
+// wrapped by the logger.
+func (u *unwrapper) Unwrap() http.ResponseWriter {
+	return u.original
+}
+
+// handler returns the handler of the current default logger, with any of the
+// attributes and groups added to this handler.
+func (d *deferred) handler() slog.Handler {
+	return d.wrap(slog.Default().Handler())
+}
+
+// Enabled reports whether the current default handler handles records at the
+// `level`.
+func (d *deferred) Enabled(ctx context.Context, level slog.Level) bool {
+	return d.handler().Enabled(ctx, level)
+}
+
+// Handle passes the record `r` to the current default handler.
+func (d *deferred) Handle(ctx context.Context, r slog.Record) error {
+	return d.handler().Handle(ctx, r)
+}
+
+// WithAttrs returns a new handler which adds the `attrs` to the current
+// default handler.
+func (d *deferred) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &deferred{wrap: func(h slog.Handler) slog.Handler { return d.wrap(h).WithAttrs(attrs) }}
+}
+
+// WithGroup returns a new handler which adds the group `name` to the current
+// default handler.
+func (d *deferred) WithGroup(name string) slog.Handler {
+	return &deferred{wrap: func(h slog.Handler) slog.Handler { return d.wrap(h).WithGroup(name) }}
 }
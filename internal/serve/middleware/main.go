@@ -1 +1,32 @@
 package middleware
+
+import (
+	"github.com/spf13/viper"
+)
+
+// Load reads the trusted proxies, the rate limits, and the API keys from the
+// configuration, and only once all of them are known to be valid, sets them
+// all together, so that a configuration which is only partly valid is never
+// applied. If any of them are not valid, the current settings are all kept.
+func Load() error {
+	networks, err := parseProxies(viper.GetStringSlice("endpoints.proxies"))
+	if err != nil {
+		return err
+	}
+
+	list := readRateLimits()
+	if err := checkRateLimits(list); err != nil {
+		return err
+	}
+
+	k, err := readKeyring()
+	if err != nil {
+		return err
+	}
+
+	proxies.Store(&networks)
+	limits.Store(&list)
+	keyring.Store(k)
+
+	return nil
+}
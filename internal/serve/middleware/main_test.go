@@ -0,0 +1,46 @@
+//nolint:paralleltest // these tests cannot operate in parallel
+package middleware_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/serve/middleware"
+)
+
+// TestLoad tests that the trusted proxies, rate limits, and API keys are only
+// applied once all of them are valid, so none are changed if any one fails.
+func TestLoad(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	gin.SetMode(gin.TestMode)
+
+	viper.Set("endpoints.proxies", []string{"127.0.0.1"})
+	require.NoError(t, middleware.Load())
+
+	router := gin.New()
+	middleware.TrustProxies(router)
+	router.GET("/ip", func(c *gin.Context) {
+		c.String(http.StatusOK, c.ClientIP())
+	})
+
+	assert.Equal(t, "198.51.100.7", clientIP(router, "127.0.0.1", "198.51.100.7"))
+
+	viper.Set("endpoints.proxies", []string{"10.0.0.0/8"})
+	viper.Set("endpoints.rate-limits.events.rate", -1)
+	require.ErrorIs(t, middleware.Load(), middleware.ErrInvalidRateLimit)
+
+	viper.Set("endpoints.rate-limits.events.rate", 1)
+	viper.Set("endpoints.rate-limits.events.burst", 1)
+	viper.Set("authentication.keys", []map[string]any{{"scopes": []string{}}})
+	require.ErrorIs(t, middleware.Load(), middleware.ErrInvalidKeyConfig)
+
+	assert.Equal(t, "198.51.100.7", clientIP(router, "127.0.0.1", "198.51.100.7"))
+	assert.Equal(t, "10.1.2.3", clientIP(router, "10.1.2.3", "198.51.100.7"))
+}
@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// clientIPHeader is the header set on each request with the address of the
+// client, as found through the trusted proxies, which is then given to Gin as
+// the header of a trusted platform to provide the address to the handlers.
+const clientIPHeader = "X-Dashboard-Client-Ip"
+
+var (
+	// ErrInvalidProxy is returned when a trusted proxy is neither an IPv4 or
+	// IPv6 address, nor a CIDR.
+	ErrInvalidProxy = errors.New("the trusted proxy is not a valid address or CIDR")
+
+	// proxies holds the networks of the trusted proxies.
+	proxies atomic.Pointer[[]*net.IPNet]
+)
+
+// LoadProxies sets the trusted proxies from the `endpoints.proxies` setting in
+// the configuration, which can be called again to change them at any time.
+func LoadProxies() error {
+	return SetProxies(viper.GetStringSlice("endpoints.proxies"))
+}
+
+// SetProxies sets the `list` of IPv4 and/or IPv6 addresses or CIDRs which are
+// trusted to provide the address of the client. If any of them are not valid,
+// the current trusted proxies are kept.
+func SetProxies(list []string) error {
+	networks, err := parseProxies(list)
+	if err != nil {
+		return err
+	}
+
+	proxies.Store(&networks)
+
+	return nil
+}
+
+// parseProxies parses the `list` of IPv4 and/or IPv6 addresses or CIDRs into
+// the networks of the trusted proxies, returning `ErrInvalidProxy` if any of
+// them are not valid.
+func parseProxies(list []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(list))
+
+	for _, proxy := range list {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidProxy, proxy)
+			}
+
+			bits := net.IPv6len * 8
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = net.IPv4len * 8
+			}
+
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidProxy, proxy)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// TrustProxies configures the Gin engine `r` to find the address of the client
+// through the trusted proxies, which, unlike the trusted proxies in Gin, can be
+// safely changed while the service is running.
+func TrustProxies(r *gin.Engine) {
+	// Never trust any proxies in Gin itself, and instead only trust the header
+	// set by the middleware below with the address found for the client
+	_ = r.SetTrustedProxies(nil)
+	r.TrustedPlatform = clientIPHeader
+
+	headers := r.RemoteIPHeaders
+
+	r.Use(func(c *gin.Context) {
+		if ip := clientIP(c.Request, headers); ip != "" {
+			c.Request.Header.Set(clientIPHeader, ip)
+		} else {
+			c.Request.Header.Del(clientIPHeader)
+		}
+
+		c.Next()
+	})
+}
+
+// clientIP returns the address of the client for the request `r`, which is
+// either the remote address of the connection, or, if that is a trusted proxy,
+// the first address in the `headers` which was not added by a trusted proxy.
+// Connections over a Unix domain socket have no remote address, and can only
+// be made from the same host, so they are always treated as trusted proxies.
+func clientIP(r *http.Request, headers []string) string {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil {
+		for _, header := range headers {
+			if ip, ok := forwarded(r.Header.Get(header)); ok {
+				return ip
+			}
+		}
+
+		return ""
+	}
+
+	remote := net.ParseIP(host)
+	if remote == nil {
+		return ""
+	}
+
+	if !trusted(remote) {
+		return remote.String()
+	}
+
+	for _, header := range headers {
+		if ip, ok := forwarded(r.Header.Get(header)); ok {
+			return ip
+		}
+	}
+
+	return remote.String()
+}
+
+// forwarded returns the address of the client from the `header` listing the
+// addresses of the client and each of the proxies, by working back from the
+// last address until one is found which is not a trusted proxy.
+func forwarded(header string) (string, bool) {
+	if header == "" {
+		return "", false
+	}
+
+	items := strings.Split(header, ",")
+	for i := len(items) - 1; i >= 0; i-- {
+		address := strings.TrimSpace(items[i])
+
+		ip := net.ParseIP(address)
+		if ip == nil {
+			break
+		}
+
+		if i == 0 || !trusted(ip) {
+			return address, true
+		}
+	}
+
+	return "", false
+}
+
+// trusted checks whether the address `ip` is one of the trusted proxies.
+func trusted(ip net.IP) bool {
+	networks := proxies.Load()
+	if networks == nil {
+		return false
+	}
+
+	for _, network := range *networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
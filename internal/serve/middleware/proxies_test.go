@@ -0,0 +1,112 @@
+//nolint:paralleltest // these tests cannot operate in parallel
+package middleware_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/serve/middleware"
+)
+
+// clientIP makes a request to the `router` from the `remote` address with the
+// `forwarded` header, returning the address of the client seen by Gin.
+func clientIP(router *gin.Engine, remote, forwarded string) string {
+	r := httptest.NewRequest(http.MethodGet, "/ip", nil)
+	r.RemoteAddr = net.JoinHostPort(remote, "54321")
+	r.Header.Set("X-Dashboard-Client-Ip", "203.0.113.99")
+
+	if forwarded != "" {
+		r.Header.Set("X-Forwarded-For", forwarded)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	return w.Body.String()
+}
+
+// TestTrustProxies tests that the address of the client is only taken from
+// the headers when the request comes through a trusted proxy, and that the
+// trusted proxies can be changed after the router has been created.
+func TestTrustProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	require.NoError(t, middleware.SetProxies([]string{"127.0.0.1", "10.0.0.0/8"}))
+
+	router := gin.New()
+	middleware.TrustProxies(router)
+	router.GET("/ip", func(c *gin.Context) {
+		c.String(http.StatusOK, c.ClientIP())
+	})
+
+	assert.Equal(t, "192.0.2.1", clientIP(router, "192.0.2.1", ""))
+	assert.Equal(t, "192.0.2.1", clientIP(router, "192.0.2.1", "198.51.100.7"))
+	assert.Equal(t, "198.51.100.7", clientIP(router, "127.0.0.1", "198.51.100.7"))
+	assert.Equal(t, "198.51.100.7", clientIP(router, "127.0.0.1", "198.51.100.7, 10.1.2.3"))
+	assert.Equal(t, "127.0.0.1", clientIP(router, "127.0.0.1", "not-an-address"))
+
+	require.NoError(t, middleware.SetProxies([]string{"::1"}))
+
+	assert.Equal(t, "127.0.0.1", clientIP(router, "127.0.0.1", "198.51.100.7"))
+	assert.Equal(t, "198.51.100.7", clientIP(router, "::1", "198.51.100.7"))
+}
+
+// TestSetProxiesInvalid tests that invalid proxies are rejected, keeping the
+// current trusted proxies.
+func TestSetProxiesInvalid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	require.NoError(t, middleware.SetProxies([]string{"127.0.0.1"}))
+
+	for _, proxy := range []string{"localhost", "10.0.0.0/33", ""} {
+		err := middleware.SetProxies([]string{proxy})
+		require.ErrorIs(t, err, middleware.ErrInvalidProxy, proxy)
+	}
+
+	router := gin.New()
+	middleware.TrustProxies(router)
+	router.GET("/ip", func(c *gin.Context) {
+		c.String(http.StatusOK, c.ClientIP())
+	})
+
+	assert.Equal(t, "198.51.100.7", clientIP(router, "127.0.0.1", "198.51.100.7"))
+}
+
+// TestTrustProxiesUnix tests that the address of the client is always taken
+// from the headers for connections over a Unix domain socket, which have no
+// remote address of their own.
+func TestTrustProxiesUnix(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	require.NoError(t, middleware.SetProxies([]string{"10.0.0.0/8"}))
+
+	router := gin.New()
+	middleware.TrustProxies(router)
+	router.GET("/ip", func(c *gin.Context) {
+		c.String(http.StatusOK, c.ClientIP())
+	})
+
+	for forwarded, expected := range map[string]string{
+		"":                       "",
+		"198.51.100.7":           "198.51.100.7",
+		"198.51.100.7, 10.1.2.3": "198.51.100.7",
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/ip", nil)
+		r.RemoteAddr = "@"
+
+		if forwarded != "" {
+			r.Header.Set("X-Forwarded-For", forwarded)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, expected, w.Body.String(), forwarded)
+	}
+}
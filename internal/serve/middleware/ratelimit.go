@@ -0,0 +1,280 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/spf13/viper"
+)
+
+const (
+	// EventsGroup is the name of the group of routes for submitting events,
+	// which is the only group with a rate limit.
+	EventsGroup = "events"
+
+	// sweepInterval is the time between removing the buckets for clients which
+	// have not made any requests recently, and so have a full bucket again.
+	sweepInterval = time.Minute
+	// costContext is the name of the value in the Gin context which holds the
+	// number of tokens the request takes from the bucket of the client.
+	costContext = "dashboard.rate-limit-cost"
+)
+
+var (
+	limited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: "http",
+		Name:      "rate_limited_total",
+		Help:      "Count of HTTP requests rejected by the rate limits.",
+	}, []string{"cluster", "component", "group"})
+
+	// ErrInvalidRateLimit is returned when a rate limit in the configuration is
+	// not valid.
+	ErrInvalidRateLimit = errors.New("invalid rate limit configuration")
+
+	// limits holds the rate limits for each group of routes, allowing them to
+	// be replaced without needing to restart the service.
+	limits atomic.Pointer[map[string]Limit]
+
+	// groups holds the names of the groups of routes which have a rate limit,
+	// so that the limits for any other group are rejected rather than ignored.
+	groups = []string{EventsGroup}
+)
+
+// Limit is the rate limit for a group of routes, which allows each client to
+// make a `Burst` of requests at once, and then `Rate` requests each second.
+type Limit struct {
+	// Rate is the number of requests added to the bucket of each client every
+	// second, where zero disables the rate limit.
+	Rate float64 `mapstructure:"rate"`
+	// Burst is the maximum number of requests held in the bucket of each
+	// client, which can be made at once.
+	Burst int `mapstructure:"burst"`
+}
+
+// LoadRateLimits sets the rate limits for each group of routes from the
+// `endpoints.rate-limits` settings in the configuration, which can be called
+// again to change them at any time.
+func LoadRateLimits() error {
+	return SetRateLimits(readRateLimits())
+}
+
+// readRateLimits returns the rate limits for each group of routes from the
+// `endpoints.rate-limits` settings in the configuration.
+func readRateLimits() map[string]Limit {
+	prefix := "endpoints.rate-limits."
+	list := map[string]Limit{}
+
+	// Find the groups from the individual keys, rather than the map of groups,
+	// so that the defaults are used for any settings not in the configuration
+	for _, key := range viper.AllKeys() {
+		name, _, ok := strings.Cut(strings.TrimPrefix(key, prefix), ".")
+		if !strings.HasPrefix(key, prefix) || !ok {
+			continue
+		}
+
+		list[name] = Limit{
+			Rate:  viper.GetFloat64(prefix + name + ".rate"),
+			Burst: viper.GetInt(prefix + name + ".burst"),
+		}
+	}
+
+	return list
+}
+
+// SetRateLimits sets the `list` of rate limits for each group of routes. If
+// any of them are not valid, the current rate limits are kept.
+func SetRateLimits(list map[string]Limit) error {
+	if err := checkRateLimits(list); err != nil {
+		return err
+	}
+
+	limits.Store(&list)
+
+	return nil
+}
+
+// checkRateLimits checks each of the rate limits in the `list`, returning
+// `ErrInvalidRateLimit` if any of them are not valid, or are not for a known
+// group of routes.
+func checkRateLimits(list map[string]Limit) error {
+	for name, limit := range list {
+		if !slices.Contains(groups, name) {
+			return fmt.Errorf("%w: %s is not a group of routes with a rate limit", ErrInvalidRateLimit, name)
+		}
+
+		if limit.Rate < 0 {
+			return fmt.Errorf("%w: the rate for %s cannot be negative", ErrInvalidRateLimit, name)
+		}
+
+		if limit.Rate > 0 && limit.Burst < 1 {
+			return fmt.Errorf("%w: the burst for %s must be at least one", ErrInvalidRateLimit, name)
+		}
+	}
+
+	return nil
+}
+
+// bucket holds the number of `tokens` (requests) a client can make, as of the
+// time it was last `updated`.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// refill adds the tokens to the bucket for the time since it was last updated
+// until `now`, up to the burst of the `limit`.
+func (b *bucket) refill(limit Limit, now time.Time) {
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*limit.Rate)
+	b.updated = now
+}
+
+// buckets holds the bucket for each client of a group of routes.
+type buckets struct {
+	mutex   sync.Mutex
+	clients map[string]*bucket
+	swept   time.Time
+}
+
+// take takes `cost` tokens from the bucket of the `client` at the time `now`,
+// returning whether or not it was allowed, the bucket after the request, and
+// the number of tokens needed in the bucket for the request to be allowed. As
+// a request can cost more than the burst of the `limit`, it is allowed once the
+// bucket is full, leaving the bucket in debt until it is refilled.
+func (b *buckets) take(client string, limit Limit, cost float64, now time.Time) (bool, bucket, float64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.sweep(limit, now)
+
+	current, ok := b.clients[client]
+	if !ok {
+		current = &bucket{tokens: float64(limit.Burst), updated: now}
+		b.clients[client] = current
+	}
+
+	current.refill(limit, now)
+
+	needed := math.Min(cost, float64(limit.Burst))
+	if current.tokens < needed {
+		return false, *current, needed
+	}
+
+	current.tokens -= cost
+
+	return true, *current, needed
+}
+
+// sweep removes the buckets which would be full at the time `now`, as these
+// clients have not made any requests recently, so are no longer limited.
+func (b *buckets) sweep(limit Limit, now time.Time) {
+	if now.Sub(b.swept) < sweepInterval {
+		return
+	}
+
+	b.swept = now
+
+	for client, current := range b.clients {
+		current.refill(limit, now)
+
+		if current.tokens >= float64(limit.Burst) {
+			delete(b.clients, client)
+		}
+	}
+}
+
+// RateLimit provides a token bucket rate limit for the routes in the `group`,
+// using the limit set for the group in the configuration, where each client is
+// identified by the name of its API key (so it must be used after `Authorize`),
+// or by its address for anonymous requests, and each request takes one token,
+// unless set otherwise through `SetCost`. Requests over the limit are given a
+// 429 (Too Many Requests) response, and counted against the `cluster` and the
+// `component`.
+func RateLimit(cluster, component, group string) gin.HandlerFunc {
+	b := &buckets{clients: map[string]*bucket{}}
+	counter := limited.WithLabelValues(cluster, component, group)
+
+	return func(c *gin.Context) {
+		list := limits.Load()
+		if list == nil {
+			c.Next()
+
+			return
+		}
+
+		limit, ok := (*list)[group]
+		if !ok || limit.Rate == 0 {
+			c.Next()
+
+			return
+		}
+
+		client := "address:" + c.ClientIP()
+		if key := Key(c); key != nil {
+			client = "key:" + key.Name
+		}
+
+		allowed, current, needed := b.take(client, limit, cost(c), time.Now())
+
+		// Time until the bucket is full again, and until there are enough tokens
+		// for the same request to be allowed
+		reset := (float64(limit.Burst) - current.tokens) / limit.Rate
+		next := (needed - current.tokens) / limit.Rate
+
+		c.Header("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		c.Header("RateLimit-Remaining", strconv.Itoa(max(int(current.tokens), 0)))
+		c.Header("RateLimit-Reset", strconv.Itoa(int(math.Ceil(reset))))
+
+		if !allowed {
+			counter.Inc()
+			tooManyRequests(c, int(math.Ceil(next)))
+
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// SetCost sets the number of tokens the request takes from the bucket of the
+// client when checked by `RateLimit`, such as for each event in a batch, which
+// must be set before the rate limit is checked.
+func SetCost(c *gin.Context, tokens int) {
+	c.Set(costContext, tokens)
+}
+
+// cost returns the number of tokens the request takes from the bucket of the
+// client, which is one unless set through `SetCost`.
+func cost(c *gin.Context) float64 {
+	if tokens, ok := c.Get(costContext); ok {
+		if n, ok := tokens.(int); ok && n > 0 {
+			return float64(n)
+		}
+	}
+
+	return 1
+}
+
+// tooManyRequests provides the response for requests which are over the rate
+// limit, telling the client to retry after the number of `seconds` when it can
+// make another request, necessitating a 429 (Too Many Requests) response back
+// to the client.
+func tooManyRequests(c *gin.Context, seconds int) {
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"code":    http.StatusTooManyRequests,
+		"status":  "too-many-requests",
+		"message": fmt.Sprintf("The rate limit has been reached, so retry after %d seconds", seconds),
+		"path":    c.Request.URL.Path,
+	})
+}
@@ -0,0 +1,211 @@
+//nolint:paralleltest // these tests cannot operate in parallel
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/serve/middleware"
+)
+
+// newLimitedRouter creates a new Gin engine with an endpoint limited by the
+// rate limit for the `events` group, which can be used with the keys `write`
+// and `read`, or anonymously.
+func newLimitedRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	keyring, err := middleware.NewKeyring([]*middleware.APIKey{
+		{
+			Name:   "write",
+			Hash:   middleware.HashKey("write"),
+			Scopes: []string{middleware.ScopeEventsRead},
+		},
+		{
+			Name:   "read",
+			Hash:   middleware.HashKey("read"),
+			Scopes: []string{middleware.ScopeEventsRead},
+		},
+	}, []string{middleware.ScopeEventsRead})
+	require.NoError(t, err)
+
+	middleware.SetKeyring(keyring)
+
+	router := gin.New()
+	router.GET(
+		"/limited",
+		middleware.Authorize(middleware.ScopeEventsRead),
+		middleware.RateLimit("test", "web", "events"),
+		func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		},
+	)
+
+	return router
+}
+
+// limitedRequest makes a request to the limited endpoint with the API `key`
+// (if set) from the `remote` address.
+func limitedRequest(router *gin.Engine, key, remote string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/limited", nil)
+	r.RemoteAddr = remote + ":54321"
+
+	if key != "" {
+		r.Header.Set("X-API-Key", key)
+	}
+
+	router.ServeHTTP(w, r)
+
+	return w
+}
+
+// TestRateLimit tests that each client can make a burst of requests before
+// being rejected, with the headers describing the rate limit on each response.
+func TestRateLimit(t *testing.T) {
+	require.NoError(t, middleware.SetRateLimits(map[string]middleware.Limit{
+		"events": {Rate: 0.001, Burst: 2},
+	}))
+
+	router := newLimitedRouter(t)
+
+	w := limitedRequest(router, "write", "192.0.2.1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.NotEmpty(t, w.Header().Get("RateLimit-Reset"))
+
+	w = limitedRequest(router, "write", "192.0.2.1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	w = limitedRequest(router, "write", "192.0.2.2")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "1000", w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{
+		"code": 429,
+		"status": "too-many-requests",
+		"message": "The rate limit has been reached, so retry after 1000 seconds",
+		"path": "/limited"
+	}`, w.Body.String())
+
+	// Other API keys, and anonymous clients, have their own limits
+	assert.Equal(t, http.StatusOK, limitedRequest(router, "read", "192.0.2.1").Code)
+	assert.Equal(t, http.StatusOK, limitedRequest(router, "", "192.0.2.1").Code)
+	assert.Equal(t, http.StatusOK, limitedRequest(router, "", "192.0.2.1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, limitedRequest(router, "", "192.0.2.1").Code)
+	assert.Equal(t, http.StatusOK, limitedRequest(router, "", "192.0.2.2").Code)
+}
+
+// TestRateLimitCost tests that requests which cost more than one token, such
+// as batches of events, take that many tokens from the bucket, and that those
+// costing more than the burst are allowed once the bucket is full.
+func TestRateLimitCost(t *testing.T) {
+	require.NoError(t, middleware.SetRateLimits(map[string]middleware.Limit{
+		"events": {Rate: 0.001, Burst: 4},
+	}))
+
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET(
+		"/limited",
+		func(c *gin.Context) {
+			cost, _ := strconv.Atoi(c.Query("cost"))
+			middleware.SetCost(c, cost)
+		},
+		middleware.RateLimit("test", "web", "events"),
+		func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		},
+	)
+
+	request := func(remote, cost string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/limited?cost="+cost, nil)
+		r.RemoteAddr = remote + ":54321"
+		router.ServeHTTP(w, r)
+
+		return w
+	}
+
+	w := request("192.0.2.1", "3")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+
+	w = request("192.0.2.1", "3")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2000", w.Header().Get("Retry-After"))
+
+	w = request("192.0.2.1", "1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	w = request("192.0.2.2", "10")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	w = request("192.0.2.2", "1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "7000", w.Header().Get("Retry-After"))
+}
+
+// TestRateLimitDisabled tests that requests are not limited when there is no
+// rate limit for the group, or its rate is zero.
+func TestRateLimitDisabled(t *testing.T) {
+	for _, list := range []map[string]middleware.Limit{
+		{},
+		{"events": {Rate: 0, Burst: 1}},
+	} {
+		require.NoError(t, middleware.SetRateLimits(list))
+
+		router := newLimitedRouter(t)
+
+		for range 5 {
+			w := limitedRequest(router, "", "192.0.2.1")
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+		}
+	}
+}
+
+// TestLoadRateLimits tests that the rate limits are loaded from the
+// configuration, using the defaults for any settings not set, and that invalid
+// rate limits are rejected.
+func TestLoadRateLimits(t *testing.T) {
+	viper.Reset()
+	viper.SetDefault("endpoints.rate-limits.events.rate", 10)
+	viper.SetDefault("endpoints.rate-limits.events.burst", 1)
+	viper.Set("endpoints.rate-limits.events.rate", 0.001)
+
+	require.NoError(t, middleware.LoadRateLimits())
+
+	router := newLimitedRouter(t)
+	assert.Equal(t, http.StatusOK, limitedRequest(router, "", "192.0.2.1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, limitedRequest(router, "", "192.0.2.1").Code)
+
+	viper.Set("endpoints.rate-limits.events.rate", -1)
+	require.ErrorIs(t, middleware.LoadRateLimits(), middleware.ErrInvalidRateLimit)
+
+	viper.Set("endpoints.rate-limits.events.rate", 1)
+	viper.Set("endpoints.rate-limits.events.burst", 0)
+	require.ErrorIs(t, middleware.LoadRateLimits(), middleware.ErrInvalidRateLimit)
+
+	// Limits for groups of routes without a rate limit are rejected, rather
+	// than being silently ignored
+	viper.Set("endpoints.rate-limits.events.burst", 1)
+	viper.Set("endpoints.rate-limits.other.rate", 5)
+	viper.Set("endpoints.rate-limits.other.burst", 5)
+	require.ErrorIs(t, middleware.LoadRateLimits(), middleware.ErrInvalidRateLimit)
+
+	viper.Reset()
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/n3tuk/dashboard/internal/signature"
+)
+
+const (
+	// DefaultSkew is the default maximum difference allowed between the time a
+	// request was signed and the time it was received.
+	DefaultSkew = 5 * time.Minute
+	// DefaultMaxBody is the default maximum size (in bytes) of the body of a
+	// signed request which will be read to verify the signature.
+	DefaultMaxBody = 1 << 20
+)
+
+var (
+	// ErrInvalidSignature is returned when the signature of a request cannot be
+	// verified with any known API key.
+	ErrInvalidSignature = errors.New("the signature provided is not valid")
+	// ErrStaleSignature is returned when the timestamp of a signed request is
+	// outside of the allowed skew from the current time.
+	ErrStaleSignature = errors.New("the signature provided has expired")
+	// ErrReplayedSignature is returned when the nonce of a signed request has
+	// already been seen.
+	ErrReplayedSignature = errors.New("the signature provided has already been used")
+
+	// nonces holds the nonces of the signed requests recently received.
+	nonces = &nonceCache{seen: map[string]time.Time{}}
+)
+
+// nonceCache records each nonce seen until it expires, after which a request
+// with the same nonce would be rejected as stale anyway. The cache is only held
+// in memory by each process, so a signed request can still be replayed against
+// another replica of the service within the allowed skew.
+type nonceCache struct {
+	mutex  sync.Mutex
+	seen   map[string]time.Time
+	pruned time.Time
+}
+
+// verify checks the signature of the request against the secret of the signed
+// API key identified by its fingerprint, that it was signed within the allowed
+// skew of the time `now`, and that it has not been seen before, returning the
+// API key if so.
+func (k *Keyring) verify(c *gin.Context, now time.Time) (*APIKey, error) {
+	fingerprint := c.GetHeader(signature.KeyHeader)
+	timestamp := c.GetHeader(signature.TimestampHeader)
+	nonce := c.GetHeader(signature.NonceHeader)
+
+	key, ok := k.fingerprints[fingerprint]
+	if !ok || !key.Signed || nonce == "" {
+		return nil, ErrInvalidSignature
+	}
+
+	if !key.Expires.IsZero() && now.After(key.Expires) {
+		return nil, ErrExpiredKey
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	signed := time.Unix(seconds, 0)
+	if signed.Before(now.Add(-k.skew)) || signed.After(now.Add(k.skew)) {
+		return nil, ErrStaleSignature
+	}
+
+	var reader io.Reader = c.Request.Body
+	if k.maxBody > 0 {
+		reader = io.LimitReader(c.Request.Body, k.maxBody+1)
+	}
+
+	body, err := io.ReadAll(reader)
+	if err != nil || (k.maxBody > 0 && int64(len(body)) > k.maxBody) {
+		return nil, ErrInvalidSignature
+	}
+
+	// Replace the body which has been read so it can be read again by the
+	// handler for the request
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	if !signature.Verify([]byte(key.Secret), c.GetHeader(signature.SignatureHeader),
+		timestamp, nonce, c.Request.Method, c.Request.URL.Path, body) {
+		return nil, ErrInvalidSignature
+	}
+
+	// Only record the nonce once the signature is known to be valid, so that
+	// nonces cannot be used up by requests which were not signed by the key
+	if !nonces.add(fingerprint+":"+nonce, signed.Add(k.skew), now) {
+		return nil, ErrReplayedSignature
+	}
+
+	return key, nil
+}
+
+// add records the `nonce` until it `expires`, returning false if the nonce has
+// already been recorded and has not yet expired at the time `now`.
+func (n *nonceCache) add(nonce string, expires, now time.Time) bool {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	// Remove any expired nonces at most once a second, to keep the cache small
+	// without having to check every nonce on every request
+	if now.Sub(n.pruned) > time.Second {
+		for seen, expiry := range n.seen {
+			if now.After(expiry) {
+				delete(n.seen, seen)
+			}
+		}
+
+		n.pruned = now
+	}
+
+	if expiry, ok := n.seen[nonce]; ok && !now.After(expiry) {
+		return false
+	}
+
+	n.seen[nonce] = expires
+
+	return true
+}
@@ -0,0 +1,171 @@
+//nolint:paralleltest // these tests cannot operate in parallel
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/serve/middleware"
+	"github.com/n3tuk/dashboard/internal/signature"
+)
+
+// signedKey is the API key which can only be used to sign requests.
+const signedKey = "8f1c2e0b-5d4a-4b7e-9c3f-6a2d1e0f7b9c" // gitleaks:allow
+
+// newSignedRouter creates a new Gin engine with an endpoint which requires the
+// events:write scope, and which echoes back the body of the request, using the
+// `signedKey`, which can only be used to sign requests, and the key `bearer`,
+// which can only be sent with the request.
+func newSignedRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	keyring, err := middleware.NewKeyring([]*middleware.APIKey{{
+		Name:   "signed",
+		Hash:   middleware.HashKey(signedKey),
+		Secret: signedKey,
+		Scopes: []string{middleware.ScopeEventsWrite},
+		Signed: true,
+	}, {
+		Name:   "bearer",
+		Hash:   middleware.HashKey("bearer"),
+		Scopes: []string{middleware.ScopeEventsWrite},
+	}}, nil)
+	require.NoError(t, err)
+
+	middleware.SetKeyring(keyring)
+
+	router := gin.New()
+	router.POST("/write", middleware.Authorize(middleware.ScopeEventsWrite), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+
+	return router
+}
+
+// signed creates a new request with the `body`, signed with the `key` at the
+// time `now`.
+func signed(t *testing.T, key, body string, now time.Time) *http.Request {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodPost, "/write", strings.NewReader(body))
+	require.NoError(t, signature.Apply(r, key, []byte(body), now))
+
+	return r
+}
+
+// TestSigned tests that a signed request is accepted, and that its body can
+// still be read by the handler, but that it cannot be replayed.
+func TestSigned(t *testing.T) {
+	router := newSignedRouter(t)
+	r := signed(t, signedKey, `{"event-id":"test"}`, time.Now())
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"event-id":"test"}`, w.Body.String())
+
+	replay := httptest.NewRequest(http.MethodPost, "/write", strings.NewReader(`{"event-id":"test"}`))
+	replay.Header = r.Header.Clone()
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, replay)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// TestSignedRejected tests that signed requests which cannot be verified, or
+// which use a signed-only key as a bearer token, are rejected.
+func TestSignedRejected(t *testing.T) {
+	router := newSignedRouter(t)
+
+	tests := map[string]func() *http.Request{
+		"unknown key": func() *http.Request {
+			return signed(t, "unknown", `{}`, time.Now())
+		},
+		"stale timestamp": func() *http.Request {
+			return signed(t, signedKey, `{}`, time.Now().Add(-time.Hour))
+		},
+		"future timestamp": func() *http.Request {
+			return signed(t, signedKey, `{}`, time.Now().Add(time.Hour))
+		},
+		"modified body": func() *http.Request {
+			r := signed(t, signedKey, `{}`, time.Now())
+			r.Body = io.NopCloser(strings.NewReader(`{"event-id":"modified"}`))
+
+			return r
+		},
+		"modified timestamp": func() *http.Request {
+			r := signed(t, signedKey, `{}`, time.Now())
+			r.Header.Set(signature.TimestampHeader, strconv.FormatInt(time.Now().Unix()+1, 10))
+
+			return r
+		},
+		"bearer token": func() *http.Request {
+			r := httptest.NewRequest(http.MethodPost, "/write", strings.NewReader(`{}`))
+			r.Header.Set("Authorization", "Bearer "+signedKey)
+
+			return r
+		},
+		"unsigned key": func() *http.Request {
+			return signed(t, "bearer", `{}`, time.Now())
+		},
+		"signed with hash": func() *http.Request {
+			return signed(t, middleware.HashKey(signedKey), `{}`, time.Now())
+		},
+	}
+
+	for name, request := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, request())
+
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+		})
+	}
+}
+
+// TestSignedMaxBody tests that the body of a signed request is limited to the
+// maximum size of a batch of events, as set in the configuration.
+func TestSignedMaxBody(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	viper.Set("endpoints.batch.max-bytes", 16)
+	viper.Set("authentication.keys", []map[string]any{{
+		"name":   "signed",
+		"secret": signedKey,
+		"scopes": []string{middleware.ScopeEventsWrite},
+		"signed": true,
+	}})
+
+	require.NoError(t, middleware.LoadKeys())
+
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST("/write", middleware.Authorize(middleware.ScopeEventsWrite), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, signed(t, signedKey, `{"id":"short"}`, time.Now()))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, signed(t, signedKey, `{"id":"much-longer"}`, time.Now()))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,274 @@
+// The `reaper` package provides the background process which checks the events
+// in the store against their expected heartbeat and TTL, marking the events
+// which have missed their heartbeat as stale, and removing the events which
+// have expired, or been stale for too long, pushing each change out to the
+// connected clients.
+package reaper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/spf13/viper"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/store"
+)
+
+const (
+	// ungrouped is the name of the group used in the metrics for events which
+	// have no group.
+	ungrouped = "ungrouped"
+	// otherGroups is the name of the group used in the metrics for the events in
+	// all the groups over `maxGroups`.
+	otherGroups = "other"
+	// maxGroups is the maximum number of groups reported on their own in the
+	// metrics, as the groups are chosen by the clients submitting the events.
+	maxGroups = 100
+)
+
+var stale = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Subsystem: "events",
+	Name:      "stale",
+	Help:      "Number of events which have missed their heartbeat.",
+}, []string{"cluster", "group"})
+
+// Reaper checks the events in the store for those which have missed their
+// heartbeat or expired, updating or removing them, and notifying the publisher
+// of each change.
+type Reaper struct {
+	store     store.EventStore
+	publisher event.Publisher
+	cluster   string
+	retention time.Duration
+
+	mutex   sync.Mutex
+	groups  map[string]bool
+	handler func([]*event.Event, time.Time)
+}
+
+// New creates a new `Reaper` for the events in the store `s`, notifying the
+// publisher `p` of each change, based on the `expiry` and `cluster.name`
+// settings in the configuration.
+func New(s store.EventStore, p event.Publisher) *Reaper {
+	return &Reaper{
+		store:     s,
+		publisher: p,
+		cluster:   viper.GetString("cluster.name"),
+		retention: time.Duration(viper.GetInt("expiry.retention")) * time.Second,
+		groups:    map[string]bool{},
+	}
+}
+
+// Subscribe sets the `handler` which is called after each sweep with the
+// current state of all the events which were not removed, along with the time
+// the sweep started, so that the events only need to be listed once.
+func (r *Reaper) Subscribe(handler func([]*event.Event, time.Time)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.handler = handler
+}
+
+// Run checks the events every `interval` until `ctx` is cancelled, logging any
+// problems found with each check.
+func (r *Reaper) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		slog.Warn("Events will not be checked for missed heartbeats as the interval is not set")
+
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := r.Sweep(ctx, now); err != nil {
+				slog.Error(
+					"Failed to check the events for missed heartbeats",
+					slog.Group("error",
+						slog.String("message", err.Error()),
+					),
+				)
+			}
+		}
+	}
+}
+
+// Sweep checks all the events in the store at the time `now`, removing those
+// which have expired, or which have been stale for longer than the retention
+// period, and marking as stale those which have missed their heartbeat, before
+// updating the number of stale events in each group, and passing the current
+// state of the events to the handler set by `Subscribe`.
+func (r *Reaper) Sweep(ctx context.Context, now time.Time) error {
+	var errs []error
+
+	started := time.Now()
+
+	events, err := store.All(ctx, r.store, "")
+	if err != nil {
+		return fmt.Errorf("unable to list the events: %w", err)
+	}
+
+	counts := map[string]int{}
+	current := make([]*event.Event, 0, len(events))
+
+	for _, e := range events {
+		removed, err := r.check(ctx, e, now)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		if removed {
+			continue
+		}
+
+		current = append(current, e)
+
+		if e.Status == event.StatusStale {
+			counts[groupName(e)]++
+		}
+	}
+
+	r.report(counts)
+
+	r.mutex.Lock()
+	handler := r.handler
+	r.mutex.Unlock()
+
+	if handler != nil {
+		handler(current, started)
+	}
+
+	return errors.Join(errs...)
+}
+
+// check checks the event `e` at the time `now`, removing it if it has expired,
+// or marking it as stale (which updates `e`) if it has missed its heartbeat,
+// returning whether or not it was removed.
+func (r *Reaper) check(ctx context.Context, e *event.Event, now time.Time) (bool, error) {
+	expires := e.Expires()
+	if e.Status == event.StatusStale && r.retention > 0 {
+		if retained := e.Timestamp.Add(r.retention); expires.IsZero() || retained.Before(expires) {
+			expires = retained
+		}
+	}
+
+	if !expires.IsZero() && !now.Before(expires) {
+		if err := r.store.Delete(ctx, e.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return false, fmt.Errorf("unable to remove the event %s: %w", e.ID, err)
+		}
+
+		removed := e.Clone()
+		removed.Received = now.UTC()
+		removed.Removed = true
+
+		r.publisher.Publish(removed)
+
+		slog.Info(
+			"Removed the expired event",
+			slog.Group("event",
+				slog.String("id", e.ID),
+				slog.String("status", e.Status),
+			),
+		)
+
+		return true, nil
+	}
+
+	deadline := e.Deadline()
+	if e.Status == event.StatusStale || deadline.IsZero() || now.Before(deadline) {
+		return false, nil
+	}
+
+	// Use the deadline as the time of the update, rather than the time it was
+	// found, so that the same update is made by every instance in the cluster
+	update := e.Clone()
+	update.Status = event.StatusStale
+	update.Message = fmt.Sprintf("No update was received within the %s heartbeat", time.Duration(e.Heartbeat)*time.Second)
+	update.Timestamp = deadline
+	update.Received = now.UTC()
+	update.Reopen = false
+	update.Duration = 0
+
+	if err := r.store.Put(ctx, update); err != nil {
+		return false, fmt.Errorf("unable to mark the event %s as stale: %w", e.ID, err)
+	}
+
+	r.publisher.Publish(update)
+	*e = *update
+
+	slog.Info(
+		"Marked the event as stale",
+		slog.Group("event",
+			slog.String("id", e.ID),
+			slog.Time("deadline", deadline),
+		),
+	)
+
+	return false, nil
+}
+
+// report sets the number of stale events in each group from the `counts`,
+// removing the groups which no longer have any stale events. Only the first
+// `maxGroups` groups by name are reported on their own, with the stale events
+// in the rest counted together, so that the number of series stays bounded.
+func (r *Reaper) report(counts map[string]int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	names := make([]string, 0, len(counts))
+	for group := range counts {
+		names = append(names, group)
+	}
+
+	slices.Sort(names)
+
+	reported := map[string]int{}
+	for i, group := range names {
+		if i >= maxGroups {
+			reported[otherGroups] += counts[group]
+
+			continue
+		}
+
+		reported[group] = counts[group]
+	}
+
+	for group := range r.groups {
+		if _, ok := reported[group]; !ok {
+			stale.DeleteLabelValues(r.cluster, group)
+			delete(r.groups, group)
+		}
+	}
+
+	for group, count := range reported {
+		stale.WithLabelValues(r.cluster, group).Set(float64(count))
+		r.groups[group] = true
+	}
+}
+
+// groupName returns the name of the group for the event `e` in the metrics,
+// which is only the top of the hierarchy of groups, such as `service` for the
+// group `service/production/web`.
+func groupName(e *event.Event) string {
+	if e.Group == "" {
+		return ungrouped
+	}
+
+	name, _, _ := strings.Cut(e.Group, "/")
+
+	return name
+}
@@ -0,0 +1,228 @@
+//nolint:paralleltest // these tests cannot operate in parallel
+package reaper_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/serve/reaper"
+	"github.com/n3tuk/dashboard/internal/store"
+)
+
+// recorder records each event it is notified of.
+type recorder struct {
+	mutex  sync.Mutex
+	events []*event.Event
+}
+
+// Publish records the event `e`.
+func (r *recorder) Publish(e *event.Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.events = append(r.events, e)
+}
+
+// newReaper creates a new reaper with the `retention` for stale events over a
+// new in-memory store holding the `events`.
+func newReaper(t *testing.T, retention int, events ...*event.Event) (*reaper.Reaper, store.EventStore, *recorder) {
+	t.Helper()
+
+	viper.Reset()
+	viper.Set("cluster.name", "test")
+	viper.Set("expiry.retention", retention)
+
+	s := store.NewMemory()
+	for _, e := range events {
+		require.NoError(t, s.Put(context.Background(), e))
+	}
+
+	p := &recorder{}
+
+	return reaper.New(s, p), s, p
+}
+
+// staleCount returns the value of the gauge for the number of stale events in
+// the `group`, or -1 if it has not been set.
+func staleCount(t *testing.T, group string) float64 {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != "events_stale" {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+
+			if labels["cluster"] == "test" && labels["group"] == group {
+				return metric.GetGauge().GetValue()
+			}
+		}
+	}
+
+	return -1
+}
+
+// TestSweepStale tests that events which have missed their heartbeat are marked
+// as stale only once, with the deadline as the time of the update, and that
+// events without a heartbeat, or within it, are left alone.
+func TestSweepStale(t *testing.T) {
+	ctx := context.Background()
+	sent := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	r, s, p := newReaper(t, 0,
+		&event.Event{ID: "missed", Status: "running", Group: "jobs/nightly", Heartbeat: 60, Timestamp: sent},
+		&event.Event{ID: "waiting", Status: "running", Group: "jobs", Heartbeat: 3600, Timestamp: sent},
+		&event.Event{ID: "forever", Status: "pass", Timestamp: sent},
+	)
+
+	now := sent.Add(5 * time.Minute)
+	require.NoError(t, r.Sweep(ctx, now))
+	require.NoError(t, r.Sweep(ctx, now.Add(time.Minute)))
+
+	require.Len(t, p.events, 1)
+	assert.Equal(t, "missed", p.events[0].ID)
+	assert.Equal(t, event.StatusStale, p.events[0].Status)
+	assert.Equal(t, sent.Add(time.Minute), p.events[0].Timestamp)
+	assert.Equal(t, now, p.events[0].Received)
+	assert.False(t, p.events[0].Removed)
+
+	current, err := s.Get(ctx, "missed")
+	require.NoError(t, err)
+	assert.Equal(t, event.StatusStale, current.Status)
+
+	history, err := s.History(ctx, "missed")
+	require.NoError(t, err)
+	assert.Len(t, history, 2)
+
+	for _, id := range []string{"waiting", "forever"} {
+		current, err := s.Get(ctx, id)
+		require.NoError(t, err)
+		assert.NotEqual(t, event.StatusStale, current.Status)
+	}
+
+	assert.InDelta(t, 1, staleCount(t, "jobs"), 0)
+}
+
+// TestSweepRemove tests that events are removed once their TTL has passed, or
+// once they have been stale for longer than the retention period, with the
+// removal pushed out to the clients.
+func TestSweepRemove(t *testing.T) {
+	ctx := context.Background()
+	sent := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	r, s, p := newReaper(t, 3600,
+		&event.Event{ID: "expired", Status: "pass", TTL: 60, Timestamp: sent},
+		&event.Event{ID: "stale", Status: "running", Heartbeat: 60, Timestamp: sent},
+		&event.Event{ID: "current", Status: "pass", TTL: 86400, Timestamp: sent},
+	)
+
+	require.NoError(t, r.Sweep(ctx, sent.Add(5*time.Minute)))
+	require.Len(t, p.events, 2)
+	assert.Equal(t, "expired", p.events[0].ID)
+	assert.True(t, p.events[0].Removed)
+	assert.Equal(t, "stale", p.events[1].ID)
+	assert.False(t, p.events[1].Removed)
+
+	_, err := s.Get(ctx, "expired")
+	require.ErrorIs(t, err, store.ErrNotFound)
+
+	// The stale event is kept for the retention period after it became stale
+	require.NoError(t, r.Sweep(ctx, sent.Add(time.Hour)))
+	assert.Len(t, p.events, 2)
+
+	require.NoError(t, r.Sweep(ctx, sent.Add(time.Hour+time.Minute)))
+	require.Len(t, p.events, 3)
+	assert.Equal(t, "stale", p.events[2].ID)
+	assert.True(t, p.events[2].Removed)
+
+	_, err = s.Get(ctx, "stale")
+	require.ErrorIs(t, err, store.ErrNotFound)
+
+	_, err = s.Get(ctx, "current")
+	require.NoError(t, err)
+
+	// Groups without any stale events are removed, rather than set to zero
+	assert.InDelta(t, -1, staleCount(t, "ungrouped"), 0)
+}
+
+// TestSweepSubscribe tests that the current state of the events which were not
+// removed is passed to the handler after each sweep.
+func TestSweepSubscribe(t *testing.T) {
+	sent := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	r, _, _ := newReaper(t, 0,
+		&event.Event{ID: "expired", Status: "pass", TTL: 60, Timestamp: sent},
+		&event.Event{ID: "missed", Status: "running", Heartbeat: 60, Timestamp: sent},
+	)
+
+	var current []*event.Event
+
+	r.Subscribe(func(events []*event.Event, _ time.Time) {
+		current = events
+	})
+
+	require.NoError(t, r.Sweep(context.Background(), sent.Add(5*time.Minute)))
+	require.Len(t, current, 1)
+	assert.Equal(t, "missed", current[0].ID)
+	assert.Equal(t, event.StatusStale, current[0].Status)
+}
+
+// TestSweepGroups tests that the stale events are only reported for each of the
+// first groups by name, with the events in the rest counted together.
+func TestSweepGroups(t *testing.T) {
+	sent := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	events := []*event.Event{}
+	for i := range 102 {
+		events = append(events, &event.Event{
+			ID:        fmt.Sprintf("event-%03d", i),
+			Status:    "running",
+			Group:     fmt.Sprintf("group-%03d/jobs", i),
+			Heartbeat: 60,
+			Timestamp: sent,
+		})
+	}
+
+	r, _, _ := newReaper(t, 0, events...)
+	require.NoError(t, r.Sweep(context.Background(), sent.Add(5*time.Minute)))
+
+	assert.InDelta(t, 1, staleCount(t, "group-099"), 0)
+	assert.InDelta(t, -1, staleCount(t, "group-100"), 0)
+	assert.InDelta(t, 2, staleCount(t, "other"), 0)
+}
+
+// TestRunDisabled tests that the reaper returns immediately when the interval
+// is not set.
+func TestRunDisabled(t *testing.T) {
+	r, _, _ := newReaper(t, 0)
+
+	done := make(chan struct{})
+
+	go func() {
+		r.Run(context.Background(), 0)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the reaper did not return without an interval")
+	}
+}
@@ -0,0 +1,291 @@
+// The `reload` package watches the configuration file for the web service and
+// reloads it whenever the file changes, or the SIGHUP signal is received, then
+// applies the settings which can be safely changed while the service is
+// running, and warns about any other settings which need a restart to change.
+package reload
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/spf13/viper"
+
+	"github.com/n3tuk/dashboard/internal/config"
+)
+
+var (
+	reloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: "config",
+		Name:      "reloads_total",
+		Help:      "Count of the reloads of the configuration.",
+	}, []string{"cluster", "result"})
+
+	successful = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: "config",
+		Name:      "last_reload_successful",
+		Help:      "Whether or not the last reload of the configuration was successful.",
+	}, []string{"cluster"})
+
+	timestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: "config",
+		Name:      "last_reload_success_timestamp_seconds",
+		Help:      "Time of the last successful reload of the configuration.",
+	}, []string{"cluster"})
+
+	// live is the list of settings, or the prefixes for groups of settings,
+	// which can be changed without restarting the service.
+	live = []string{
+		"logging.",
+		"endpoints.proxies",
+		"endpoints.rate-limits.",
+		"authentication.",
+	}
+
+	// mutex ensures only one reload of the configuration is made at a time.
+	mutex sync.Mutex
+	// running holds the values of the settings which can only be changed by
+	// restarting the service, as they were when the service started, against
+	// which each reload is compared.
+	running map[string]any
+)
+
+// Apply applies the settings from the configuration which can be changed
+// while the service is running, after it has been reloaded. If it returns an
+// error, the previous configuration is restored, so it should check all the
+// settings before applying any of them.
+type Apply func() error
+
+// Watch reloads the configuration file `name` whenever the file changes, or
+// the SIGHUP signal is received, until the context `ctx` is done, calling
+// `apply` after each successful reload. All reloads are made from this
+// goroutine, one at a time, so the configuration is never changed while it is
+// being reloaded.
+func Watch(ctx context.Context, name string, apply Apply) {
+	cluster := viper.GetString("cluster.name")
+	successful.WithLabelValues(cluster).Set(1)
+	timestamp.WithLabelValues(cluster).SetToCurrentTime()
+
+	Record()
+
+	changes := make(chan struct{}, 1)
+
+	if file := viper.ConfigFileUsed(); file != "" {
+		if err := watchFile(ctx, file, changes); err != nil {
+			slog.Error(
+				"Unable to watch the configuration file for changes",
+				slog.Group("error", slog.String("message", err.Error())),
+				slog.Group("config", slog.String("file", file)),
+			)
+		}
+	}
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	defer signal.Stop(hangup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changes:
+			_ = Reload(name, cluster, "file", apply)
+		case <-hangup:
+			_ = Reload(name, cluster, "signal", apply)
+		}
+	}
+}
+
+// watchFile watches the configuration `file` until the context `ctx` is done,
+// queuing a reload on `changes` whenever it is written or replaced. Unlike
+// `viper.WatchConfig`, the file is not read here, leaving the reload to be made
+// by `Watch` so that it is validated before it is used.
+func watchFile(ctx context.Context, file string, changes chan<- struct{}) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	file = filepath.Clean(file)
+
+	// Watch the directory rather than the file, so that changes are still seen
+	// when the file is replaced, such as through the symbolic links used by
+	// Kubernetes to update the files from a ConfigMap
+	if err := watcher.Add(filepath.Dir(file)); err != nil {
+		_ = watcher.Close()
+
+		return err
+	}
+
+	target, _ := filepath.EvalSymlinks(file)
+
+	go func() {
+		defer watcher.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				current, _ := filepath.EvalSymlinks(file)
+				written := filepath.Clean(event.Name) == file &&
+					(event.Has(fsnotify.Write) || event.Has(fsnotify.Create))
+
+				if !written && current == target {
+					continue
+				}
+
+				target = current
+
+				// Only queue one reload at a time, as many changes to the file can
+				// be made at once, and each reload reads the file as it is then
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				slog.Warn(
+					"Error while watching the configuration file for changes",
+					slog.Group("error", slog.String("message", err.Error())),
+					slog.Group("config", slog.String("file", file)),
+				)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Record takes a snapshot of the settings which can only be changed by
+// restarting the service, as they are currently set, so that each reload can
+// report which of them have been changed in the configuration file since.
+func Record() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	running = snapshot()
+}
+
+// Reload reloads the configuration file `name` and then calls `apply` to
+// apply the settings which can be changed live (restoring the previous
+// configuration if it fails), recording the result in the
+// metrics for the `cluster`, and logging any other settings which changed but
+// will not be used until the service is restarted. The `trigger` is logged to
+// show what caused the reload.
+func Reload(name, cluster, trigger string, apply Apply) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if running == nil {
+		running = snapshot()
+	}
+
+	if err := config.Reload(name, apply); err != nil {
+		reloads.WithLabelValues(cluster, "failure").Inc()
+		successful.WithLabelValues(cluster).Set(0)
+
+		slog.Error(
+			"Unable to reload the configuration",
+			slog.Group("error", slog.String("message", err.Error())),
+			slog.Group("config",
+				slog.String("file", viper.ConfigFileUsed()),
+				slog.String("trigger", trigger),
+			),
+		)
+
+		return err
+	}
+
+	reloads.WithLabelValues(cluster, "success").Inc()
+	successful.WithLabelValues(cluster).Set(1)
+	timestamp.WithLabelValues(cluster).SetToCurrentTime()
+
+	// Compare against the settings in use since the service started, rather than
+	// those from the last reload, as none of these changes are ever applied
+	for _, key := range changed(running) {
+		slog.Warn(
+			"Setting cannot be changed without a restart, so the change will be ignored",
+			slog.Group("config",
+				slog.String("file", viper.ConfigFileUsed()),
+				slog.String("key", key),
+			),
+		)
+	}
+
+	slog.Info(
+		"Reloaded the configuration",
+		slog.Group("config",
+			slog.String("file", viper.ConfigFileUsed()),
+			slog.String("trigger", trigger),
+		),
+	)
+
+	return nil
+}
+
+// snapshot returns the current values of all the settings which can only be
+// changed by restarting the service.
+func snapshot() map[string]any {
+	settings := map[string]any{}
+
+	for _, key := range viper.AllKeys() {
+		if !isLive(key) {
+			settings[key] = viper.Get(key)
+		}
+	}
+
+	return settings
+}
+
+// changed returns the sorted list of the settings which can only be changed
+// by restarting the service, and have changed since the `before` snapshot.
+func changed(before map[string]any) []string {
+	after := snapshot()
+	keys := []string{}
+
+	for key, value := range after {
+		if previous, ok := before[key]; !ok || !reflect.DeepEqual(previous, value) {
+			keys = append(keys, key)
+		}
+	}
+
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
+
+// isLive checks whether the setting `key` can be changed live.
+func isLive(key string) bool {
+	for _, prefix := range live {
+		if key == prefix || strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,225 @@
+//nolint:paralleltest // these tests cannot operate in parallel
+package reload_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/config"
+	"github.com/n3tuk/dashboard/internal/serve/reload"
+)
+
+const (
+	serveConfigName = "serve.yaml"
+
+	initial = "endpoints:\n  bind:\n    address: localhost\nlogging:\n  level: info\n"
+	changed = "endpoints:\n  bind:\n    address: 0.0.0.0\nlogging:\n  level: debug\n"
+	invalid = "endpoints:\n  bind:\n    address: 0.0.0.0\nlogging:\n  level: verbose\n"
+)
+
+var errApply = errors.New("unable to apply")
+
+// logs collects the output of the logger, which can be written to from the
+// goroutine watching the configuration while the test reads it.
+type logs struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+// Write adds the `p` to the collected output of the logger.
+func (l *logs) Write(p []byte) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.buffer.Write(p)
+}
+
+// String returns the output of the logger collected so far.
+func (l *logs) String() string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.buffer.String()
+}
+
+// load writes the `contents` to a new configuration file and loads it,
+// recording the settings in use, and collecting the output of the logger.
+func load(t *testing.T, contents string) (string, *logs) {
+	t.Helper()
+
+	viper.Reset()
+
+	file := filepath.Join(t.TempDir(), serveConfigName)
+	require.NoError(t, os.WriteFile(file, []byte(contents), 0o600))
+	require.NoError(t, config.Load(serveConfigName, file))
+
+	reload.Record()
+
+	output := &logs{}
+	logger := slog.Default()
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(output, nil)))
+	t.Cleanup(func() { slog.SetDefault(logger) })
+
+	return file, output
+}
+
+// restarts returns the settings which were logged as needing a restart to be
+// changed in the `output` from the logger.
+func restarts(output string) []string {
+	keys := []string{}
+
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.Contains(line, "cannot be changed without a restart") {
+			continue
+		}
+
+		_, key, _ := strings.Cut(line, "config.key=")
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// TestReload tests that the changes to the configuration file are loaded and
+// applied, including those which need a restart, which are reported on each
+// reload until the service is restarted.
+func TestReload(t *testing.T) {
+	file, output := load(t, initial)
+	require.NoError(t, os.WriteFile(file, []byte(changed), 0o600))
+
+	applied := 0
+	err := reload.Reload(serveConfigName, "test", "test", func() error {
+		applied++
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, applied)
+	assert.Equal(t, "debug", viper.GetString("logging.level"))
+	assert.Equal(t, "0.0.0.0", viper.GetString("endpoints.bind.address"))
+	assert.Equal(t, []string{"endpoints.bind.address"}, restarts(output.String()))
+
+	require.NoError(t, reload.Reload(serveConfigName, "test", "test", func() error { return nil }))
+	assert.Equal(t, []string{"endpoints.bind.address", "endpoints.bind.address"}, restarts(output.String()))
+}
+
+// TestReloadInvalid tests that an invalid configuration file is not applied,
+// and the previous configuration is kept.
+func TestReloadInvalid(t *testing.T) {
+	file, _ := load(t, initial)
+	require.NoError(t, os.WriteFile(file, []byte(invalid), 0o600))
+
+	applied := 0
+	err := reload.Reload(serveConfigName, "test", "test", func() error {
+		applied++
+
+		return nil
+	})
+	require.Error(t, err)
+
+	var violations *config.ValidationError
+	require.ErrorAs(t, err, &violations)
+
+	assert.Zero(t, applied)
+	assert.Equal(t, "info", viper.GetString("logging.level"))
+	assert.Equal(t, "localhost", viper.GetString("endpoints.bind.address"))
+
+	require.NoError(t, os.WriteFile(file, []byte("logging: [\n"), 0o600))
+
+	err = reload.Reload(serveConfigName, "test", "test", func() error { return nil })
+	require.Error(t, err)
+	assert.Equal(t, "info", viper.GetString("logging.level"))
+}
+
+// TestReloadApplyFailed tests that a failure to apply the configuration is
+// reported as a failed reload, and the previous configuration is restored.
+func TestReloadApplyFailed(t *testing.T) {
+	file, _ := load(t, initial)
+	require.NoError(t, os.WriteFile(file, []byte(changed), 0o600))
+
+	err := reload.Reload(serveConfigName, "test", "test", func() error {
+		return errApply
+	})
+	require.ErrorIs(t, err, errApply)
+
+	assert.Equal(t, "info", viper.GetString("logging.level"))
+	assert.Equal(t, "localhost", viper.GetString("endpoints.bind.address"))
+}
+
+// TestWatchSignal tests that the configuration is reloaded when the SIGHUP
+// signal is received.
+func TestWatchSignal(t *testing.T) {
+	file, _ := load(t, initial)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	applied := make(chan struct{}, 4)
+
+	go reload.Watch(ctx, serveConfigName, func() error {
+		applied <- struct{}{}
+
+		return nil
+	})
+
+	// Give the watcher time to start listening for the signal
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, os.WriteFile(file, []byte(changed), 0o600))
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+
+	select {
+	case <-applied:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the configuration was not reloaded")
+	}
+}
+
+// TestWatchFile tests that the configuration is reloaded when the file is
+// changed, and that the settings which need a restart are reported.
+func TestWatchFile(t *testing.T) {
+	file, output := load(t, initial)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	applied := make(chan struct{}, 4)
+
+	go reload.Watch(ctx, serveConfigName, func() error {
+		applied <- struct{}{}
+
+		return nil
+	})
+
+	// Give the watcher time to start watching the file
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, os.WriteFile(file, []byte(changed), 0o600))
+
+	select {
+	case <-applied:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the configuration was not reloaded")
+	}
+
+	assert.Eventually(t, func() bool {
+		return slices.Contains(restarts(output.String()), "endpoints.bind.address")
+	}, time.Second, 10*time.Millisecond)
+	assert.NotContains(t, restarts(output.String()), "logging.level")
+}
@@ -0,0 +1,246 @@
+// The `dashboard` package provides the HTML dashboard served from the root of
+// the web service, rendering the current state of the events from the event
+// store, grouped together by their group (or source), and the history of each
+// event, using the embedded templates and assets, and then updating live in the
+// browser from the event stream.
+package dashboard
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/serve/middleware"
+	"github.com/n3tuk/dashboard/internal/store"
+	"github.com/n3tuk/dashboard/pages"
+)
+
+const (
+	// ungrouped is the name used for events which have neither a group nor a
+	// source to group them by.
+	ungrouped = "ungrouped"
+
+	day = 24 * time.Hour
+)
+
+var (
+	//go:embed templates/*.html
+	templates embed.FS
+	//go:embed static/*
+	static embed.FS
+
+	events store.EventStore
+	// rollup is the rule used to find the status of each group.
+	rollup string
+)
+
+// Group is a set of events shown together on the dashboard, along with the
+// status rolled up from the statuses of the events.
+type Group struct {
+	Name   string
+	Status string
+	Events []*event.Event
+}
+
+// Attach takes a reference to the Gin engine and attaches the dashboard pages
+// and their assets, rendering the events from the event store `s`, and using
+// the `rule` to roll up the status of each group.
+func Attach(r *gin.Engine, s store.EventStore, rule string) {
+	events = s
+	rollup = rule
+
+	r.SetHTMLTemplate(template.Must(
+		template.New("dashboard").
+			Funcs(template.FuncMap{
+				"since":  since,
+				"status": status,
+				"iso":    iso,
+			}).
+			ParseFS(templates, "templates/*.html"),
+	))
+
+	// The embedded paths are fixed above, so this cannot fail
+	assets, _ := fs.Sub(static, "static")
+
+	for _, name := range []string{"logo.svg", "custom.css"} {
+		r.StaticFileFS("/assets/"+name, name, http.FS(pages.Assets()))
+	}
+
+	for _, name := range []string{"dashboard.css", "dashboard.js"} {
+		r.StaticFileFS("/static/"+name, name, http.FS(assets))
+	}
+
+	r.GET("/", middleware.Authorize(middleware.ScopeEventsRead), index)
+	r.GET("/events/:id", middleware.Authorize(middleware.ScopeEventsRead), history)
+}
+
+// index renders the dashboard with the current state of all the events,
+// grouped together by their group, or their source if they have no group.
+func index(c *gin.Context) {
+	result, err := events.List(c.Request.Context(), "", store.Page{Limit: store.MaxLimit})
+	if err != nil {
+		internalError(c, "Unable to list the events", err)
+
+		return
+	}
+
+	c.HTML(http.StatusOK, "index.html", gin.H{
+		"Title":     "Dashboard",
+		"Rollup":    rollup,
+		"Groups":    group(result.Events),
+		"Truncated": result.Next != "",
+	})
+}
+
+// history renders the current state of a single event, along with all the
+// updates recorded for it, from the newest to the oldest.
+func history(c *gin.Context) {
+	id := c.Param("id")
+
+	current, err := events.Get(c.Request.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		c.HTML(http.StatusNotFound, "error.html", gin.H{
+			"Title":   "Event Not Found",
+			"Message": fmt.Sprintf("The event %q could not be found", id),
+		})
+
+		return
+	}
+
+	if err != nil {
+		internalError(c, "Unable to get the event", err)
+
+		return
+	}
+
+	updates, err := events.History(c.Request.Context(), id)
+	if err != nil {
+		internalError(c, "Unable to get the history for the event", err)
+
+		return
+	}
+
+	slices.Reverse(updates)
+
+	c.HTML(http.StatusOK, "event.html", gin.H{
+		"Title":   current.ID,
+		"Event":   current,
+		"History": updates,
+	})
+}
+
+// internalError logs the `err` and renders the error page for when a problem
+// with the event store prevents the page from being shown.
+func internalError(c *gin.Context, message string, err error) {
+	slog.Error(
+		message,
+		slog.Group("error", slog.String("message", err.Error())),
+		slog.String("path", c.Request.URL.Path),
+	)
+
+	c.HTML(http.StatusInternalServerError, "error.html", gin.H{
+		"Title":   "Error",
+		"Message": message,
+	})
+}
+
+// group collects the `events` together by their group, or their source if
+// they have no group, returning the groups sorted by name, with the events in
+// each group sorted by their ID, and the status of each group rolled up from
+// the statuses of its events.
+func group(events []*event.Event) []*Group {
+	groups := map[string]*Group{}
+	counts := map[string]map[string]int{}
+
+	for _, e := range events {
+		name := groupName(e)
+
+		g, ok := groups[name]
+		if !ok {
+			g = &Group{Name: name}
+			groups[name] = g
+			counts[name] = map[string]int{}
+		}
+
+		g.Events = append(g.Events, e)
+		counts[name][e.Status]++
+	}
+
+	sorted := make([]*Group, 0, len(groups))
+	for name, g := range groups {
+		g.Status = event.Rollup(rollup, counts[name])
+
+		slices.SortFunc(g.Events, func(a, b *event.Event) int {
+			return strings.Compare(a.ID, b.ID)
+		})
+
+		sorted = append(sorted, g)
+	}
+
+	slices.SortFunc(sorted, func(a, b *Group) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return sorted
+}
+
+// groupName returns the name of the group the event `e` is shown in.
+func groupName(e *event.Event) string {
+	switch {
+	case e.Group != "":
+		return e.Group
+	case e.Source != "":
+		return e.Source
+	default:
+		return ungrouped
+	}
+}
+
+// status returns the class used to colour the `value` of a status, which is
+// the status it represents, so that any of the other names used for it are
+// coloured the same, and any status which is not known is shown as `unknown`.
+func status(value string) string {
+	return event.Canonical(value)
+}
+
+// iso returns the time `t` in the format used by the `datetime` attribute.
+func iso(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// since returns a short description of the time elapsed since `t`, such as
+// `5 minutes ago`, which is then kept up to date in the browser.
+func since(t time.Time) string {
+	elapsed := time.Since(t)
+
+	switch {
+	case elapsed < time.Minute:
+		return "just now"
+	case elapsed < time.Hour:
+		return plural(int(elapsed/time.Minute), "minute")
+	case elapsed < day:
+		return plural(int(elapsed/time.Hour), "hour")
+	default:
+		return plural(int(elapsed/day), "day")
+	}
+}
+
+// plural returns the `count` of the `unit` elapsed, such as `1 hour ago` or
+// `2 hours ago`.
+func plural(count int, unit string) string {
+	if count == 1 {
+		return fmt.Sprintf("1 %s ago", unit)
+	}
+
+	return fmt.Sprintf("%d %ss ago", count, unit)
+}
@@ -0,0 +1,126 @@
+package dashboard_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/serve/web/dashboard"
+	"github.com/n3tuk/dashboard/internal/store"
+)
+
+// newRouter creates a new Gin engine with the dashboard attached, rendering
+// the `events` saved into a new memory store.
+func newRouter(t *testing.T, events ...*event.Event) *gin.Engine {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	s := store.NewMemory()
+	for _, e := range events {
+		e.Normalise(time.Now())
+		require.NoError(t, s.Put(context.Background(), e))
+	}
+
+	router := gin.New()
+	dashboard.Attach(router, s, event.RollupWorstOf)
+
+	return router
+}
+
+// get makes a GET request for the `path` and returns the recorded response.
+func get(router *gin.Engine, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	router.ServeHTTP(w, r)
+
+	return w
+}
+
+// TestIndex tests that the dashboard lists the events in their groups, with
+// the status of each event.
+func TestIndex(t *testing.T) {
+	t.Parallel()
+
+	router := newRouter(t,
+		&event.Event{ID: "deploy", Status: "pass", Group: "service/production"},
+		&event.Event{ID: "backup", Status: "failed", Source: "cron"},
+		&event.Event{ID: "check", Status: "custom"},
+		&event.Event{ID: "release", Status: "cancelled"},
+	)
+
+	w := get(router, "/")
+	body := w.Body.String()
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, body, `data-group="service/production"`)
+	assert.Contains(t, body, `data-group="cron"`)
+	assert.Contains(t, body, `data-group="ungrouped"`)
+	assert.Contains(t, body, `<span class="status status-pass">pass</span>`)
+	assert.Contains(t, body, `<span class="status status-fail">fail</span>`)
+	assert.Contains(t, body, `<span class="status status-unknown">custom</span>`)
+	assert.Contains(t, body, `<span class="status status-cancelled">cancelled</span>`)
+	assert.Contains(t, body, `href="/events/deploy"`)
+	assert.Contains(t, body, `data-rollup="worst-of"`)
+	assert.Contains(t, body, `<h2>cron <span class="status status-fail" data-rollup>fail</span></h2>`)
+}
+
+// TestIndexEmpty tests that the dashboard can be shown without any events.
+func TestIndexEmpty(t *testing.T) {
+	t.Parallel()
+
+	w := get(newRouter(t), "/")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "No events have been received yet")
+}
+
+// TestHistory tests that the history of an event is shown from the newest to
+// the oldest update.
+func TestHistory(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	router := newRouter(t,
+		&event.Event{ID: "deploy", Status: "running", Message: "first", Timestamp: now.Add(-time.Hour)},
+		&event.Event{ID: "deploy", Status: "pass", Message: "second", Timestamp: now},
+	)
+
+	w := get(router, "/events/deploy")
+	body := w.Body.String()
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, body, `id="current">pass</span>`)
+	assert.Less(t, strings.Index(body, "second"), strings.Index(body, "first"))
+	assert.Contains(t, body, "1 hour ago")
+
+	w = get(router, "/events/missing")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// TestAssets tests that the shared and the dashboard assets are served.
+func TestAssets(t *testing.T) {
+	t.Parallel()
+
+	router := newRouter(t)
+
+	for _, path := range []string{
+		"/assets/logo.svg",
+		"/assets/custom.css",
+		"/static/dashboard.css",
+		"/static/dashboard.js",
+	} {
+		w := get(router, path)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.NotEmpty(t, w.Body.String(), path)
+	}
+}
@@ -0,0 +1,291 @@
+// dashboard.js keeps the dashboard up to date in the browser, refreshing the
+// relative times shown for each event, and applying each event received from
+// the live event stream to the page.
+
+// severity lists the statuses from the least to the most severe, used to roll
+// up the status of each group and to find the class used to colour each
+// status, which must be kept in sync with `severity` in the event package.
+const severity = [
+  'pass',
+  'cancelled',
+  'queued',
+  'running',
+  'unknown',
+  'warning',
+  'stale',
+  'fail',
+]
+
+// units are the units used to describe the time elapsed since an event.
+const units = [
+  ['day', 86400],
+  ['hour', 3600],
+  ['minute', 60],
+]
+
+// statusClass returns the class used to colour the `status`, where the events
+// received have already had any other names for their status resolved by the
+// service, so any status which is not known is shown as unknown.
+function statusClass(status) {
+  return severity.includes(status) ? status : 'unknown'
+}
+
+function since(datetime) {
+  const elapsed = (Date.now() - new Date(datetime).getTime()) / 1000
+
+  for (const [unit, seconds] of units) {
+    const count = Math.floor(elapsed / seconds)
+    if (count >= 1) {
+      return `${count} ${unit}${count === 1 ? '' : 's'} ago`
+    }
+  }
+
+  return 'just now'
+}
+
+function refreshTimes() {
+  for (const time of document.querySelectorAll('time[datetime]')) {
+    time.textContent = since(time.getAttribute('datetime'))
+  }
+}
+
+function element(tag, attributes, ...children) {
+  const node = document.createElement(tag)
+
+  for (const [key, value] of Object.entries(attributes || {})) {
+    node.setAttribute(key, value)
+  }
+
+  node.append(...children)
+
+  return node
+}
+
+function statusElement(status) {
+  const name = `status status-${statusClass(status)}`
+
+  return element('span', { class: name }, status)
+}
+
+function timeElement(datetime) {
+  return element('time', { datetime, title: datetime }, since(datetime))
+}
+
+function groupName(e) {
+  return e.group || e.source || 'ungrouped'
+}
+
+// rollup returns the status of a group from the `values` of the statuses of its
+// events using the `rule`, which must be kept in sync with `Rollup` in the event
+// package.
+function rollup(rule, values) {
+  const counts = {}
+  for (const value of values) {
+    const status = severity.includes(value) ? value : 'unknown'
+    counts[status] = (counts[status] || 0) + 1
+  }
+
+  const present = severity.filter((s) => counts[s])
+  if (present.length === 0) {
+    return 'unknown'
+  }
+
+  switch (rule) {
+    case 'any-fail':
+      return counts.fail ? 'fail' : 'pass'
+    case 'majority':
+      return present.reduce((a, b) => (counts[b] >= counts[a] ? b : a))
+    default:
+      return present[present.length - 1]
+  }
+}
+
+// updateRollup updates the status shown for the group `section` from the
+// statuses of the events in it.
+function updateRollup(container, section) {
+  const values = Array.from(
+    section.querySelectorAll('tr[data-event-id] .status'),
+    (node) => node.textContent
+  )
+  const status = rollup(container.dataset.rollup, values)
+
+  const badge = section.querySelector('[data-rollup]')
+  badge.className = `status status-${statusClass(status)}`
+  badge.textContent = status
+}
+
+function highlight(row) {
+  row.classList.remove('updated')
+  // Force a reflow so the animation is restarted for repeated updates
+  row.getBoundingClientRect()
+  row.classList.add('updated')
+}
+
+// findGroup returns the table body for the group `name`, creating the group in
+// order on the page if it does not already exist.
+function findGroup(container, name) {
+  const sections = Array.from(container.querySelectorAll('section.group'))
+
+  const existing = sections.find((s) => s.dataset.group === name)
+  if (existing) {
+    return existing.querySelector('tbody')
+  }
+
+  document.getElementById('empty')?.remove()
+
+  const headings = ['Event', 'Status', 'Message', 'Updated']
+  const head = element(
+    'thead',
+    {},
+    element('tr', {}, ...headings.map((h) => element('th', {}, h)))
+  )
+  const body = element('tbody')
+  const section = element(
+    'section',
+    { class: 'group', 'data-group': name },
+    element(
+      'h2',
+      {},
+      `${name} `,
+      element('span', { class: 'status', 'data-rollup': '' })
+    ),
+    element('table', { class: 'events' }, head, body)
+  )
+
+  const after = sections.find((s) => s.dataset.group > name)
+  container.insertBefore(section, after || null)
+
+  return body
+}
+
+// removeFromIndex removes the row for the event `id` from the dashboard, along
+// with its group if it has no other events.
+function removeFromIndex(container, id) {
+  const rows = Array.from(container.querySelectorAll('tr[data-event-id]'))
+  const row = rows.find((r) => r.dataset.eventId === id)
+  if (!row) {
+    return
+  }
+
+  const section = row.closest('section.group')
+  row.remove()
+
+  if (section.querySelectorAll('tr[data-event-id]').length === 0) {
+    section.remove()
+  } else {
+    updateRollup(container, section)
+  }
+}
+
+// applyToIndex updates or adds the row for the event `e` on the dashboard, or
+// removes it if the event has been removed.
+function applyToIndex(container, e) {
+  const id = e['event-id']
+  if (e.removed) {
+    removeFromIndex(container, id)
+
+    return
+  }
+
+  const cells = [
+    element(
+      'td',
+      {},
+      element('a', { href: `/events/${encodeURIComponent(id)}` }, id)
+    ),
+    element('td', {}, statusElement(e.status)),
+    element('td', { class: 'message' }, e.message || ''),
+    element('td', {}, timeElement(e.timestamp)),
+  ]
+
+  const rows = Array.from(container.querySelectorAll('tr[data-event-id]'))
+  let row = rows.find((r) => r.dataset.eventId === id)
+
+  if (row) {
+    const shown = row.querySelector('time')?.getAttribute('datetime')
+    if (shown && new Date(shown) > new Date(e.timestamp)) {
+      // Ignore updates which are older than the one already shown
+      return
+    }
+  }
+
+  if (row && row.closest('section.group').dataset.group === groupName(e)) {
+    row.replaceChildren(...cells)
+  } else {
+    if (row) {
+      removeFromIndex(container, id)
+    }
+
+    row = element('tr', { 'data-event-id': id }, ...cells)
+
+    const body = findGroup(container, groupName(e))
+    const after = Array.from(body.rows).find((r) => r.dataset.eventId > id)
+    body.insertBefore(row, after || null)
+  }
+
+  updateRollup(container, row.closest('section.group'))
+  highlight(row)
+}
+
+// applyToEvent adds the update for the event `e` to its history, if it is the
+// event being shown, or marks it as removed.
+function applyToEvent(container, e) {
+  if (container.dataset.eventId !== e['event-id']) {
+    return
+  }
+
+  const current = document.getElementById('current')
+  if (e.removed) {
+    current.className = 'status status-unknown'
+    current.textContent = 'removed'
+
+    return
+  }
+
+  current.className = `status status-${statusClass(e.status)}`
+  current.textContent = e.status
+
+  const row = element(
+    'tr',
+    {},
+    element('td', {}, statusElement(e.status)),
+    element('td', { class: 'message' }, e.message || ''),
+    element('td', {}, timeElement(e.timestamp)),
+    element('td', {}, timeElement(e.received))
+  )
+
+  document.getElementById('history').prepend(row)
+  highlight(row)
+}
+
+function connect(container) {
+  const live = document.getElementById('live')
+  const source = new EventSource('/api/v1/events/stream')
+
+  source.addEventListener('open', () => {
+    live.textContent = 'live'
+    live.classList.add('connected')
+  })
+
+  source.addEventListener('error', () => {
+    live.textContent = 'reconnecting'
+    live.classList.remove('connected')
+  })
+
+  source.addEventListener('event', (message) => {
+    const e = JSON.parse(message.data)
+
+    if (container.dataset.stream === 'all') {
+      applyToIndex(container, e)
+    } else {
+      applyToEvent(container, e)
+    }
+  })
+}
+
+setInterval(refreshTimes, 15000)
+
+const container = document.querySelector('[data-stream]')
+if (container && window.EventSource) {
+  connect(container)
+}
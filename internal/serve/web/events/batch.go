@@ -0,0 +1,308 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/serve/middleware"
+
+	slogg "github.com/samber/slog-gin"
+)
+
+const (
+	// batchAction is the value of the `action` parameter for the batch
+	// endpoint, as Gin includes the colon from the path in the parameter.
+	batchAction = ":batch"
+	// documentsContext is the name of the value in the Gin context which holds
+	// the JSON document for each event in the batch.
+	documentsContext = "events.documents"
+)
+
+// errEmptyBatch is returned when a batch does not hold any events.
+var errEmptyBatch = errors.New("no events found in the batch")
+
+// Batch holds the limits on the batches of events which can be submitted
+// together to the service.
+type Batch struct {
+	// Events is the maximum number of events in a single batch, or zero for no
+	// limit.
+	Events int
+	// Bytes is the maximum size of the body of a single batch, or zero for no
+	// limit.
+	Bytes int64
+}
+
+// result is the outcome of processing a single event from a batch, returned
+// in the same order as the events were submitted.
+type result struct {
+	// Index is the position of the event in the batch, starting from zero.
+	Index int `json:"index"`
+	// ID is the ID of the event, if it could be parsed.
+	ID string `json:"event-id,omitempty"`
+	// Code is the HTTP status code which would have been returned had the
+	// event been submitted on its own.
+	Code int `json:"code"`
+	// Status is the short name for the outcome of processing the event.
+	Status string `json:"status"`
+	// Message is the description of the outcome of processing the event.
+	Message string `json:"message"`
+	// Event is the processed event, if it was accepted.
+	Event *event.Event `json:"event,omitempty"`
+	// Errors is the list of the fields in the event which failed validation.
+	Errors event.ValidationErrors `json:"errors,omitempty"`
+}
+
+// action checks that the request is for the batch endpoint, as the route for
+// it matches any path starting with `/events`, rejecting any other path with
+// a 404 (Page Not Found) response back to the client.
+func action(c *gin.Context) {
+	if c.Param("action") == batchAction {
+		c.Next()
+
+		return
+	}
+
+	c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+		"code":    http.StatusNotFound,
+		"status":  "page-not-found",
+		"message": "The path requested could not be found",
+		"path":    c.Request.URL.Path,
+	})
+}
+
+// readBody reads the body of the request, up to `limit` bytes (unless zero),
+// returning false after aborting the request with a 413 (Request Entity Too
+// Large) response if it is larger, or a 400 (Bad Request) response if it could
+// not be read.
+func readBody(c *gin.Context, limit int64) ([]byte, bool) {
+	reader := c.Request.Body
+	if limit > 0 {
+		reader = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	}
+
+	body, err := io.ReadAll(reader)
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		entityTooLarge(c, "request-too-large",
+			fmt.Sprintf("The body of the request must be at most %d bytes", limit), err)
+
+		return nil, false
+	}
+
+	if err != nil {
+		badRequest(c, "invalid-body", "The body of the request could not be read", err)
+		c.Abort()
+
+		return nil, false
+	}
+
+	return body, true
+}
+
+// read provides the middleware which reads the body of a single event, up to
+// the same size limit as a batch, rejecting larger requests with a 413 (Request
+// Entity Too Large) response back to the client, whether or not the body will
+// also be read to find repeated submissions.
+func (b Batch) read(c *gin.Context) {
+	body, ok := readBody(c, b.Bytes)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+}
+
+// parse provides the middleware which reads the body of the batch, up to the
+// size limit, and separates it into the JSON document for each event, so that
+// the batch can be charged against the rate limit for each event it holds,
+// rejecting batches which cannot be processed at all with a 400 (Bad Request)
+// or 413 (Request Entity Too Large) response back to the client.
+func (b Batch) parse(c *gin.Context) {
+	body, ok := readBody(c, b.Bytes)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	documents, err := split(body)
+	if err != nil {
+		badRequest(c, "invalid-json", "The batch could not be parsed as a JSON array or newline-delimited JSON documents", err)
+		c.Abort()
+
+		return
+	}
+
+	if len(documents) == 0 {
+		badRequest(c, "empty-batch", "The batch must hold at least one event", errEmptyBatch)
+		c.Abort()
+
+		return
+	}
+
+	if b.Events > 0 && len(documents) > b.Events {
+		entityTooLarge(c, "too-many-events",
+			fmt.Sprintf("The batch must hold at most %d events", b.Events),
+			fmt.Errorf("%d events found in the batch", len(documents)))
+
+		return
+	}
+
+	c.Set(documentsContext, documents)
+	middleware.SetCost(c, len(documents))
+
+	c.Next()
+}
+
+// submit provides the endpoint for clients to submit a batch of new events, or
+// updates to existing events, as either a JSON array or newline-delimited JSON
+// documents, as separated by `parse`, processing each event in order on its
+// own, and returning a 200 (OK) response with the outcome for each event.
+func (b Batch) submit(c *gin.Context) {
+	documents, _ := c.MustGet(documentsContext).([]json.RawMessage)
+
+	results := make([]*result, len(documents))
+	accepted := 0
+
+	// Process the events in order, so that updates to the same event within
+	// the batch are applied in the order they were sent
+	for i, document := range documents {
+		results[i] = processDocument(c.Request.Context(), i, document)
+		if results[i].Code == http.StatusAccepted {
+			accepted++
+		}
+	}
+
+	slogg.AddCustomAttributes(c,
+		slog.Group("batch",
+			slog.Int("events", len(results)),
+			slog.Int("accepted", accepted),
+			slog.Int("rejected", len(results)-accepted),
+		),
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":     http.StatusOK,
+		"status":   "processed",
+		"message":  "The batch has been processed",
+		"accepted": accepted,
+		"rejected": len(results) - accepted,
+		"results":  results,
+	})
+}
+
+// split separates the `body` of a batch into the JSON document for each event,
+// reading it as a JSON array if it starts with `[`, or otherwise as a stream of
+// newline-delimited JSON documents.
+func split(body []byte) ([]json.RawMessage, error) {
+	body = bytes.TrimSpace(body)
+
+	if bytes.HasPrefix(body, []byte("[")) {
+		var documents []json.RawMessage
+		if err := json.Unmarshal(body, &documents); err != nil {
+			return nil, err
+		}
+
+		return documents, nil
+	}
+
+	documents := []json.RawMessage{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+
+	for {
+		var document json.RawMessage
+
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			return documents, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		documents = append(documents, document)
+	}
+}
+
+// processDocument parses and processes the event in the JSON `document` at
+// position `index` in a batch, returning the outcome.
+func processDocument(ctx context.Context, index int, document json.RawMessage) *result {
+	var e event.Event
+
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&e); err != nil {
+		return &result{
+			Index:   index,
+			Code:    http.StatusBadRequest,
+			Status:  "invalid-json",
+			Message: "The event could not be parsed as a JSON document: " + err.Error(),
+		}
+	}
+
+	f := process(ctx, &e)
+	if f == nil {
+		return &result{
+			Index:   index,
+			ID:      e.ID,
+			Code:    http.StatusAccepted,
+			Status:  "accepted",
+			Message: "The event has been accepted for processing",
+			Event:   &e,
+		}
+	}
+
+	if f.code == http.StatusInternalServerError {
+		slog.Error(
+			"Failed to process event in batch",
+			slog.Group("error", slog.String("message", f.err.Error())),
+			slog.Group("event", slog.String("id", e.ID), slog.Int("index", index)),
+		)
+	}
+
+	r := &result{
+		Index:   index,
+		ID:      e.ID,
+		Code:    f.code,
+		Status:  f.status,
+		Message: f.message,
+	}
+
+	var errs event.ValidationErrors
+	if errors.As(f.err, &errs) {
+		r.Errors = errs
+	}
+
+	return r
+}
+
+// entityTooLarge provides the response for requests which are larger than the
+// service will process, with the `status` and `message` explaining which limit
+// was reached, necessitating a 413 (Request Entity Too Large) response back to
+// the client.
+func entityTooLarge(c *gin.Context, status, message string, err error) {
+	slogg.AddCustomAttributes(c,
+		slog.Group("error",
+			slog.String("message", err.Error()),
+		),
+	)
+
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+		"code":    http.StatusRequestEntityTooLarge,
+		"status":  status,
+		"message": message,
+		"path":    c.Request.URL.Path,
+	})
+}
@@ -0,0 +1,158 @@
+package events_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/serve/middleware"
+	"github.com/n3tuk/dashboard/internal/store"
+)
+
+// submitBatch sends the `body` to the batch endpoint at the `path`, returning
+// the recorded response and the decoded JSON body.
+func submitBatch(t *testing.T, router *gin.Engine, path, body string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+apiKey)
+	router.ServeHTTP(w, r)
+
+	var response map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	return w, response
+}
+
+// TestBatchArray tests that each event in a JSON array is processed in order,
+// with the outcome for each event returned, even if others are rejected.
+//
+//nolint:paralleltest // the event store is shared by the package
+func TestBatchArray(t *testing.T) {
+	s := store.NewMemory()
+	router := newStoreRouter(t, s)
+
+	w, response := submitBatch(t, router, "/api/v1/events:batch", `[
+		{"event-id":"build","status":"running","timestamp":"2024-07-01T12:00:00Z"},
+		{"event-id":"build","status":"pass","timestamp":"2024-07-01T12:01:00Z"},
+		{"message":"missing fields"}
+	]`)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "processed", response["status"])
+	assert.InDelta(t, 2, response["accepted"], 0)
+	assert.InDelta(t, 1, response["rejected"], 0)
+
+	results, ok := response["results"].([]any)
+	require.True(t, ok)
+	require.Len(t, results, 3)
+
+	last, ok := results[2].(map[string]any)
+	require.True(t, ok)
+	assert.InDelta(t, 2, last["index"], 0)
+	assert.InDelta(t, http.StatusBadRequest, last["code"], 0)
+	assert.Equal(t, "invalid-event", last["status"])
+	assert.Len(t, last["errors"], 2)
+
+	current, err := s.Get(context.Background(), "build")
+	require.NoError(t, err)
+	assert.Equal(t, "pass", current.Status)
+}
+
+// TestBatchNDJSON tests that newline-delimited JSON documents are accepted,
+// and that invalid changes of status are only rejected for that event.
+//
+//nolint:paralleltest // the event store is shared by the package
+func TestBatchNDJSON(t *testing.T) {
+	router := newStoreRouter(t, store.NewMemory())
+
+	w, response := submitBatch(t, router, "/api/v1/events:batch",
+		`{"event-id":"deploy","status":"fail","timestamp":"2024-07-01T12:00:00Z"}`+"\n"+
+			`{"event-id":"deploy","status":"running","timestamp":"2024-07-01T12:01:00Z"}`+"\n"+
+			`{"event-id":"test","status":"pass","unknown":true}`+"\n")
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.InDelta(t, 1, response["accepted"], 0)
+
+	results, ok := response["results"].([]any)
+	require.True(t, ok)
+	require.Len(t, results, 3)
+
+	for i, status := range []string{"accepted", "invalid-transition", "invalid-json"} {
+		r, ok := results[i].(map[string]any)
+		require.True(t, ok)
+		assert.Equal(t, status, r["status"], i)
+	}
+}
+
+// TestBatchErrors tests that batches which cannot be processed at all are
+// rejected without processing any of their events.
+func TestBatchErrors(t *testing.T) {
+	t.Parallel()
+
+	router := newRouter(t)
+	event := `{"event-id":"test","status":"pass"},`
+
+	for _, test := range []struct {
+		path   string
+		body   string
+		code   int
+		status string
+	}{
+		{"/api/v1/events:batch", `[{"event-id":`, http.StatusBadRequest, "invalid-json"},
+		{"/api/v1/events:batch", `[]`, http.StatusBadRequest, "empty-batch"},
+		{"/api/v1/events:batch", "\n", http.StatusBadRequest, "empty-batch"},
+		{"/api/v1/events:batch", "[" + strings.Repeat(event, 4) + "{}]", http.StatusRequestEntityTooLarge, "too-many-events"},
+		{"/api/v1/events:batch", "[" + strings.Repeat(event, 50) + "{}]", http.StatusRequestEntityTooLarge, "request-too-large"},
+		{"/api/v1/events:other", `[]`, http.StatusNotFound, "page-not-found"},
+	} {
+		w, response := submitBatch(t, router, test.path, test.body)
+
+		assert.Equal(t, test.code, w.Code, test.status)
+		assert.Equal(t, test.status, response["status"])
+	}
+}
+
+// TestBatchRateLimit tests that a batch is charged against the rate limit for
+// each event it holds, rather than once for the whole batch.
+//
+//nolint:paralleltest // the rate limits are shared by the package
+func TestBatchRateLimit(t *testing.T) {
+	require.NoError(t, middleware.SetRateLimits(map[string]middleware.Limit{
+		"events": {Rate: 0.001, Burst: 4},
+	}))
+	t.Cleanup(func() { _ = middleware.SetRateLimits(map[string]middleware.Limit{}) })
+
+	router := newRouter(t)
+	batch := `[{"event-id":"one","status":"pass"},{"event-id":"two","status":"pass"},{"event-id":"three","status":"pass"}]`
+
+	w, _ := submitBatch(t, router, "/api/v1/events:batch", batch)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+
+	w, response := submitBatch(t, router, "/api/v1/events:batch", batch)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "too-many-requests", response["status"])
+}
+
+// TestBatchUnauthorized tests that the batch is rejected before its body is
+// read when the request is not authorized.
+func TestBatchUnauthorized(t *testing.T) {
+	t.Parallel()
+
+	router := newRouter(t)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/events:batch", strings.NewReader(strings.Repeat("[", 2048)))
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
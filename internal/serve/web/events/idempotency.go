@@ -0,0 +1,274 @@
+package events
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/serve/middleware"
+
+	slogg "github.com/samber/slog-gin"
+)
+
+const (
+	// IdempotencyHeader is the header used by clients to identify repeated
+	// submissions of the same request.
+	IdempotencyHeader = "Idempotency-Key"
+	// ReplayedHeader is the header set on responses which were replayed from
+	// the original request with the same idempotency key.
+	ReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLength is the maximum number of characters allowed in an
+	// idempotency key.
+	maxIdempotencyKeyLength = 255
+	// inProgressRetry is the time (in seconds) clients are asked to wait before
+	// making a request again while the original request is still in progress.
+	inProgressRetry = "1"
+)
+
+// idempotency holds the responses to the requests made with each idempotency
+// key, so that repeated submissions of the same request within the `window`
+// are given the original response, rather than being processed again.
+type idempotency struct {
+	mutex     sync.Mutex
+	window    time.Duration
+	limit     int64
+	responses map[string]*response
+	swept     time.Time
+}
+
+// response holds the response to a request, once it has been made, along with
+// the `digest` of the body of the request, so that different requests using
+// the same idempotency key can be found.
+type response struct {
+	digest      string
+	done        bool
+	status      int
+	contentType string
+	body        []byte
+	expires     time.Time
+}
+
+// recorder captures the body of the response as it is written to the client,
+// so that it can be replayed for repeated requests.
+type recorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+// Write writes the `data` to both the client and the recorded body.
+func (r *recorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+
+	return r.ResponseWriter.Write(data)
+}
+
+// WriteString writes the string `s` to both the client and the recorded body.
+func (r *recorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+
+	return r.ResponseWriter.WriteString(s)
+}
+
+// newIdempotency creates the store for the responses to requests, keeping them
+// for the `window`, and reading at most `limit` bytes (unless zero) from the
+// body of each request.
+func newIdempotency(window time.Duration, limit int64) *idempotency {
+	return &idempotency{
+		window:    window,
+		limit:     limit,
+		responses: map[string]*response{},
+	}
+}
+
+// handler provides the middleware which replays the original response for
+// repeated requests, identified by the `Idempotency-Key` header, or, if not
+// set, by the ID of the event and the timestamp set by the sender. Requests
+// which reuse an idempotency key with a different body are rejected, but only
+// when the key was set by the client.
+func (i *idempotency) handler(c *gin.Context) {
+	body, ok := readBody(c, i.limit)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	key, explicit := c.GetHeader(IdempotencyHeader), true
+	if key == "" {
+		key, explicit = defaultKey(body), false
+	}
+
+	if key == "" {
+		c.Next()
+
+		return
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"code":    http.StatusBadRequest,
+			"status":  "invalid-idempotency-key",
+			"message": "The idempotency key must be at most 255 characters",
+			"path":    c.Request.URL.Path,
+		})
+
+		return
+	}
+
+	// Keep the keys for each API key separate, so that one client cannot see
+	// the responses to another
+	scoped := "anonymous:" + key
+	if k := middleware.Key(c); k != nil {
+		scoped = "key:" + k.Name + ":" + key
+	}
+
+	sum := sha256.Sum256(body)
+	digest := hex.EncodeToString(sum[:])
+
+	current, replay := i.begin(scoped, digest, time.Now())
+
+	switch {
+	case current == nil && !explicit:
+		// Another update to the event was made at the same time, so this is not
+		// a repeated request, and is processed as normal
+		c.Next()
+
+		return
+	case current == nil:
+		conflict(c, http.StatusUnprocessableEntity, "idempotency-key-reused",
+			"The idempotency key has already been used for a different request")
+
+		return
+	case replay && !current.done:
+		c.Header("Retry-After", inProgressRetry)
+		conflict(c, http.StatusConflict, "request-in-progress",
+			"The original request with the idempotency key is still being processed")
+
+		return
+	case replay:
+		slogg.AddCustomAttributes(c,
+			slog.Group("idempotency",
+				slog.String("key", key),
+				slog.Bool("replayed", true),
+			),
+		)
+
+		c.Header(ReplayedHeader, "true")
+		c.Data(current.status, current.contentType, current.body)
+		c.Abort()
+
+		return
+	}
+
+	writer := &recorder{ResponseWriter: c.Writer}
+	c.Writer = writer
+
+	// Always finish the response, even if the request panics, so that it is not
+	// left in progress for the whole window, blocking any retries
+	completed := false
+	defer func() { i.finish(scoped, writer, completed) }()
+
+	c.Next()
+
+	completed = true
+}
+
+// begin finds the response for the idempotency `key` at the time `now`,
+// returning it with `replay` set if the request has already been made with the
+// same `digest`, or a new response if it has not been made at all, or nil if
+// the request was made with a different digest.
+func (i *idempotency) begin(key, digest string, now time.Time) (*response, bool) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.sweep(now)
+
+	if current, ok := i.responses[key]; ok && now.Before(current.expires) {
+		if current.digest != digest {
+			return nil, false
+		}
+
+		// Return a copy, as the response may still be being recorded
+		replay := *current
+
+		return &replay, true
+	}
+
+	current := &response{digest: digest, expires: now.Add(i.window)}
+	i.responses[key] = current
+
+	return current, false
+}
+
+// finish records the response written by the `writer` against the idempotency
+// `key`, unless the request was not `completed` or failed within the service,
+// so that it can be made again.
+func (i *idempotency) finish(key string, writer *recorder, completed bool) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	current, ok := i.responses[key]
+	if !ok {
+		return
+	}
+
+	if !completed || writer.Status() >= http.StatusInternalServerError {
+		delete(i.responses, key)
+
+		return
+	}
+
+	current.done = true
+	current.status = writer.Status()
+	current.contentType = writer.Header().Get("Content-Type")
+	current.body = writer.body.Bytes()
+}
+
+// sweep removes the responses which have expired at the time `now`, checking
+// at most once each window.
+func (i *idempotency) sweep(now time.Time) {
+	if now.Sub(i.swept) < i.window {
+		return
+	}
+
+	i.swept = now
+
+	for key, current := range i.responses {
+		if !now.Before(current.expires) {
+			delete(i.responses, key)
+		}
+	}
+}
+
+// defaultKey returns the idempotency key for the event in the `body`, made
+// from its ID and the timestamp set by the sender, or an empty string if the
+// event cannot be parsed or has no timestamp.
+func defaultKey(body []byte) string {
+	var e event.Event
+	if err := json.Unmarshal(body, &e); err != nil {
+		return ""
+	}
+
+	return e.IdempotencyKey()
+}
+
+// conflict provides the response for requests which cannot be processed as
+// they reuse an idempotency key, with the `code`, `status`, and `message`
+// explaining why.
+func conflict(c *gin.Context, code int, status, message string) {
+	c.AbortWithStatusJSON(code, gin.H{
+		"code":    code,
+		"status":  status,
+		"message": message,
+		"path":    c.Request.URL.Path,
+	})
+}
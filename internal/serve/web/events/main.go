@@ -0,0 +1,202 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/serve/middleware"
+	"github.com/n3tuk/dashboard/internal/store"
+
+	slogg "github.com/samber/slog-gin"
+)
+
+var (
+	events    store.EventStore
+	publisher event.Publisher
+)
+
+// Attach takes a reference to the Gin router group for the versioned API and
+// attaches all the expected endpoints which can be used by clients through
+// this package, saving the events submitted to the event store `s`, and then
+// notifying `p` of each event once saved, with each client limited by `limit`.
+// Events can be submitted on their own, or together in batches, where the body
+// of either is limited to the size of a `batch`, and repeated submissions
+// within the `window` are given the original response, unless it is zero. The
+// events, and the history of each event, can also be listed from the store.
+func Attach(r *gin.RouterGroup, s store.EventStore, p event.Publisher, limit gin.HandlerFunc, window time.Duration, batch Batch) {
+	events = s
+	publisher = p
+
+	authorize := middleware.Authorize(middleware.ScopeEventsWrite)
+
+	handlers := []gin.HandlerFunc{limit}
+	if window > 0 {
+		handlers = append(handlers, newIdempotency(window, batch.Bytes).handler)
+	}
+
+	single := append([]gin.HandlerFunc{authorize, batch.read}, handlers...)
+	r.POST("/events", append(single, submit)...)
+	// Gin cannot match a literal colon in a path, so the batch endpoint is
+	// matched as a parameter, and any other path is rejected by `action`. The
+	// batch is parsed before the rate limit so it is charged for each event
+	batches := append([]gin.HandlerFunc{authorize, action, batch.parse}, handlers...)
+	r.POST("/events:action", append(batches, batch.submit)...)
+	r.GET("/events", middleware.Authorize(middleware.ScopeEventsRead), list)
+	r.GET("/events/:id/history", middleware.Authorize(middleware.ScopeEventsRead), history)
+}
+
+// submit provides the endpoint for clients to submit a new event, or an update
+// to an existing event, as a JSON document, validating it and returning a 202
+// (Accepted) response with the processed event if successful, or a 400 (Bad
+// Request) response listing the problems found with the event if not.
+func submit(c *gin.Context) {
+	var e event.Event
+
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&e); err != nil {
+		badRequest(c, "invalid-json", "The event could not be parsed as a JSON document", err)
+
+		return
+	}
+
+	slogg.AddCustomAttributes(c,
+		slog.Group("event",
+			slog.String("id", e.ID),
+			slog.String("status", e.Status),
+		),
+	)
+
+	if f := process(c.Request.Context(), &e); f != nil {
+		switch f.code {
+		case http.StatusBadRequest:
+			badRequest(c, f.status, f.message, f.err)
+		case http.StatusConflict:
+			invalidTransition(c, f.err)
+		default:
+			internalError(c, f.message, f.err)
+		}
+
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"code":    http.StatusAccepted,
+		"status":  "accepted",
+		"message": "The event has been accepted for processing",
+		"event":   e,
+	})
+}
+
+// failure holds the reason an event could not be processed, along with the
+// code, status, and message to be returned to the client.
+type failure struct {
+	code    int
+	status  string
+	message string
+	err     error
+}
+
+// process normalises and validates the event `e`, checks the change from the
+// current status of the event, and then saves it to the event store, notifying
+// the publisher once saved, returning the reason it failed if it could not be
+// processed.
+func process(ctx context.Context, e *event.Event) *failure {
+	e.Normalise(time.Now())
+
+	if err := e.Validate(); err != nil {
+		return &failure{http.StatusBadRequest, "invalid-event", "The event failed validation", err}
+	}
+
+	current, err := events.Get(ctx, e.ID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return &failure{http.StatusInternalServerError, "internal-error", "The current state of the event could not be found", err}
+	}
+
+	// Updates older than the current state are only recorded in the history of
+	// the event, so the change of status is only checked for newer updates
+	if current == nil || !e.Timestamp.Before(current.Timestamp) {
+		if err := e.Transition(current); err != nil {
+			return &failure{http.StatusConflict, "invalid-transition", "The status of the event cannot be changed: " + err.Error(), err}
+		}
+	}
+
+	if err := events.Put(ctx, e); err != nil {
+		return &failure{http.StatusInternalServerError, "internal-error", "The event could not be saved", err}
+	}
+
+	publisher.Publish(e)
+
+	return nil
+}
+
+// badRequest provides the default response for requests which cannot be
+// processed due to a problem with the request from the client, necessitating a
+// 400 (Bad Request) response back to the client, with the details of the
+// problem, and any fields which failed validation, if known.
+func badRequest(c *gin.Context, status, message string, err error) {
+	slogg.AddCustomAttributes(c,
+		slog.Group("error",
+			slog.String("message", err.Error()),
+		),
+	)
+
+	response := gin.H{
+		"code":    http.StatusBadRequest,
+		"status":  status,
+		"message": message,
+		"path":    c.Request.URL.Path,
+	}
+
+	var errs event.ValidationErrors
+	if errors.As(err, &errs) {
+		response["errors"] = errs
+	}
+
+	c.JSON(http.StatusBadRequest, response)
+}
+
+// invalidTransition provides the response for events which cannot change from
+// their current status to the new status, without being reopened,
+// necessitating a 409 (Conflict) response back to the client.
+func invalidTransition(c *gin.Context, err error) {
+	slogg.AddCustomAttributes(c,
+		slog.Group("error",
+			slog.String("message", err.Error()),
+		),
+	)
+
+	c.JSON(http.StatusConflict, gin.H{
+		"code":    http.StatusConflict,
+		"status":  "invalid-transition",
+		"message": "The status of the event cannot be changed: " + err.Error(),
+		"path":    c.Request.URL.Path,
+	})
+}
+
+// internalError provides the default response for requests which cannot be
+// processed due to a problem within the service or one of its downstream
+// services, necessitating a 500 (Internal Server Error) response back to the
+// client.
+func internalError(c *gin.Context, message string, err error) {
+	slogg.AddCustomAttributes(c,
+		slog.Group("error",
+			slog.String("message", err.Error()),
+		),
+	)
+
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"code":    http.StatusInternalServerError,
+		"status":  "internal-error",
+		"message": message,
+		"path":    c.Request.URL.Path,
+	})
+}
@@ -0,0 +1,346 @@
+package events_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/serve/middleware"
+	"github.com/n3tuk/dashboard/internal/serve/web/events"
+	"github.com/n3tuk/dashboard/internal/store"
+)
+
+// apiKey is the API key used to authenticate the requests to the events
+// endpoints.
+const apiKey = "test-api-key"
+
+// newRouter creates a new Gin engine with the events endpoints attached under
+// the versioned API path, only accepting `apiKey` to submit and list events.
+func newRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+
+	return newStoreRouter(t, store.NewMemory())
+}
+
+// newStoreRouter creates a new Gin engine with the events endpoints attached
+// under the versioned API path, saving the events in the store `s`, and only
+// accepting `apiKey` to submit and list events.
+func newStoreRouter(t *testing.T, s store.EventStore) *gin.Engine {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	keyring, err := middleware.NewKeyring([]*middleware.APIKey{{
+		Name:   "test",
+		Hash:   middleware.HashKey(apiKey),
+		Scopes: []string{middleware.ScopeEventsWrite, middleware.ScopeEventsRead},
+	}}, nil)
+	require.NoError(t, err)
+
+	middleware.SetKeyring(keyring)
+
+	router := gin.New()
+	router.Use(gin.RecoveryWithWriter(io.Discard))
+	events.Attach(router.Group("/api/v1"), s, event.Publishers{}, middleware.RateLimit("test", "web", "events"), time.Hour, events.Batch{Events: 3, Bytes: 1024})
+
+	return router
+}
+
+// submit sends the `body` to the events endpoint, returning the recorded
+// response and the decoded JSON body.
+func submit(t *testing.T, router *gin.Engine, body string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/events", strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+apiKey)
+	router.ServeHTTP(w, r)
+
+	var response map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	return w, response
+}
+
+// TestSubmitAccepted tests that a valid event is accepted.
+func TestSubmitAccepted(t *testing.T) {
+	t.Parallel()
+
+	w, response := submit(t, newRouter(t), `{"event-id":"test","status":"pass","labels":{"env":"dev"}}`)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "accepted", response["status"])
+	assert.InEpsilon(t, float64(http.StatusAccepted), response["code"], 0)
+}
+
+// TestSubmitInvalidJSON tests that a request which cannot be parsed is
+// rejected.
+func TestSubmitInvalidJSON(t *testing.T) {
+	t.Parallel()
+
+	w, response := submit(t, newRouter(t), `{"event-id":`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "invalid-json", response["status"])
+}
+
+// TestSubmitInvalidEvent tests that an event which fails validation is
+// rejected, listing the fields which failed.
+func TestSubmitInvalidEvent(t *testing.T) {
+	t.Parallel()
+
+	w, response := submit(t, newRouter(t), `{"message":"missing fields"}`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "invalid-event", response["status"])
+	assert.Len(t, response["errors"], 2)
+}
+
+// TestSubmitUnauthorized tests that an event submitted without an API key is
+// rejected.
+func TestSubmitUnauthorized(t *testing.T) {
+	t.Parallel()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/events", strings.NewReader(`{"event-id":"test","status":"pass"}`))
+	newRouter(t).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// submitWithKey sends the `body` to the events endpoint with the idempotency
+// `key`, if set, returning the recorded response.
+func submitWithKey(router *gin.Engine, body, key string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/events", strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+apiKey)
+
+	if key != "" {
+		r.Header.Set(events.IdempotencyHeader, key)
+	}
+
+	router.ServeHTTP(w, r)
+
+	return w
+}
+
+// TestSubmitIdempotencyKey tests that repeated submissions with the same
+// idempotency key are given the original response without being saved again,
+// and that the key cannot be reused for a different event.
+//
+//nolint:paralleltest // the event store is shared by the package
+func TestSubmitIdempotencyKey(t *testing.T) {
+	s := store.NewMemory()
+	router := newStoreRouter(t, s)
+	body := `{"event-id":"retry","status":"running"}`
+
+	first := submitWithKey(router, body, "attempt-1")
+	require.Equal(t, http.StatusAccepted, first.Code)
+	assert.Empty(t, first.Header().Get(events.ReplayedHeader))
+
+	second := submitWithKey(router, body, "attempt-1")
+	require.Equal(t, http.StatusAccepted, second.Code)
+	assert.Equal(t, "true", second.Header().Get(events.ReplayedHeader))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+
+	history, err := s.History(context.Background(), "retry")
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	reused := submitWithKey(router, `{"event-id":"retry","status":"pass"}`, "attempt-1")
+	assert.Equal(t, http.StatusUnprocessableEntity, reused.Code)
+
+	long := submitWithKey(router, body, strings.Repeat("a", 256))
+	assert.Equal(t, http.StatusBadRequest, long.Code)
+}
+
+// TestSubmitIdempotencyDefault tests that, without an idempotency key, events
+// are identified as repeated by their ID and the timestamp set by the sender.
+//
+//nolint:paralleltest // the event store is shared by the package
+func TestSubmitIdempotencyDefault(t *testing.T) {
+	s := store.NewMemory()
+	router := newStoreRouter(t, s)
+	body := `{"event-id":"retry","status":"running","timestamp":"2024-07-01T12:00:00Z"}`
+
+	for range 3 {
+		w := submitWithKey(router, body, "")
+		require.Equal(t, http.StatusAccepted, w.Code)
+	}
+
+	// A different update at the same time is not a repeated submission
+	w := submitWithKey(router, `{"event-id":"retry","status":"pass","timestamp":"2024-07-01T12:00:00Z"}`, "")
+	require.Equal(t, http.StatusAccepted, w.Code)
+	assert.Empty(t, w.Header().Get(events.ReplayedHeader))
+
+	// Without a timestamp, each submission is a new update
+	for range 2 {
+		w := submitWithKey(router, `{"event-id":"retry","status":"pass"}`, "")
+		require.Equal(t, http.StatusAccepted, w.Code)
+		assert.Empty(t, w.Header().Get(events.ReplayedHeader))
+	}
+
+	history, err := s.History(context.Background(), "retry")
+	require.NoError(t, err)
+	assert.Len(t, history, 4)
+}
+
+// TestSubmitIdempotencyLimit tests that the body read to find repeated
+// submissions is limited to the maximum size of a batch.
+//
+//nolint:paralleltest // the event store is shared by the package
+func TestSubmitIdempotencyLimit(t *testing.T) {
+	router := newRouter(t)
+	body := `{"event-id":"large","status":"pass","message":"` + strings.Repeat("a", 1024) + `"}`
+
+	w := submitWithKey(router, body, "large")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"request-too-large"`)
+}
+
+// TestSubmitLimit tests that the body of a single event is limited to the
+// maximum size of a batch, even when repeated submissions are not checked.
+//
+//nolint:paralleltest // the event store is shared by the package
+func TestSubmitLimit(t *testing.T) {
+	router := newRouter(t)
+	events.Attach(router.Group("/api/v2"), store.NewMemory(), event.Publishers{}, middleware.RateLimit("test", "web", "events"), 0, events.Batch{Bytes: 1024})
+
+	body := `{"event-id":"large","status":"pass","message":"` + strings.Repeat("a", 1024) + `"}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/v2/events", strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+apiKey)
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"request-too-large"`)
+}
+
+// panicStore is an event store which panics when saving an event, as long as
+// `panics` is set.
+type panicStore struct {
+	store.EventStore
+	panics atomic.Bool
+}
+
+// Put panics if `panics` is set, or saves the event `e` otherwise.
+func (s *panicStore) Put(ctx context.Context, e *event.Event) error {
+	if s.panics.Load() {
+		panic("unable to save the event")
+	}
+
+	return s.EventStore.Put(ctx, e)
+}
+
+// TestSubmitIdempotencyPanic tests that a request which panics does not leave
+// its idempotency key in progress, so that it can be made again.
+//
+//nolint:paralleltest // the event store is shared by the package
+func TestSubmitIdempotencyPanic(t *testing.T) {
+	s := &panicStore{EventStore: store.NewMemory()}
+	s.panics.Store(true)
+
+	router := newStoreRouter(t, s)
+	body := `{"event-id":"panic","status":"running"}`
+
+	w := submitWithKey(router, body, "attempt-1")
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+
+	s.panics.Store(false)
+
+	w = submitWithKey(router, body, "attempt-1")
+	require.Equal(t, http.StatusAccepted, w.Code)
+	assert.Empty(t, w.Header().Get(events.ReplayedHeader))
+}
+
+// blockingStore is an event store which waits for `release` to be closed
+// before saving an event, signalling on `started` once it has begun waiting.
+type blockingStore struct {
+	store.EventStore
+	started chan struct{}
+	release chan struct{}
+}
+
+// Put waits for `release` to be closed before saving the event `e`.
+func (s *blockingStore) Put(ctx context.Context, e *event.Event) error {
+	s.started <- struct{}{}
+	<-s.release
+
+	return s.EventStore.Put(ctx, e)
+}
+
+// TestSubmitIdempotencyInProgress tests that a repeated request made while the
+// original request is still in progress is asked to retry after a delay.
+//
+//nolint:paralleltest // the event store is shared by the package
+func TestSubmitIdempotencyInProgress(t *testing.T) {
+	s := &blockingStore{
+		EventStore: store.NewMemory(),
+		started:    make(chan struct{}, 1),
+		release:    make(chan struct{}),
+	}
+
+	router := newStoreRouter(t, s)
+	body := `{"event-id":"slow","status":"running"}`
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- submitWithKey(router, body, "slow-1") }()
+
+	<-s.started
+
+	w := submitWithKey(router, body, "slow-1")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), `"status":"request-in-progress"`)
+
+	close(s.release)
+	assert.Equal(t, http.StatusAccepted, (<-done).Code)
+}
+
+// TestSubmitTransition tests that an event which has finished cannot change
+// its status unless it is reopened, and that older updates are only recorded
+// in its history.
+//
+//nolint:paralleltest // the event store is shared by the package
+func TestSubmitTransition(t *testing.T) {
+	s := store.NewMemory()
+	router := newStoreRouter(t, s)
+
+	w, _ := submit(t, router, `{"event-id":"job","status":"running","timestamp":"2024-07-01T12:00:00Z"}`)
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	w, _ = submit(t, router, `{"event-id":"job","status":"success","timestamp":"2024-07-01T12:01:30Z"}`)
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	current, err := s.Get(context.Background(), "job")
+	require.NoError(t, err)
+	assert.Equal(t, "pass", current.Status)
+	assert.InDelta(t, 90.0, current.Duration, 0)
+
+	w, response := submit(t, router, `{"event-id":"job","status":"running","timestamp":"2024-07-01T12:02:00Z"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "invalid-transition", response["status"])
+
+	// An update from before the event finished is still recorded
+	w, _ = submit(t, router, `{"event-id":"job","status":"running","timestamp":"2024-07-01T12:01:00Z"}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	w, _ = submit(t, router, `{"event-id":"job","status":"running","timestamp":"2024-07-01T12:02:00Z","reopen":true}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	current, err = s.Get(context.Background(), "job")
+	require.NoError(t, err)
+	assert.Equal(t, "running", current.Status)
+}
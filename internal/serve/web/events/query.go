@@ -0,0 +1,391 @@
+package events
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/serve/hub"
+	"github.com/n3tuk/dashboard/internal/store"
+)
+
+const (
+	// sortID sorts the events by their ID.
+	sortID = "id"
+	// sortTimestamp sorts the events by the time they were sent.
+	sortTimestamp = "timestamp"
+
+	// orderAscending returns the events from the first to the last.
+	orderAscending = "asc"
+	// orderDescending returns the events from the last to the first.
+	orderDescending = "desc"
+
+	// keyFormat is the fixed-width format used for the times in the keys the
+	// events are sorted by, so that they sort in order as strings.
+	keyFormat = "2006-01-02T15:04:05.000000000Z"
+	// digestLength is the number of characters of the digest of an update used
+	// to tell apart updates made at the same time in its key.
+	digestLength = 16
+)
+
+// errInvalidQuery is returned when a query parameter cannot be used.
+var errInvalidQuery = errors.New("invalid query parameter")
+
+// query holds the filters, sort order, and page requested through the query
+// parameters for a list of events.
+type query struct {
+	groups     []string
+	filter     hub.Filter
+	statuses   []string
+	since      time.Time
+	until      time.Time
+	search     string
+	sort       string
+	descending bool
+	limit      int
+	after      string
+}
+
+// results is the envelope for a page of events returned to the client.
+type results struct {
+	// Events is the list of events in this page of results.
+	Events []*event.Event `json:"events"`
+	// Count is the number of events in this page of results.
+	Count int `json:"count"`
+	// Next is the cursor to pass back to request the next page of results, or
+	// empty if there are no more results.
+	Next string `json:"next"`
+}
+
+// list provides the endpoint for clients to list the current state of the
+// events, filtered, sorted, and paged through the query parameters. The group,
+// if only one is requested, is found through the event store, and when sorted
+// by ID in ascending order, the order of the store, so is the page, leaving
+// only the other filters to be checked here.
+func list(c *gin.Context) {
+	q, err := parseQuery(c, sortID, sortID, sortTimestamp)
+	if err != nil {
+		invalidQuery(c, err)
+
+		return
+	}
+
+	group := ""
+	if len(q.groups) == 1 {
+		group = q.groups[0]
+	}
+
+	if q.sort == sortID && !q.descending {
+		paged(c, q, group)
+
+		return
+	}
+
+	// Any other order needs all the events in the group, so that they can be
+	// sorted before the page is found
+	all, err := store.All(c.Request.Context(), events, group)
+	if err != nil {
+		internalError(c, "The events could not be listed", err)
+
+		return
+	}
+
+	key := func(e *event.Event) string {
+		if q.sort == sortTimestamp {
+			return e.Timestamp.UTC().Format(keyFormat) + "\x00" + e.ID
+		}
+
+		return e.ID
+	}
+
+	respond(c, q, all, key)
+}
+
+// paged sends the page of the events in the `group` which match the query `q`,
+// reading full pages of events in the order of the event store. The cursor for
+// the next page holds both the store's cursor for the page the last event was
+// read from, and the ID of that event, so the next page starts after it.
+func paged(c *gin.Context, q *query, group string) {
+	response := results{Events: []*event.Event{}}
+	cursor, last, _ := strings.Cut(q.after, "\x00")
+
+	for response.Next == "" {
+		result, err := events.List(c.Request.Context(), group, store.Page{
+			Limit:  store.MaxLimit,
+			Cursor: cursor,
+		})
+		if errors.Is(err, store.ErrInvalidCursor) {
+			invalidQuery(c, fmt.Errorf("%w: %w", errInvalidQuery, err))
+
+			return
+		}
+
+		if err != nil {
+			internalError(c, "The events could not be listed", err)
+
+			return
+		}
+
+		for _, e := range result.Events {
+			if e.ID <= last || !q.matches(e) {
+				continue
+			}
+
+			// Only give a cursor for the next page once another event is known
+			// to follow, so the last page never needs to be requested empty
+			if len(response.Events) == q.limit {
+				previous := response.Events[q.limit-1]
+				response.Next = q.next(cursor + "\x00" + previous.ID)
+
+				break
+			}
+
+			response.Events = append(response.Events, e)
+		}
+
+		if result.Next == "" {
+			break
+		}
+
+		cursor = result.Next
+	}
+
+	response.Count = len(response.Events)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// history provides the endpoint for clients to list all the updates recorded
+// for an event, filtered, sorted, and paged through the query parameters.
+func history(c *gin.Context) {
+	q, err := parseQuery(c, sortTimestamp, sortTimestamp)
+	if err != nil {
+		invalidQuery(c, err)
+
+		return
+	}
+
+	updates, err := events.History(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    http.StatusNotFound,
+			"status":  "event-not-found",
+			"message": "The event requested could not be found",
+			"path":    c.Request.URL.Path,
+		})
+
+		return
+	}
+
+	if err != nil {
+		internalError(c, "The history of the event could not be found", err)
+
+		return
+	}
+
+	// Updates can be sent at the same time, so use the time they were received
+	// and their contents to keep the order stable between pages
+	key := func(e *event.Event) string {
+		return e.Timestamp.UTC().Format(keyFormat) + e.Received.UTC().Format(keyFormat) + e.Digest()[:digestLength]
+	}
+
+	respond(c, q, updates, key)
+}
+
+// respond sends the page of the events in `all` which match the query `q`,
+// sorted by the `key` of each event.
+func respond(c *gin.Context, q *query, all []*event.Event, key func(*event.Event) string) {
+	keys := make(map[*event.Event]string, len(all))
+	matched := make([]*event.Event, 0, len(all))
+
+	for _, e := range all {
+		if !q.matches(e) {
+			continue
+		}
+
+		keys[e] = key(e)
+		if !q.follows(keys[e]) {
+			continue
+		}
+
+		matched = append(matched, e)
+	}
+
+	slices.SortFunc(matched, func(a, b *event.Event) int {
+		if q.descending {
+			return strings.Compare(keys[b], keys[a])
+		}
+
+		return strings.Compare(keys[a], keys[b])
+	})
+
+	response := results{Events: matched}
+	if len(matched) > q.limit {
+		response.Events = matched[:q.limit]
+		response.Next = q.next(keys[response.Events[q.limit-1]])
+	}
+
+	response.Count = len(response.Events)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// parseQuery reads the query parameters for a list of events, sorting by
+// `fallback` unless the `sort` parameter is set to one of the `sorts`.
+func parseQuery(c *gin.Context, fallback string, sorts ...string) (*query, error) {
+	// Only the labels are matched through the filter, as the groups are matched
+	// exactly, the same as the event store, rather than including the groups
+	// below them
+	filter, err := hub.ParseFilter(nil, c.QueryArray("label"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidQuery, err)
+	}
+
+	q := &query{
+		groups: c.QueryArray("group"),
+		filter: filter,
+		search: strings.ToLower(c.Query("q")),
+		sort:   c.DefaultQuery("sort", fallback),
+		limit:  store.DefaultLimit,
+	}
+
+	for _, status := range c.QueryArray("status") {
+		if !event.IsStatus(status) {
+			return nil, fmt.Errorf("%w: status must be one of %s", errInvalidQuery, strings.Join(event.Statuses, ", "))
+		}
+
+		q.statuses = append(q.statuses, event.Canonical(status))
+	}
+
+	if q.since, err = parseTime(c, "since"); err != nil {
+		return nil, err
+	}
+
+	if q.until, err = parseTime(c, "until"); err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(sorts, q.sort) {
+		return nil, fmt.Errorf("%w: sort must be one of %s", errInvalidQuery, strings.Join(sorts, ", "))
+	}
+
+	switch c.DefaultQuery("order", orderAscending) {
+	case orderAscending:
+	case orderDescending:
+		q.descending = true
+	default:
+		return nil, fmt.Errorf("%w: order must be one of %s, %s", errInvalidQuery, orderAscending, orderDescending)
+	}
+
+	if value, ok := c.GetQuery("limit"); ok {
+		q.limit, err = strconv.Atoi(value)
+		if err != nil || q.limit < 1 || q.limit > store.MaxLimit {
+			return nil, fmt.Errorf("%w: limit must be between 1 and %d", errInvalidQuery, store.MaxLimit)
+		}
+	}
+
+	if q.after, err = q.decode(c.Query("cursor")); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// parseTime reads the query parameter `name` as a time in the RFC 3339 format,
+// returning the zero time if it is not set.
+func parseTime(c *gin.Context, name string) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s must be a time in the RFC 3339 format", errInvalidQuery, name)
+	}
+
+	return t, nil
+}
+
+// matches checks whether the event `e` matches all the filters in the query.
+func (q *query) matches(e *event.Event) bool {
+	switch {
+	case len(q.groups) > 0 && !slices.Contains(q.groups, e.Group):
+		return false
+	case !q.filter.Matches(e):
+		return false
+	case len(q.statuses) > 0 && !slices.Contains(q.statuses, event.Canonical(e.Status)):
+		return false
+	case !q.since.IsZero() && e.Timestamp.Before(q.since):
+		return false
+	case !q.until.IsZero() && !e.Timestamp.Before(q.until):
+		return false
+	case q.search != "" && !strings.Contains(strings.ToLower(e.Message), q.search):
+		return false
+	default:
+		return true
+	}
+}
+
+// next returns the cursor for the page of results after the event with the
+// `key`, which is tied to the sort order of the query.
+func (q *query) next(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(q.order() + "\x00" + key))
+}
+
+// follows checks whether the event with the `key` comes after the last event
+// from the previous page of results, in the sort order of the query.
+func (q *query) follows(key string) bool {
+	switch {
+	case q.after == "":
+		return true
+	case q.descending:
+		return key < q.after
+	default:
+		return key > q.after
+	}
+}
+
+// decode returns the key of the last event from the previous page of results
+// from the `cursor`, or an empty string for the first page.
+func (q *query) decode(cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errInvalidQuery, store.ErrInvalidCursor)
+	}
+
+	order, key, ok := strings.Cut(string(decoded), "\x00")
+	if !ok || order != q.order() || key == "" {
+		return "", fmt.Errorf("%w: %w", errInvalidQuery, store.ErrInvalidCursor)
+	}
+
+	return key, nil
+}
+
+// order returns the name of the sort order of the query, such as `id:asc`.
+func (q *query) order() string {
+	if q.descending {
+		return q.sort + ":" + orderDescending
+	}
+
+	return q.sort + ":" + orderAscending
+}
+
+// invalidQuery provides the response for requests with query parameters which
+// cannot be used, with the problem found in `err`, necessitating a 400 (Bad
+// Request) response back to the client.
+func invalidQuery(c *gin.Context, err error) {
+	badRequest(c, "invalid-query", "The query parameters could not be used: "+err.Error(), err)
+}
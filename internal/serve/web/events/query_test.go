@@ -0,0 +1,298 @@
+package events_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/store"
+)
+
+// page is a page of events returned from the list endpoints.
+type page struct {
+	Events []*event.Event `json:"events"`
+	Count  int            `json:"count"`
+	Next   string         `json:"next"`
+}
+
+// newQueryRouter creates a new Gin engine with the events endpoints attached,
+// over a new memory store holding a set of events, and the history of one of
+// them.
+func newQueryRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+
+	return newStoreRouter(t, newQueryStore(t))
+}
+
+// newQueryStore creates a new memory store holding a set of events, and the
+// history of one of them.
+func newQueryStore(t *testing.T) store.EventStore {
+	t.Helper()
+
+	s := store.NewMemory()
+	sent := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, e := range []*event.Event{
+		{ID: "deploy", Status: "running", Group: "service/production", Message: "Deploying v1.2.0"},
+		{ID: "deploy", Status: "fail", Group: "service/production", Message: "Deployment failed"},
+		{ID: "deploy", Status: "running", Group: "service/production", Message: "Retrying", Reopen: true},
+		{ID: "deploy", Status: "pass", Group: "service/production", Message: "Deployed v1.2.0"},
+		{ID: "backup", Status: "fail", Group: "jobs", Message: "Disk FULL", Labels: map[string]string{"env": "prod"}},
+		{ID: "build", Status: "pass", Group: "service/staging", Labels: map[string]string{"env": "dev"}},
+		{ID: "check", Status: "warning", Message: "Slow response"},
+	} {
+		e.Normalise(sent)
+		e.Timestamp = sent.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, s.Put(context.Background(), e))
+	}
+
+	return s
+}
+
+// listStore is an event store which records the group and the page of each
+// request to list the events, returning at most `size` events in each page if
+// set, as DynamoDB can return fewer events than requested.
+type listStore struct {
+	store.EventStore
+
+	mutex  sync.Mutex
+	groups []string
+	pages  []store.Page
+	size   int
+}
+
+// List records the `group` and the `page` requested, and then lists the
+// events from the store.
+func (s *listStore) List(ctx context.Context, group string, page store.Page) (*store.Result, error) {
+	s.mutex.Lock()
+	s.groups = append(s.groups, group)
+	s.pages = append(s.pages, page)
+	s.mutex.Unlock()
+
+	if s.size > 0 {
+		page.Limit = min(page.Limit, s.size)
+	}
+
+	return s.EventStore.List(ctx, group, page)
+}
+
+// query makes a request to the `path`, returning the recorded response and
+// the decoded page of events.
+func query(t *testing.T, router *gin.Engine, path string) (*httptest.ResponseRecorder, *page) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	r.Header.Set("Authorization", "Bearer "+apiKey)
+	router.ServeHTTP(w, r)
+
+	var response page
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	return w, &response
+}
+
+// ids returns the IDs of the events in the `page`.
+func ids(p *page) []string {
+	list := make([]string, 0, len(p.Events))
+	for _, e := range p.Events {
+		list = append(list, e.ID)
+	}
+
+	return list
+}
+
+// TestList tests that the current state of the events can be listed, filtered
+// by each of the query parameters, and sorted.
+func TestList(t *testing.T) {
+	t.Parallel()
+
+	router := newQueryRouter(t)
+
+	for path, expected := range map[string][]string{
+		"/api/v1/events":                                      {"backup", "build", "check", "deploy"},
+		"/api/v1/events?order=desc":                           {"deploy", "check", "build", "backup"},
+		"/api/v1/events?sort=timestamp":                       {"deploy", "backup", "build", "check"},
+		"/api/v1/events?status=failed&status=warning":         {"backup", "check"},
+		"/api/v1/events?group=service/production":             {"deploy"},
+		"/api/v1/events?group=service":                        {},
+		"/api/v1/events?group=jobs&group=":                    {"backup", "check"},
+		"/api/v1/events?label=env=prod":                       {"backup"},
+		"/api/v1/events?since=2024-07-01T12:04:00Z":           {"backup", "build", "check"},
+		"/api/v1/events?until=2024-07-01T12:05:00Z":           {"backup", "deploy"},
+		"/api/v1/events?q=full":                               {"backup"},
+		"/api/v1/events?group=service/staging&label=env=dev":  {"build"},
+		"/api/v1/events?group=service/staging&label=env=prod": {},
+		"/api/v1/events?status=queued":                        {},
+	} {
+		w, response := query(t, router, path)
+
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.Equal(t, expected, ids(response), path)
+		assert.Equal(t, len(expected), response.Count, path)
+		assert.Empty(t, response.Next, path)
+	}
+}
+
+// TestListPages tests that the events can be paged through with the cursor,
+// in either order.
+func TestListPages(t *testing.T) {
+	t.Parallel()
+
+	router := newQueryRouter(t)
+
+	for order, expected := range map[string][]string{
+		"asc":  {"deploy", "backup", "build", "check"},
+		"desc": {"check", "build", "backup", "deploy"},
+	} {
+		var seen []string
+
+		path := "/api/v1/events?sort=timestamp&limit=3&order=" + order
+
+		_, response := query(t, router, path)
+		seen = append(seen, ids(response)...)
+		require.NotEmpty(t, response.Next)
+
+		_, response = query(t, router, path+"&cursor="+response.Next)
+		seen = append(seen, ids(response)...)
+		assert.Empty(t, response.Next)
+
+		assert.Equal(t, expected, seen, order)
+	}
+}
+
+// TestListStore tests that, when sorted by ID in ascending order, the group and
+// the page are found through the event store, rather than by reading all the
+// events, with the other filters still applied to each page.
+//
+//nolint:paralleltest // the event store is shared by the package
+func TestListStore(t *testing.T) {
+	s := &listStore{EventStore: newQueryStore(t)}
+	router := newStoreRouter(t, s)
+
+	_, response := query(t, router, "/api/v1/events?group=service/production&limit=1")
+	assert.Equal(t, []string{"deploy"}, ids(response))
+	assert.Equal(t, []string{"service/production"}, s.groups)
+	assert.Equal(t, []store.Page{{Limit: store.MaxLimit}}, s.pages)
+
+	var seen []string
+
+	path := "/api/v1/events?status=pass&limit=1"
+	for cursor, pages := "", 0; pages < 3; pages++ {
+		_, response := query(t, router, path+"&cursor="+cursor)
+		seen = append(seen, ids(response)...)
+
+		if cursor = response.Next; cursor == "" {
+			break
+		}
+	}
+
+	assert.Equal(t, []string{"build", "deploy"}, seen)
+
+	// Full pages are always read from the store, however few events are still
+	// needed, or are left to match the other filters
+	for _, page := range s.pages {
+		assert.Equal(t, store.MaxLimit, page.Limit)
+	}
+}
+
+// TestListStorePages tests that paging through the events gives each event
+// once, in order, when the pages from the event store and the pages of results
+// do not line up.
+//
+//nolint:paralleltest // the event store is shared by the package
+func TestListStorePages(t *testing.T) {
+	s := &listStore{EventStore: newQueryStore(t), size: 3}
+	router := newStoreRouter(t, s)
+
+	for path, expected := range map[string][]string{
+		"/api/v1/events?limit=1":             {"backup", "build", "check", "deploy"},
+		"/api/v1/events?limit=2":             {"backup", "build", "check", "deploy"},
+		"/api/v1/events?limit=1&status=pass": {"build", "deploy"},
+	} {
+		var seen []string
+
+		for cursor, pages := "", 0; pages < 5; pages++ {
+			_, response := query(t, router, path+"&cursor="+cursor)
+			seen = append(seen, ids(response)...)
+
+			if cursor = response.Next; cursor == "" {
+				break
+			}
+		}
+
+		assert.Equal(t, expected, seen, path)
+	}
+}
+
+// TestHistory tests that the history of an event can be listed, filtered,
+// sorted, and paged through.
+func TestHistory(t *testing.T) {
+	t.Parallel()
+
+	router := newQueryRouter(t)
+
+	w, response := query(t, router, "/api/v1/events/deploy/history")
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, 4, response.Count)
+	assert.Equal(t, "running", response.Events[0].Status)
+	assert.Equal(t, "pass", response.Events[3].Status)
+
+	_, response = query(t, router, "/api/v1/events/deploy/history?order=desc&limit=1")
+	require.Len(t, response.Events, 1)
+	assert.Equal(t, "pass", response.Events[0].Status)
+
+	_, response = query(t, router, "/api/v1/events/deploy/history?order=desc&limit=1&cursor="+response.Next)
+	require.Len(t, response.Events, 1)
+	assert.Equal(t, "running", response.Events[0].Status)
+	assert.Equal(t, "Retrying", response.Events[0].Message)
+
+	_, response = query(t, router, "/api/v1/events/deploy/history?status=running&q=deploying")
+	require.Len(t, response.Events, 1)
+	assert.Equal(t, "Deploying v1.2.0", response.Events[0].Message)
+}
+
+// TestQueryErrors tests that unknown events, and query parameters which cannot
+// be used, are rejected with the standard error response.
+func TestQueryErrors(t *testing.T) {
+	t.Parallel()
+
+	router := newQueryRouter(t)
+
+	for path, code := range map[string]int{
+		"/api/v1/events/missing/history":            http.StatusNotFound,
+		"/api/v1/events?status=custom":              http.StatusBadRequest,
+		"/api/v1/events?since=yesterday":            http.StatusBadRequest,
+		"/api/v1/events?label=env":                  http.StatusBadRequest,
+		"/api/v1/events?sort=status":                http.StatusBadRequest,
+		"/api/v1/events?order=random":               http.StatusBadRequest,
+		"/api/v1/events?limit=0":                    http.StatusBadRequest,
+		"/api/v1/events?limit=5000":                 http.StatusBadRequest,
+		"/api/v1/events?cursor=!!!":                 http.StatusBadRequest,
+		"/api/v1/events?cursor=aWQ6ZGVzYwBkZXBsb3k": http.StatusBadRequest,
+		"/api/v1/events/deploy/history?sort=id":     http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Authorization", "Bearer "+apiKey)
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, code, w.Code, path)
+
+		var response map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.InDelta(t, code, response["code"], 0, path)
+		assert.NotEmpty(t, response["status"], path)
+		assert.NotEmpty(t, response["message"], path)
+		assert.NotEmpty(t, response["path"], path)
+	}
+}
@@ -0,0 +1,130 @@
+package groups
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/store"
+)
+
+// Index keeps the group and the status of every event in memory, so that the
+// hierarchy of groups can be built without listing every event from the store
+// on each request. It is kept up to date as a `Publisher` of each event saved
+// or removed, and is replaced with the current state of all the events after
+// each sweep of the reaper, which already needs to list them.
+type Index struct {
+	store store.EventStore
+
+	mutex  sync.RWMutex
+	loaded bool
+	events map[string]*entry
+}
+
+// entry is the group and status of a single event in the index, along with
+// the time it was last changed by a published event.
+type entry struct {
+	group     string
+	status    string
+	timestamp time.Time
+	updated   time.Time
+}
+
+// NewIndex creates a new, empty, `Index` of the events, which will be loaded
+// from the store `s` when first used, unless it has already been replaced.
+func NewIndex(s store.EventStore) *Index {
+	return &Index{
+		store:  s,
+		events: map[string]*entry{},
+	}
+}
+
+// Publish updates the index with the event `e`, removing the event if it has
+// been removed, or ignoring it if it is older than the event in the index.
+func (i *Index) Publish(e *event.Event) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if e.Removed {
+		delete(i.events, e.ID)
+
+		return
+	}
+
+	if current, ok := i.events[e.ID]; ok && e.Timestamp.Before(current.timestamp) {
+		return
+	}
+
+	i.events[e.ID] = &entry{
+		group:     e.Group,
+		status:    e.Status,
+		timestamp: e.Timestamp,
+		updated:   time.Now(),
+	}
+}
+
+// Replace replaces the index with the current state of `all` the events, as
+// listed from the store from the time `started`. Any events published since
+// then are kept, as they may not have been seen when the events were listed.
+func (i *Index) Replace(all []*event.Event, started time.Time) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	events := make(map[string]*entry, len(all))
+
+	for _, e := range all {
+		events[e.ID] = &entry{group: e.Group, status: e.Status, timestamp: e.Timestamp}
+	}
+
+	for id, current := range i.events {
+		if !current.updated.Before(started) {
+			events[id] = current
+		}
+	}
+
+	i.events = events
+	i.loaded = true
+}
+
+// Build creates the hierarchy of groups from the events in the index, rolling
+// up the status of each group using the `rule`, loading all the events from
+// the store first if the index has not yet been loaded.
+func (i *Index) Build(ctx context.Context, rule string) (*Group, error) {
+	if err := i.load(ctx); err != nil {
+		return nil, err
+	}
+
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	all := make([]*event.Event, 0, len(i.events))
+	for _, current := range i.events {
+		all = append(all, &event.Event{Group: current.group, Status: current.status})
+	}
+
+	return Build(all, rule), nil
+}
+
+// load loads all the events from the store into the index, unless it has
+// already been loaded, or replaced after a sweep of the reaper.
+func (i *Index) load(ctx context.Context) error {
+	i.mutex.RLock()
+	loaded := i.loaded
+	i.mutex.RUnlock()
+
+	if loaded {
+		return nil
+	}
+
+	started := time.Now()
+
+	all, err := store.All(ctx, i.store, "")
+	if err != nil {
+		return err
+	}
+
+	i.Replace(all, started)
+
+	return nil
+}
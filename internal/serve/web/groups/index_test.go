@@ -0,0 +1,77 @@
+package groups_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/serve/web/groups"
+	"github.com/n3tuk/dashboard/internal/store"
+)
+
+// TestIndex tests that the index is loaded from the store when first used, and
+// then kept up to date from the events published to it, without the store.
+func TestIndex(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	sent := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	s := store.NewMemory()
+	require.NoError(t, s.Put(ctx, &event.Event{ID: "web", Status: event.StatusPass, Group: "service/web", Timestamp: sent}))
+
+	i := groups.NewIndex(s)
+
+	root, err := i.Build(ctx, event.RollupWorstOf)
+	require.NoError(t, err)
+	assert.Equal(t, 1, root.Total)
+
+	// Events saved after the index is loaded are only seen through the index
+	require.NoError(t, s.Put(ctx, &event.Event{ID: "ignored", Status: event.StatusPass, Timestamp: sent}))
+	i.Publish(&event.Event{ID: "api", Status: event.StatusFail, Group: "service/api", Timestamp: sent})
+	i.Publish(&event.Event{ID: "web", Status: event.StatusWarning, Group: "service/web", Timestamp: sent.Add(-time.Minute)})
+
+	root, err = i.Build(ctx, event.RollupWorstOf)
+	require.NoError(t, err)
+	assert.Equal(t, 2, root.Total)
+	assert.Equal(t, event.StatusFail, root.Status)
+	assert.Equal(t, event.StatusPass, root.Find("service/web").Status)
+
+	i.Publish(&event.Event{ID: "api", Group: "service/api", Removed: true})
+
+	root, err = i.Build(ctx, event.RollupWorstOf)
+	require.NoError(t, err)
+	assert.Equal(t, 1, root.Total)
+	assert.Nil(t, root.Find("service/api"))
+}
+
+// TestIndexReplace tests that the index is replaced with the events listed by
+// a sweep, but keeps any events published since the sweep started.
+func TestIndexReplace(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	sent := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	i := groups.NewIndex(store.NewMemory())
+	started := time.Now()
+
+	i.Publish(&event.Event{ID: "new", Status: event.StatusRunning, Group: "jobs", Timestamp: sent})
+	i.Replace([]*event.Event{
+		{ID: "old", Status: event.StatusPass, Group: "jobs", Timestamp: sent},
+	}, started)
+
+	root, err := i.Build(ctx, event.RollupWorstOf)
+	require.NoError(t, err)
+	assert.Equal(t, 2, root.Total)
+
+	i.Replace([]*event.Event{}, time.Now())
+
+	root, err = i.Build(ctx, event.RollupWorstOf)
+	require.NoError(t, err)
+	assert.Zero(t, root.Total)
+}
@@ -0,0 +1,218 @@
+// The `groups` package provides the endpoints for the hierarchy of groups the
+// events are organised into, such as `service/production/web`, with the number
+// of events with each status in each group (including the groups below it),
+// and the status of each group rolled up from the statuses of its events.
+package groups
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/serve/middleware"
+
+	slogg "github.com/samber/slog-gin"
+)
+
+var (
+	index *Index
+	// rollup is the default rule used to find the status of each group.
+	rollup string
+)
+
+// Group is a single group in the hierarchy, along with the groups below it.
+type Group struct {
+	// Name is the last part of the path of the group, or empty for the root of
+	// the hierarchy.
+	Name string `json:"name,omitempty"`
+	// Path is the full path of the group, or empty for the root of the
+	// hierarchy.
+	Path string `json:"path,omitempty"`
+	// Status is the status of the group, rolled up from the statuses of the
+	// events in it, and in all the groups below it.
+	Status string `json:"status"`
+	// Total is the number of events in the group, and in all the groups below
+	// it.
+	Total int `json:"total"`
+	// Counts is the number of events with each status in the group, and in all
+	// the groups below it.
+	Counts map[string]int `json:"counts"`
+	// Groups is the list of the groups directly below this group, sorted by
+	// their name.
+	Groups []*Group `json:"groups,omitempty"`
+}
+
+// Attach takes a reference to the Gin router group for the versioned API and
+// attaches the endpoints for the groups of the events in the index `i`, using
+// the `rule` to roll up the status of each group by default.
+func Attach(r *gin.RouterGroup, i *Index, rule string) {
+	index = i
+	rollup = rule
+
+	r.GET("/groups", middleware.Authorize(middleware.ScopeEventsRead), list)
+	r.GET("/groups/*path", middleware.Authorize(middleware.ScopeEventsRead), list)
+}
+
+// list provides the endpoint for clients to fetch the hierarchy of groups, or
+// a single group (and the groups below it) if a path is given, with the rule
+// used to roll up the status of each group optionally set by the `rollup`
+// query parameter.
+func list(c *gin.Context) {
+	path := strings.Trim(c.Param("path"), "/")
+
+	rule := c.DefaultQuery("rollup", rollup)
+	if !event.IsRollup(rule) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    http.StatusBadRequest,
+			"status":  "invalid-rollup",
+			"message": "The rollup must be one of " + strings.Join(event.Rollups, ", "),
+			"path":    c.Request.URL.Path,
+		})
+
+		return
+	}
+
+	// The store only lists the events directly in a group, so all events are
+	// needed to count the events in the groups below it, which are kept in the
+	// index rather than listed from the store on every request
+	root, err := index.Build(c.Request.Context(), rule)
+	if err != nil {
+		internalError(c, "The events could not be listed", err)
+
+		return
+	}
+
+	group := root.Find(path)
+	if group == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    http.StatusNotFound,
+			"status":  "group-not-found",
+			"message": "The group requested could not be found",
+			"path":    c.Request.URL.Path,
+		})
+
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
+// Build creates the hierarchy of groups from the events in `all`, rolling up
+// the status of each group using the `rule`, where events without a group are
+// only counted in the root of the hierarchy.
+func Build(all []*event.Event, rule string) *Group {
+	root := newGroup("", "")
+
+	for _, e := range all {
+		status := event.Canonical(e.Status)
+		current := root
+		current.add(status)
+
+		if e.Group == "" {
+			continue
+		}
+
+		for _, name := range strings.Split(e.Group, "/") {
+			current = current.child(name)
+			current.add(status)
+		}
+	}
+
+	root.rollup(rule)
+
+	return root
+}
+
+// Find returns the group with the `path` from the groups below this group, or
+// this group if the `path` is empty, or nil if it does not exist.
+func (g *Group) Find(path string) *Group {
+	if path == "" {
+		return g
+	}
+
+	current := g
+
+	for _, name := range strings.Split(path, "/") {
+		i := slices.IndexFunc(current.Groups, func(child *Group) bool {
+			return child.Name == name
+		})
+		if i < 0 {
+			return nil
+		}
+
+		current = current.Groups[i]
+	}
+
+	return current
+}
+
+// newGroup creates an empty group with the `name` and `path`, and with the
+// count of every status set to zero.
+func newGroup(name, path string) *Group {
+	counts := make(map[string]int, len(event.Statuses))
+	for _, status := range event.Statuses {
+		counts[status] = 0
+	}
+
+	return &Group{Name: name, Path: path, Counts: counts}
+}
+
+// add counts an event with the `status` in the group.
+func (g *Group) add(status string) {
+	g.Total++
+	g.Counts[status]++
+}
+
+// child returns the group `name` directly below this group, creating it in
+// order by name if it does not already exist.
+func (g *Group) child(name string) *Group {
+	i, found := slices.BinarySearchFunc(g.Groups, name, func(child *Group, name string) int {
+		return strings.Compare(child.Name, name)
+	})
+	if found {
+		return g.Groups[i]
+	}
+
+	path := name
+	if g.Path != "" {
+		path = g.Path + "/" + name
+	}
+
+	child := newGroup(name, path)
+	g.Groups = slices.Insert(g.Groups, i, child)
+
+	return child
+}
+
+// rollup sets the status of this group, and all the groups below it, from the
+// counts of the statuses of their events using the `rule`.
+func (g *Group) rollup(rule string) {
+	g.Status = event.Rollup(rule, g.Counts)
+
+	for _, child := range g.Groups {
+		child.rollup(rule)
+	}
+}
+
+// internalError provides the default response for requests which cannot be
+// processed due to a problem within the service or one of its downstream
+// services, necessitating a 500 (Internal Server Error) response back to the
+// client.
+func internalError(c *gin.Context, message string, err error) {
+	slogg.AddCustomAttributes(c,
+		slog.Group("error",
+			slog.String("message", err.Error()),
+		),
+	)
+
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"code":    http.StatusInternalServerError,
+		"status":  "internal-error",
+		"message": message,
+		"path":    c.Request.URL.Path,
+	})
+}
@@ -0,0 +1,145 @@
+package groups_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/serve/web/groups"
+	"github.com/n3tuk/dashboard/internal/store"
+)
+
+// newRouter creates a new Gin engine with the groups endpoints attached under
+// the versioned API path, over a new memory store holding a set of events.
+func newRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	s := store.NewMemory()
+	for _, e := range []*event.Event{
+		{ID: "web", Status: event.StatusPass, Group: "service/production/web"},
+		{ID: "api", Status: event.StatusFail, Group: "service/production/api"},
+		{ID: "worker", Status: event.StatusPass, Group: "service/production"},
+		{ID: "staging", Status: event.StatusRunning, Group: "service/staging"},
+		{ID: "backup", Status: event.StatusWarning},
+	} {
+		e.Normalise(time.Now())
+		require.NoError(t, s.Put(context.Background(), e))
+	}
+
+	router := gin.New()
+	groups.Attach(router.Group("/api/v1"), groups.NewIndex(s), event.RollupWorstOf)
+
+	return router
+}
+
+// get makes a GET request for the `path`, returning the recorded response and
+// the decoded JSON body.
+func get(t *testing.T, router *gin.Engine, path string) (*httptest.ResponseRecorder, *groups.Group) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	router.ServeHTTP(w, r)
+
+	var response groups.Group
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	return w, &response
+}
+
+// TestList tests that the full hierarchy of groups is returned, with the counts
+// and the status of each group including the groups below it.
+func TestList(t *testing.T) {
+	t.Parallel()
+
+	w, root := get(t, newRouter(t), "/api/v1/groups")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, event.StatusFail, root.Status)
+	assert.Equal(t, 5, root.Total)
+	assert.Equal(t, 1, root.Counts[event.StatusWarning])
+	require.Len(t, root.Groups, 1)
+
+	service := root.Groups[0]
+	assert.Equal(t, "service", service.Path)
+	assert.Equal(t, 4, service.Total)
+	require.Len(t, service.Groups, 2)
+	assert.Equal(t, "production", service.Groups[0].Name)
+	assert.Equal(t, "staging", service.Groups[1].Name)
+	assert.Equal(t, event.StatusRunning, service.Groups[1].Status)
+}
+
+// TestGet tests that a single group is returned with the groups below it, and
+// that the rollup rule can be changed for the request.
+func TestGet(t *testing.T) {
+	t.Parallel()
+
+	router := newRouter(t)
+
+	w, production := get(t, router, "/api/v1/groups/service/production")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "service/production", production.Path)
+	assert.Equal(t, event.StatusFail, production.Status)
+	assert.Equal(t, 3, production.Total)
+	assert.Equal(t, 2, production.Counts[event.StatusPass])
+	assert.Equal(t, 0, production.Counts[event.StatusQueued])
+	require.Len(t, production.Groups, 2)
+	assert.Equal(t, "service/production/api", production.Groups[0].Path)
+
+	_, production = get(t, router, "/api/v1/groups/service/production?rollup=majority")
+	assert.Equal(t, event.StatusPass, production.Status)
+
+	_, web := get(t, router, "/api/v1/groups/service/production/web/")
+	assert.Equal(t, event.StatusPass, web.Status)
+	assert.Empty(t, web.Groups)
+}
+
+// TestGetErrors tests that unknown groups, and unknown rollup rules, are
+// rejected.
+func TestGetErrors(t *testing.T) {
+	t.Parallel()
+
+	router := newRouter(t)
+
+	for path, code := range map[string]int{
+		"/api/v1/groups/service/development": http.StatusNotFound,
+		"/api/v1/groups?rollup=best-of":      http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, code, w.Code, path)
+
+		var response map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.InDelta(t, code, response["code"], 0, path)
+	}
+}
+
+// TestBuild tests that events without a group are only counted in the root of
+// the hierarchy.
+func TestBuild(t *testing.T) {
+	t.Parallel()
+
+	root := groups.Build([]*event.Event{
+		{ID: "one", Status: event.StatusPass},
+		{ID: "two", Status: event.StatusQueued, Group: "jobs"},
+	}, event.RollupAnyFail)
+
+	assert.Equal(t, 2, root.Total)
+	assert.Equal(t, event.StatusPass, root.Status)
+	assert.Equal(t, 1, root.Find("jobs").Total)
+	assert.Nil(t, root.Find("jobs/nightly"))
+}
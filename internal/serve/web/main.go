@@ -3,6 +3,7 @@ package web
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
@@ -11,8 +12,18 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
 
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/serve/certificate"
+	"github.com/n3tuk/dashboard/internal/serve/hub"
+	"github.com/n3tuk/dashboard/internal/serve/listener"
 	"github.com/n3tuk/dashboard/internal/serve/middleware"
+	"github.com/n3tuk/dashboard/internal/serve/web/dashboard"
+	"github.com/n3tuk/dashboard/internal/serve/web/events"
+	"github.com/n3tuk/dashboard/internal/serve/web/groups"
 	"github.com/n3tuk/dashboard/internal/serve/web/ping"
+	"github.com/n3tuk/dashboard/internal/serve/web/socket"
+	"github.com/n3tuk/dashboard/internal/serve/web/stream"
+	"github.com/n3tuk/dashboard/internal/store"
 )
 
 type Service struct {
@@ -20,44 +31,58 @@ type Service struct {
 	router *gin.Engine
 	server *http.Server
 	health func(bool)
+	certs  *certificate.Reloader
+	listen net.Listener
 }
 
-var ErrServiceNotConfigured = errors.New("service not configured")
+var (
+	ErrServiceNotConfigured = errors.New("service not configured")
+	// ErrInvalidRollup is returned when the rule used to roll up the status of
+	// each group is unknown.
+	ErrInvalidRollup = errors.New("unknown group rollup rule")
+)
 
-func NewService() *Service {
+func NewService(s store.EventStore, h *hub.Hub, p event.Publisher, i *groups.Index) (*Service, error) {
 	router := gin.New()
 
 	name := viper.GetString("cluster.name")
 	address := viper.GetString("endpoints.bind.address")
 	port := viper.GetString("endpoints.bind.port.web")
 
+	rollup := viper.GetString("groups.rollup")
+	if !event.IsRollup(rollup) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRollup, rollup)
+	}
+
+	certs, err := certificate.New(name, "web")
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := listener.Listen("web", address, port)
+	if err != nil {
+		_ = certs.Close()
+
+		return nil, err
+	}
+
+	// Find the address of the client first, so it can be used by all the
+	// other middleware, such as for logging
+	middleware.TrustProxies(router)
 	router.Use(middleware.Logger())
 	router.Use(middleware.Prometheus(name, "web"))
 	router.Use(gin.Recovery())
 
-	proxies := viper.GetStringSlice("endpoints.proxies")
-	if len(proxies) > 0 {
-		err := router.SetTrustedProxies(proxies)
-		if err != nil {
-			slog.Error(
-				"Unable to configure trusted proxies",
-				slog.Group(
-					"error",
-					slog.String("message", err.Error()),
-				),
-			)
-		}
-	}
-
 	service := &Service{
 		router: router,
+		certs:  certs,
+		listen: l,
 		server: &http.Server{
 			ReadTimeout:       time.Duration(viper.GetInt("endpoints.timeouts.read")) * time.Second,
 			WriteTimeout:      time.Duration(viper.GetInt("endpoints.timeouts.write")) * time.Second,
 			IdleTimeout:       time.Duration(viper.GetInt("endpoints.timeouts.idle")) * time.Second,
-			ReadHeaderTimeout: time.Duration(viper.GetInt("endpoints.timeouts.header")) * time.Second,
+			ReadHeaderTimeout: time.Duration(viper.GetInt("endpoints.timeouts.headers")) * time.Second,
 
-			Addr:    net.JoinHostPort(address, port),
 			Handler: router,
 		},
 
@@ -67,13 +92,34 @@ func NewService() *Service {
 			slog.String("service", "web"),
 			slog.String("address", address),
 			slog.String("port", port),
+			slog.Bool("tls", certs != nil),
 		),
 	}
 
 	ping.Attach(router)
+	dashboard.Attach(router, s, rollup)
+
+	v1 := router.Group("/api/v1")
+	events.Attach(
+		v1, s, p,
+		middleware.RateLimit(name, "web", middleware.EventsGroup),
+		time.Duration(viper.GetInt("endpoints.idempotency.window"))*time.Second,
+		events.Batch{
+			Events: viper.GetInt("endpoints.batch.max-events"),
+			Bytes:  viper.GetInt64("endpoints.batch.max-bytes"),
+		},
+	)
+	groups.Attach(v1, i, rollup)
+	heartbeat := time.Duration(viper.GetInt("stream.heartbeat")) * time.Second
+	stream.Attach(v1, h, heartbeat)
+	// The streams only end when the client disconnects, so end them as the
+	// server shuts down, rather than waiting for the shutdown to time out
+	service.server.RegisterOnShutdown(stream.Close)
+	socket.Attach(v1, h, heartbeat)
+
 	router.NoRoute(notFound)
 
-	return service
+	return service, nil
 }
 
 func (s *Service) Start(e chan error, health func(bool)) {
@@ -93,7 +139,7 @@ func (s *Service) Start(e chan error, health func(bool)) {
 
 	health(true)
 
-	err := s.server.ListenAndServe()
+	err := certificate.Serve(s.server, s.listen, s.certs)
 	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		health(false)
 		slog.Error(
@@ -119,6 +165,10 @@ func (s *Service) Shutdown(timeout time.Duration) error {
 		return err
 	}
 
+	if err := s.certs.Close(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -0,0 +1,265 @@
+package socket
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/serve/hub"
+	"github.com/n3tuk/dashboard/internal/serve/middleware"
+)
+
+const (
+	// writeWait is the maximum time allowed to write a message to the client.
+	writeWait = 10 * time.Second
+	// maxMessageSize is the maximum size of a control message from the client.
+	maxMessageSize = 4096
+	// replyQueue is the number of replies to control messages which can be
+	// waiting to be sent to the client.
+	replyQueue = 16
+)
+
+var (
+	events    *hub.Hub
+	heartbeat time.Duration
+
+	upgrader = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 4096,
+	}
+)
+
+// Control is a message sent by the client to change which groups it receives
+// events for (`subscribe` or `unsubscribe`), or to check that the connection
+// is still open (`ping`).
+type Control struct {
+	Type   string   `json:"type"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// Reply is a message sent to the client, either in response to a control
+// message, or to deliver an event.
+type Reply struct {
+	Type    string       `json:"type"`
+	ID      uint64       `json:"id,omitempty"`
+	Event   *event.Event `json:"event,omitempty"`
+	All     bool         `json:"all,omitempty"`
+	Groups  []string     `json:"groups,omitempty"`
+	Message string       `json:"message,omitempty"`
+}
+
+// client holds the state of a single connected WebSocket client, including the
+// groups it is currently subscribed to.
+type client struct {
+	conn    *websocket.Conn
+	replies chan *Reply
+	done    chan struct{}
+
+	mutex  sync.RWMutex
+	all    bool
+	groups []string
+}
+
+// Attach takes a reference to the Gin router group for the versioned API and
+// attaches all the expected endpoints which can be used by clients through
+// this package, delivering the events published to the hub `h`, and sending a
+// ping every `interval` to keep the connection open.
+func Attach(r *gin.RouterGroup, h *hub.Hub, interval time.Duration) {
+	events = h
+	heartbeat = interval
+
+	r.GET("/ws", middleware.Authorize(middleware.ScopeEventsRead), socket)
+}
+
+// socket provides the WebSocket endpoint which delivers each new or updated
+// event to the client as it is accepted, initially filtered by the `group` and
+// `label` (as `key=value`) query parameters, and then by the groups the client
+// subscribes to, or unsubscribes from, through control messages.
+func socket(c *gin.Context) {
+	filter, err := hub.ParseFilter(c.QueryArray("group"), c.QueryArray("label"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    http.StatusBadRequest,
+			"status":  "invalid-request",
+			"message": err.Error(),
+			"path":    c.Request.URL.Path,
+		})
+
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already responded to the client with the error
+		return
+	}
+	defer conn.Close()
+
+	// Groups are filtered by the client itself so that they can be changed
+	// through control messages, so only the labels are filtered by the hub
+	subscription := events.Subscribe("websocket", hub.Filter{Labels: filter.Labels}, 0)
+	defer events.Unsubscribe(subscription)
+
+	cl := &client{
+		conn:    conn,
+		replies: make(chan *Reply, replyQueue),
+		done:    make(chan struct{}),
+		all:     len(filter.Groups) == 0,
+		groups:  filter.Groups,
+	}
+
+	go cl.read()
+
+	cl.write(subscription)
+}
+
+// read processes the control messages from the client until the connection is
+// closed, extending the read deadline each time a pong is received.
+func (cl *client) read() {
+	defer close(cl.done)
+
+	cl.conn.SetReadLimit(maxMessageSize)
+	_ = cl.conn.SetReadDeadline(time.Now().Add(2 * heartbeat))
+	cl.conn.SetPongHandler(func(string) error {
+		return cl.conn.SetReadDeadline(time.Now().Add(2 * heartbeat))
+	})
+
+	for {
+		control := &Control{}
+		if err := cl.conn.ReadJSON(control); err != nil {
+			return
+		}
+
+		_ = cl.conn.SetReadDeadline(time.Now().Add(2 * heartbeat))
+
+		reply := cl.process(control)
+
+		select {
+		case cl.replies <- reply:
+		default:
+			// The client is sending control messages faster than the replies
+			// can be sent, so stop processing them
+			return
+		}
+	}
+}
+
+// process applies the control message to the client, returning the reply to
+// be sent back to the client.
+func (cl *client) process(control *Control) *Reply {
+	cl.mutex.Lock()
+	defer cl.mutex.Unlock()
+
+	switch control.Type {
+	case "ping":
+		return &Reply{Type: "pong"}
+	case "subscribe":
+		if len(control.Groups) == 0 {
+			cl.all = true
+			cl.groups = nil
+		} else {
+			cl.all = false
+
+			for _, group := range control.Groups {
+				if !slices.Contains(cl.groups, group) {
+					cl.groups = append(cl.groups, group)
+				}
+			}
+		}
+	case "unsubscribe":
+		if len(control.Groups) == 0 {
+			cl.all = false
+			cl.groups = nil
+		} else {
+			cl.groups = slices.DeleteFunc(cl.groups, func(group string) bool {
+				return slices.Contains(control.Groups, group)
+			})
+		}
+	default:
+		return &Reply{Type: "error", Message: "unknown control message type: " + control.Type}
+	}
+
+	return &Reply{Type: "subscribed", All: cl.all, Groups: slices.Clone(cl.groups)}
+}
+
+// matches checks whether the event `e` is in one of the groups the client is
+// currently subscribed to.
+func (cl *client) matches(e *event.Event) bool {
+	cl.mutex.RLock()
+	defer cl.mutex.RUnlock()
+
+	if cl.all {
+		return true
+	}
+
+	if len(cl.groups) == 0 {
+		return false
+	}
+
+	return hub.Filter{Groups: cl.groups}.Matches(e)
+}
+
+// write sends the events from the `subscription`, the replies to the control
+// messages, and the pings, to the client until the connection is closed, or
+// the client is dropped by the hub for being too slow.
+func (cl *client) write(subscription *hub.Subscription) {
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		var err error
+
+		select {
+		case <-cl.done:
+			return
+		case <-ticker.C:
+			_ = cl.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			err = cl.conn.WriteMessage(websocket.PingMessage, nil)
+		case reply := <-cl.replies:
+			err = cl.send(reply)
+		case message, ok := <-subscription.C:
+			if !ok {
+				cl.close(subscription)
+
+				return
+			}
+
+			if cl.matches(message.Event) {
+				err = cl.send(&Reply{Type: "event", ID: message.ID, Event: message.Event})
+			}
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// send writes the `reply` to the client as a JSON message.
+func (cl *client) send(reply *Reply) error {
+	_ = cl.conn.SetWriteDeadline(time.Now().Add(writeWait))
+
+	return cl.conn.WriteJSON(reply)
+}
+
+// close tells the client why the connection is being closed by the server.
+func (cl *client) close(subscription *hub.Subscription) {
+	code := websocket.CloseNormalClosure
+	reason := "closing"
+
+	if subscription.Dropped {
+		code = websocket.CloseTryAgainLater
+		reason = "client too slow to receive events"
+
+		slog.Warn("Dropped slow WebSocket client", slog.String("remote", cl.conn.RemoteAddr().String()))
+	}
+
+	message := websocket.FormatCloseMessage(code, reason)
+	_ = cl.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(writeWait))
+}
@@ -0,0 +1,122 @@
+//nolint:paralleltest // these tests cannot operate in parallel
+package socket_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/serve/hub"
+	"github.com/n3tuk/dashboard/internal/serve/web/socket"
+)
+
+// connect starts a test server with the WebSocket endpoint attached for the
+// hub `h`, and connects to it with the `query` provided.
+func connect(t *testing.T, h *hub.Hub, query string) *websocket.Conn {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	socket.Attach(router.Group("/api/v1"), h, time.Second)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1/ws" + query
+
+	conn, response, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+
+	defer response.Body.Close()
+
+	t.Cleanup(func() { conn.Close() })
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	return conn
+}
+
+// exchange sends the `control` message to the server and returns the reply.
+func exchange(t *testing.T, conn *websocket.Conn, control *socket.Control) *socket.Reply {
+	t.Helper()
+
+	require.NoError(t, conn.WriteJSON(control))
+
+	reply := &socket.Reply{}
+	require.NoError(t, conn.ReadJSON(reply))
+
+	return reply
+}
+
+// TestPing tests that the server replies to a ping control message.
+func TestPing(t *testing.T) {
+	conn := connect(t, hub.NewHub("test"), "")
+
+	reply := exchange(t, conn, &socket.Control{Type: "ping"})
+	assert.Equal(t, "pong", reply.Type)
+
+	reply = exchange(t, conn, &socket.Control{Type: "unknown"})
+	assert.Equal(t, "error", reply.Type)
+}
+
+// TestSubscribe tests that events published to the hub are sent to the client
+// only for the groups it has subscribed to.
+func TestSubscribe(t *testing.T) {
+	h := hub.NewHub("test")
+	conn := connect(t, h, "?group=other")
+
+	reply := exchange(t, conn, &socket.Control{Type: "unsubscribe", Groups: []string{"other"}})
+	assert.Equal(t, "subscribed", reply.Type)
+	assert.False(t, reply.All)
+	assert.Empty(t, reply.Groups)
+
+	reply = exchange(t, conn, &socket.Control{Type: "subscribe", Groups: []string{"service"}})
+	assert.Equal(t, "subscribed", reply.Type)
+	assert.Equal(t, []string{"service"}, reply.Groups)
+
+	h.Publish(&event.Event{ID: "skipped", Status: "pass", Group: "other"})
+	h.Publish(&event.Event{ID: "delivered", Status: "pass", Group: "service/production"})
+
+	reply = &socket.Reply{}
+	require.NoError(t, conn.ReadJSON(reply))
+
+	assert.Equal(t, "event", reply.Type)
+	assert.Equal(t, uint64(2), reply.ID)
+	require.NotNil(t, reply.Event)
+	assert.Equal(t, "delivered", reply.Event.ID)
+
+	reply = exchange(t, conn, &socket.Control{Type: "subscribe"})
+	assert.True(t, reply.All)
+
+	h.Publish(&event.Event{ID: "everything", Status: "pass", Group: "other"})
+
+	reply = &socket.Reply{}
+	require.NoError(t, conn.ReadJSON(reply))
+
+	require.NotNil(t, reply.Event)
+	assert.Equal(t, "everything", reply.Event.ID)
+}
+
+// TestSocketInvalid tests that invalid query parameters are rejected before
+// the connection is upgraded.
+func TestSocketInvalid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	socket.Attach(router.Group("/api/v1"), hub.NewHub("test"), time.Second)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/ws?label=invalid", nil)
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
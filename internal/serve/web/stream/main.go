@@ -0,0 +1,161 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/n3tuk/dashboard/internal/serve/hub"
+	"github.com/n3tuk/dashboard/internal/serve/middleware"
+)
+
+var (
+	events    *hub.Hub
+	heartbeat time.Duration
+	// closing is closed when the service is shutting down, so that all the
+	// open streams are ended.
+	closing chan struct{}
+	// closed ensures that `closing` is only closed once.
+	closed *sync.Once
+)
+
+// Attach takes a reference to the Gin router group for the versioned API and
+// attaches all the expected endpoints which can be used by clients through
+// this package, streaming the events published to the hub `h`, and sending a
+// heart-beat every `interval` to keep the connection open.
+func Attach(r *gin.RouterGroup, h *hub.Hub, interval time.Duration) {
+	events = h
+	heartbeat = interval
+	closing = make(chan struct{})
+	closed = &sync.Once{}
+
+	r.GET("/events/stream", middleware.Authorize(middleware.ScopeEventsRead), middleware.Unwrap, stream)
+}
+
+// Close ends all the open streams, and any opened after, as the streams stay
+// open until the client disconnects, which would otherwise stop the service
+// from shutting down gracefully.
+func Close() {
+	closed.Do(func() { close(closing) })
+}
+
+// stream provides the Server-Sent Events endpoint which pushes each new or
+// updated event to the client as it is accepted, filtered by the `group` and
+// `label` (as `key=value`) query parameters, and resuming after the event in
+// the `Last-Event-ID` header, if provided by the client on reconnection.
+func stream(c *gin.Context) {
+	filter, err := hub.ParseFilter(c.QueryArray("group"), c.QueryArray("label"))
+	if err != nil {
+		badRequest(c, err)
+
+		return
+	}
+
+	last, err := lastEventID(c)
+	if err != nil {
+		badRequest(c, err)
+
+		return
+	}
+
+	// The stream is expected to stay open for much longer than the timeout for
+	// writing a normal response, so remove the deadline for this response only
+	controller := http.NewResponseController(c.Writer)
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil {
+		slog.Warn(
+			"Unable to remove the write deadline for the event stream",
+			slog.Group("error", slog.String("message", err.Error())),
+		)
+	}
+
+	subscription := events.Subscribe("sse", filter, last)
+	defer events.Unsubscribe(subscription)
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-closing:
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case message, ok := <-subscription.C:
+			if !ok {
+				// The client was too slow to receive the events, so close the
+				// stream and allow the client to reconnect and resume from the
+				// last event it received
+				return
+			}
+
+			if err := write(c, message); err != nil {
+				return
+			}
+		}
+
+		c.Writer.Flush()
+	}
+}
+
+// write sends the `message` to the client as a single Server-Sent Event.
+func write(c *gin.Context, message *hub.Message) error {
+	data, err := json.Marshal(message.Event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(c.Writer, "id: %d\nevent: event\ndata: %s\n\n", message.ID, data)
+
+	return err
+}
+
+// lastEventID returns the ID of the last event received by the client, from
+// either the `Last-Event-ID` header or query parameter, or zero if not set.
+func lastEventID(c *gin.Context) (uint64, error) {
+	value := c.GetHeader("Last-Event-ID")
+	if value == "" {
+		value = c.Query("last-event-id")
+	}
+
+	if value == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("last event ID %q must be a positive number", value)
+	}
+
+	return id, nil
+}
+
+// badRequest provides the default response for requests which cannot be
+// processed due to a problem with the request from the client, necessitating a
+// 400 (Bad Request) response back to the client.
+func badRequest(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"code":    http.StatusBadRequest,
+		"status":  "invalid-request",
+		"message": err.Error(),
+		"path":    c.Request.URL.Path,
+	})
+}
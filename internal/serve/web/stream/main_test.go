@@ -0,0 +1,158 @@
+//nolint:paralleltest // these tests cannot operate in parallel
+package stream_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/serve/hub"
+	"github.com/n3tuk/dashboard/internal/serve/middleware"
+	"github.com/n3tuk/dashboard/internal/serve/web/stream"
+)
+
+// TestStream tests that events published to the hub are streamed to a client
+// as Server-Sent Events, filtered by the group requested.
+func TestStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	h := hub.NewHub("test")
+	router := gin.New()
+	stream.Attach(router.Group("/api/v1"), h, time.Second)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/events/stream?group=service", nil)
+	require.NoError(t, err)
+
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+
+	defer response.Body.Close()
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	h.Publish(&event.Event{ID: "skipped", Status: "pass", Group: "other"})
+	h.Publish(&event.Event{ID: "streamed", Status: "pass", Group: "service/production"})
+
+	reader := bufio.NewReader(response.Body)
+	lines := []string{}
+
+	for len(lines) < 3 {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	assert.Equal(t, "id: 2", lines[0])
+	assert.Equal(t, "event: event", lines[1])
+	assert.Contains(t, lines[2], `"event-id":"streamed"`)
+}
+
+// TestStreamInvalid tests that invalid query parameters are rejected.
+func TestStreamInvalid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	stream.Attach(router.Group("/api/v1"), hub.NewHub("test"), time.Second)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/events/stream?label=invalid", nil)
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// newServer creates a test server with the stream endpoint attached behind the
+// logger, as in the web service, which ends each response after the write
+// `timeout`, sending a heart-beat every 50ms.
+func newServer(t *testing.T, timeout time.Duration) *httptest.Server {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(middleware.Logger())
+	stream.Attach(router.Group("/api/v1"), hub.NewHub("test"), 50*time.Millisecond)
+
+	server := httptest.NewUnstartedServer(router)
+	server.Config.WriteTimeout = timeout
+	server.Config.RegisterOnShutdown(stream.Close)
+	server.Start()
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// open opens the stream from the `server`, returning a reader for the body of
+// the response.
+func open(t *testing.T, server *httptest.Server) *bufio.Reader {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/events/stream", nil)
+	require.NoError(t, err)
+
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = response.Body.Close() })
+
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	return bufio.NewReader(response.Body)
+}
+
+// TestStreamWriteTimeout tests that the stream stays open for longer than the
+// write timeout of the server, even when behind the logger.
+func TestStreamWriteTimeout(t *testing.T) {
+	reader := open(t, newServer(t, 200*time.Millisecond))
+
+	start := time.Now()
+	for time.Since(start) < 600*time.Millisecond {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err, "stream ended after %s", time.Since(start))
+
+		if strings.TrimSpace(line) != "" {
+			assert.Equal(t, ": heartbeat", strings.TrimSpace(line))
+		}
+	}
+}
+
+// TestStreamShutdown tests that open streams are ended when the server shuts
+// down, so that it can shut down gracefully.
+func TestStreamShutdown(t *testing.T) {
+	server := newServer(t, time.Minute)
+	reader := open(t, server)
+
+	_, err := reader.ReadString('\n')
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	require.NoError(t, server.Config.Shutdown(ctx))
+}
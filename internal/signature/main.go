@@ -0,0 +1,104 @@
+// The `signature` package provides the signing of requests to the dashboard
+// endpoint, and the verification of those signatures, so that a client can
+// prove it holds an API key without sending the key itself, and without the
+// request being able to be replayed.
+//
+// The secret used to sign each request is the API key itself, which the
+// dashboard service must hold as the `secret` of a signed API key, as it
+// cannot be derived from the hash stored for the keys sent with each request,
+// and the key is identified by the fingerprint of that secret. The signature is
+// the HMAC (SHA-256) of the timestamp, nonce, method, path, and body of the
+// request.
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// KeyHeader is the header which identifies the API key used to sign the
+	// request, by the fingerprint of its secret.
+	KeyHeader = "X-Dashboard-Key"
+	// TimestampHeader is the header which holds the time the request was signed,
+	// as the number of seconds since the Unix epoch.
+	TimestampHeader = "X-Dashboard-Timestamp"
+	// NonceHeader is the header which holds the random value unique to each
+	// request, used to prevent the request being replayed.
+	NonceHeader = "X-Dashboard-Nonce"
+	// SignatureHeader is the header which holds the signature of the request.
+	SignatureHeader = "X-Dashboard-Signature"
+
+	// prefix is added to the signature to identify the algorithm used.
+	prefix = "sha256="
+	// nonceSize is the number of random bytes used to create each nonce.
+	nonceSize = 16
+	// fingerprintContext is mixed into the fingerprint of each secret so that
+	// it never matches the plain SHA-256 hash of the same API key.
+	fingerprintContext = "dashboard-fingerprint"
+)
+
+// Fingerprint returns the identifier for the API key with the `secret`, which
+// can be shared without revealing either the key or the secret.
+func Fingerprint(secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(fingerprintContext))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign returns the signature of a request using the `secret`, for the
+// `timestamp` and `nonce` provided in the headers, and the `method`, `path`,
+// and `body` of the request.
+func Sign(secret []byte, timestamp, nonce, method, path string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strings.Join([]string{timestamp, nonce, method, path}, "\n")))
+	mac.Write([]byte("\n"))
+	mac.Write(body)
+
+	return prefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks, in constant time, that the `signature` matches the one
+// created by `Sign` for the same `secret` and request details.
+func Verify(secret []byte, signature, timestamp, nonce, method, path string, body []byte) bool {
+	expected := Sign(secret, timestamp, nonce, method, path, body)
+
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// NewNonce returns a new random nonce for signing a request.
+func NewNonce() (string, error) {
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(nonce), nil
+}
+
+// Apply signs the request `r`, with the `body` it will send, using the API
+// `key` as the secret at the time `now`, setting the headers needed to verify
+// it.
+func Apply(r *http.Request, key string, body []byte, now time.Time) error {
+	nonce, err := NewNonce()
+	if err != nil {
+		return err
+	}
+
+	secret := []byte(key)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	r.Header.Set(KeyHeader, Fingerprint(secret))
+	r.Header.Set(TimestampHeader, timestamp)
+	r.Header.Set(NonceHeader, nonce)
+	r.Header.Set(SignatureHeader, Sign(secret, timestamp, nonce, r.Method, r.URL.Path, body))
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package signature_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/signature"
+)
+
+// TestVerify tests that a signature is only verified for the same secret and
+// request details it was created with.
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("key")
+	body := []byte(`{"event-id":"test"}`)
+	signed := signature.Sign(secret, "1700000000", "nonce", http.MethodPost, "/api/v1/events", body)
+
+	assert.True(t, strings.HasPrefix(signed, "sha256="))
+	assert.True(t, signature.Verify(secret, signed, "1700000000", "nonce", http.MethodPost, "/api/v1/events", body))
+
+	assert.False(t, signature.Verify([]byte("other"), signed,
+		"1700000000", "nonce", http.MethodPost, "/api/v1/events", body))
+	assert.False(t, signature.Verify(secret, signed, "1700000001", "nonce", http.MethodPost, "/api/v1/events", body))
+	assert.False(t, signature.Verify(secret, signed, "1700000000", "other", http.MethodPost, "/api/v1/events", body))
+	assert.False(t, signature.Verify(secret, signed, "1700000000", "nonce", http.MethodPost, "/api/v1/events",
+		[]byte(`{"event-id":"other"}`)))
+}
+
+// TestApply tests that the headers needed to verify a request are set.
+func TestApply(t *testing.T) {
+	t.Parallel()
+
+	body := []byte(`{"event-id":"test"}`)
+	now := time.Unix(1700000000, 0)
+
+	request, err := http.NewRequest(http.MethodPost, "http://localhost/api/v1/events", nil)
+	require.NoError(t, err)
+	require.NoError(t, signature.Apply(request, "key", body, now))
+
+	secret := []byte("key")
+
+	assert.NotEqual(t, "2c70e12b7a0646f92279f427c7b38e7334d8e5389cff167a1dc30e73f826b683",
+		request.Header.Get(signature.KeyHeader), "the fingerprint must not be the hash of the key")
+	assert.Equal(t, signature.Fingerprint(secret), request.Header.Get(signature.KeyHeader))
+	assert.Equal(t, "1700000000", request.Header.Get(signature.TimestampHeader))
+	assert.Len(t, request.Header.Get(signature.NonceHeader), 32)
+	assert.True(t, signature.Verify(secret, request.Header.Get(signature.SignatureHeader),
+		"1700000000", request.Header.Get(signature.NonceHeader), http.MethodPost, "/api/v1/events", body))
+}
@@ -0,0 +1,654 @@
+// The `dynamo` package provides an implementation of `store.EventStore` backed
+// by Amazon DynamoDB (or DynamoDB Local for development), allowing multiple
+// dashboard instances to share the events and their history.
+//
+// Both the current state of each event, and each update recorded in its
+// history, are stored in a single table, keyed on the event ID (as `id`) and a
+// sort key (as `sort`), where the current state is always stored under the
+// `current` sort key, and each history update under a sort key prefixed with
+// `history#` and ordered by the timestamp of the update.
+package dynamo
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/spf13/viper"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/store"
+)
+
+const (
+	// Driver is the name of the driver for the DynamoDB event store.
+	Driver = "dynamodb"
+
+	// currentKey is the sort key used for the current state of an event.
+	currentKey = "current"
+	// historyPrefix is the prefix of the sort key used for each update in the
+	// history of an event.
+	historyPrefix = "history#"
+	// digestLength is the number of characters of the digest of an update used
+	// in the sort key for the history of an event.
+	digestLength = 16
+	// eventKind is the value of the `kind` attribute set on the current state of
+	// each event, allowing all events to be listed through `eventsIndex`.
+	eventKind = "event"
+
+	// eventsIndex is the name of the global secondary index used to list the
+	// current state of all events, ordered by ID.
+	eventsIndex = "events-index"
+	// groupIndex is the name of the global secondary index used to list the
+	// current state of the events in a group, ordered by ID.
+	groupIndex = "group-index"
+
+	// batchSize is the maximum number of items which DynamoDB will accept in a
+	// single BatchWriteItem request.
+	batchSize = 25
+	// minBackoff is the initial time to wait before resubmitting any requests
+	// in a batch which DynamoDB did not process.
+	minBackoff = 50 * time.Millisecond
+	// maxBackoff is the maximum time to wait before resubmitting any requests
+	// in a batch which DynamoDB did not process.
+	maxBackoff = 5 * time.Second
+	// createTimeout is the maximum time to wait for a new table to be created.
+	createTimeout = 2 * time.Minute
+)
+
+// ErrTableNotActive is returned when the table exists but cannot yet be used.
+var ErrTableNotActive = errors.New("dynamodb table is not active")
+
+// Store provides an implementation of `store.EventStore` backed by DynamoDB.
+type Store struct {
+	client *dynamodb.Client
+	table  string
+	ttl    time.Duration
+}
+
+// item represents the attributes of each item stored in the table, for both
+// the current state and the history of an event.
+type item struct {
+	ID        string            `dynamodbav:"id"`
+	Sort      string            `dynamodbav:"sort"`
+	Kind      string            `dynamodbav:"kind,omitempty"`
+	Index     string            `dynamodbav:"group_index,omitempty"`
+	Status    string            `dynamodbav:"status"`
+	Message   string            `dynamodbav:"message,omitempty"`
+	Source    string            `dynamodbav:"source,omitempty"`
+	Group     string            `dynamodbav:"group,omitempty"`
+	Labels    map[string]string `dynamodbav:"labels,omitempty"`
+	Timestamp int64             `dynamodbav:"timestamp"`
+	Received  int64             `dynamodbav:"received"`
+	Reopen    bool              `dynamodbav:"reopen,omitempty"`
+	Started   int64             `dynamodbav:"started,omitempty"`
+	Duration  float64           `dynamodbav:"duration,omitempty"`
+	Heartbeat int               `dynamodbav:"heartbeat,omitempty"`
+	TTL       int               `dynamodbav:"ttl,omitempty"`
+	Expires   int64             `dynamodbav:"expires,omitempty"`
+}
+
+// init will register the DynamoDB driver so it can be selected through the
+// `store.driver` configuration setting.
+func init() {
+	store.Register(Driver, func(ctx context.Context) (store.EventStore, error) {
+		return New(ctx)
+	})
+}
+
+// New creates a new DynamoDB-backed event store from the `store.dynamodb`
+// configuration settings, creating the table (and enabling expiry on it) if
+// `store.dynamodb.create` is set and the table does not already exist.
+func New(ctx context.Context) (*Store, error) {
+	options := []func(*config.LoadOptions) error{}
+	if region := viper.GetString("store.dynamodb.region"); region != "" {
+		options = append(options, config.WithRegion(region))
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load the AWS configuration: %w", err)
+	}
+
+	client := dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		if endpoint := viper.GetString("store.dynamodb.endpoint"); endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+
+	s := &Store{
+		client: client,
+		table:  viper.GetString("store.dynamodb.table"),
+		ttl:    time.Duration(viper.GetInt("store.dynamodb.ttl")) * time.Second,
+	}
+
+	if viper.GetBool("store.dynamodb.create") {
+		if err := s.create(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// create checks that the table exists, and if not, creates it, along with the
+// indexes used to list the events, waits for it to become active, and then
+// enables expiry on the `expires` attribute if a TTL has been configured.
+func (s *Store) create(ctx context.Context) error {
+	_, err := s.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(s.table),
+	})
+	if err == nil {
+		return nil
+	}
+
+	var missing *types.ResourceNotFoundException
+	if !errors.As(err, &missing) {
+		return fmt.Errorf("unable to check the dynamodb table %s: %w", s.table, err)
+	}
+
+	slog.Info("Creating the DynamoDB table for the event store", slog.String("table", s.table))
+
+	_, err = s.client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName:   aws.String(s.table),
+		BillingMode: types.BillingModePayPerRequest,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("id"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("sort"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("kind"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("group_index"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("id"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("sort"), KeyType: types.KeyTypeRange},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+			index(eventsIndex, "kind"),
+			index(groupIndex, "group_index"),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to create the dynamodb table %s: %w", s.table, err)
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(s.client)
+
+	err = waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(s.table)}, createTimeout)
+	if err != nil {
+		return fmt.Errorf("unable to wait for the dynamodb table %s: %w", s.table, err)
+	}
+
+	if s.ttl <= 0 {
+		return nil
+	}
+
+	_, err = s.client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(s.table),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String("expires"),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to enable expiry on the dynamodb table %s: %w", s.table, err)
+	}
+
+	return nil
+}
+
+// index returns the definition of a global secondary index called `name`,
+// partitioned on the `partition` attribute and sorted by the event ID.
+func index(name, partition string) types.GlobalSecondaryIndex {
+	return types.GlobalSecondaryIndex{
+		IndexName: aws.String(name),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String(partition), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("id"), KeyType: types.KeyTypeRange},
+		},
+		Projection: &types.Projection{
+			ProjectionType: types.ProjectionTypeAll,
+		},
+	}
+}
+
+// Ready checks that the table can be reached and is active.
+func (s *Store) Ready(ctx context.Context) error {
+	output, err := s.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(s.table),
+	})
+	if err != nil {
+		return err
+	}
+
+	if output.Table.TableStatus != types.TableStatusActive {
+		return fmt.Errorf("%w: %s", ErrTableNotActive, output.Table.TableStatus)
+	}
+
+	return nil
+}
+
+// Put saves the event `e`, recording it in the history for the event, and
+// replacing the current state of the event with a conditional write, so that
+// it is only replaced if it is not older than the one currently stored.
+func (s *Store) Put(ctx context.Context, e *event.Event) error {
+	history, err := attributevalue.MarshalMap(s.toItem(e, historyKey(e)))
+	if err != nil {
+		return fmt.Errorf("unable to encode the event: %w", err)
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item:      history,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to save the event history: %w", err)
+	}
+
+	current, err := attributevalue.MarshalMap(s.toItem(e, currentKey))
+	if err != nil {
+		return fmt.Errorf("unable to encode the event: %w", err)
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(s.table),
+		Item:                current,
+		ConditionExpression: aws.String("attribute_not_exists(#id) OR #timestamp <= :timestamp"),
+		ExpressionAttributeNames: map[string]string{
+			"#id":        "id",
+			"#timestamp": "timestamp",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":timestamp": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", e.Timestamp.UnixNano())},
+		},
+	})
+
+	var stale *types.ConditionalCheckFailedException
+	if errors.As(err, &stale) {
+		// A newer update has already been saved, so this update is only recorded
+		// in the history for the event, rather than as the current state
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to save the event: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns the current state of the event with the given `id`.
+func (s *Store) Get(ctx context.Context, id string) (*event.Event, error) {
+	output, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]types.AttributeValue{
+			"id":   &types.AttributeValueMemberS{Value: id},
+			"sort": &types.AttributeValueMemberS{Value: currentKey},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the event: %w", err)
+	}
+
+	if len(output.Item) == 0 {
+		return nil, store.ErrNotFound
+	}
+
+	return fromItem(output.Item)
+}
+
+// List returns a page of the current state of the events in the `group`, or
+// all events if `group` is empty, ordered by their ID.
+func (s *Store) List(ctx context.Context, group string, page store.Page) (*store.Result, error) {
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(s.table),
+		IndexName:                 aws.String(eventsIndex),
+		KeyConditionExpression:    aws.String("#partition = :partition"),
+		ExpressionAttributeNames:  map[string]string{"#partition": "kind"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":partition": &types.AttributeValueMemberS{Value: eventKind}},
+		Limit:                     aws.Int32(int32(page.Size())), //nolint:gosec // limited by Page.Size()
+	}
+
+	if group != "" {
+		input.IndexName = aws.String(groupIndex)
+		input.ExpressionAttributeNames["#partition"] = "group_index"
+		input.ExpressionAttributeValues[":partition"] = &types.AttributeValueMemberS{Value: group}
+	}
+
+	if page.Cursor != "" {
+		key, err := decodeCursor(page.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		input.ExclusiveStartKey = key
+	}
+
+	output, err := s.client.Query(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the events: %w", err)
+	}
+
+	result := &store.Result{
+		Events: make([]*event.Event, 0, len(output.Items)),
+	}
+
+	for _, i := range output.Items {
+		e, err := fromItem(i)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Events = append(result.Events, e)
+	}
+
+	if len(output.LastEvaluatedKey) > 0 {
+		result.Next, err = encodeCursor(output.LastEvaluatedKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// History returns all the updates recorded for the event with the given `id`,
+// ordered from the oldest to the newest.
+func (s *Store) History(ctx context.Context, id string) ([]*event.Event, error) {
+	paginator := dynamodb.NewQueryPaginator(s.client, &dynamodb.QueryInput{
+		TableName:              aws.String(s.table),
+		ConsistentRead:         aws.Bool(true),
+		KeyConditionExpression: aws.String("#id = :id AND begins_with(#sort, :prefix)"),
+		ExpressionAttributeNames: map[string]string{
+			"#id":   "id",
+			"#sort": "sort",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id":     &types.AttributeValueMemberS{Value: id},
+			":prefix": &types.AttributeValueMemberS{Value: historyPrefix},
+		},
+	})
+
+	history := []*event.Event{}
+
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get the event history: %w", err)
+		}
+
+		for _, i := range output.Items {
+			e, err := fromItem(i)
+			if err != nil {
+				return nil, err
+			}
+
+			history = append(history, e)
+		}
+	}
+
+	if len(history) == 0 {
+		return nil, store.ErrNotFound
+	}
+
+	return history, nil
+}
+
+// Delete removes the event with the given `id`, along with its history.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	paginator := dynamodb.NewQueryPaginator(s.client, &dynamodb.QueryInput{
+		TableName:              aws.String(s.table),
+		ConsistentRead:         aws.Bool(true),
+		KeyConditionExpression: aws.String("#id = :id"),
+		ProjectionExpression:   aws.String("#id, #sort"),
+		ExpressionAttributeNames: map[string]string{
+			"#id":   "id",
+			"#sort": "sort",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id": &types.AttributeValueMemberS{Value: id},
+		},
+	})
+
+	keys := []map[string]types.AttributeValue{}
+
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to find the event: %w", err)
+		}
+
+		keys = append(keys, output.Items...)
+	}
+
+	if len(keys) == 0 {
+		return store.ErrNotFound
+	}
+
+	for start := 0; start < len(keys); start += batchSize {
+		end := min(start+batchSize, len(keys))
+
+		requests := make([]types.WriteRequest, 0, end-start)
+		for _, key := range keys[start:end] {
+			requests = append(requests, types.WriteRequest{
+				DeleteRequest: &types.DeleteRequest{Key: key},
+			})
+		}
+
+		if err := s.write(ctx, requests); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// write submits the batch of `requests`, resubmitting any which were not
+// processed with an exponential backoff until they are, or `ctx` is done.
+func (s *Store) write(ctx context.Context, requests []types.WriteRequest) error {
+	pending := map[string][]types.WriteRequest{s.table: requests}
+	backoff := minBackoff
+
+	for {
+		output, err := s.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: pending,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to write the batch: %w", err)
+		}
+
+		pending = output.UnprocessedItems
+		if len(pending[s.table]) == 0 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("unable to write the batch: %w", ctx.Err())
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, maxBackoff) //nolint:mnd // ignore
+	}
+
+	return nil
+}
+
+// Expire removes all the events, along with their history, which have not been
+// updated since `before`, returning the number of events removed.
+func (s *Store) Expire(ctx context.Context, before time.Time) (int, error) {
+	paginator := dynamodb.NewQueryPaginator(s.client, &dynamodb.QueryInput{
+		TableName:              aws.String(s.table),
+		IndexName:              aws.String(eventsIndex),
+		KeyConditionExpression: aws.String("#kind = :kind"),
+		FilterExpression:       aws.String("#timestamp < :before"),
+		ExpressionAttributeNames: map[string]string{
+			"#kind":      "kind",
+			"#timestamp": "timestamp",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":kind":   &types.AttributeValueMemberS{Value: eventKind},
+			":before": &types.AttributeValueMemberN{Value: fmt.Sprintf("%d", before.UnixNano())},
+		},
+	})
+
+	ids := []string{}
+
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("unable to find expired events: %w", err)
+		}
+
+		for _, i := range output.Items {
+			if id, ok := i["id"].(*types.AttributeValueMemberS); ok {
+				ids = append(ids, id.Value)
+			}
+		}
+	}
+
+	count := 0
+
+	for _, id := range ids {
+		err := s.Delete(ctx, id)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return count, err
+		}
+
+		count++
+	}
+
+	return count, nil
+}
+
+// Close releases any resources held by the store.
+func (s *Store) Close() error {
+	return nil
+}
+
+// toItem converts the event `e` into the item stored in the table under the
+// sort key `sort`, setting the attributes used by the indexes only on the
+// current state of the event.
+func (s *Store) toItem(e *event.Event, sort string) *item {
+	i := &item{
+		ID:        e.ID,
+		Sort:      sort,
+		Status:    e.Status,
+		Message:   e.Message,
+		Source:    e.Source,
+		Group:     e.Group,
+		Labels:    e.Labels,
+		Timestamp: e.Timestamp.UnixNano(),
+		Received:  e.Received.UnixNano(),
+		Reopen:    e.Reopen,
+		Duration:  e.Duration,
+		Heartbeat: e.Heartbeat,
+		TTL:       e.TTL,
+	}
+
+	if e.Started != nil {
+		i.Started = e.Started.UnixNano()
+	}
+
+	if sort == currentKey {
+		i.Kind = eventKind
+		i.Index = e.Group
+	}
+
+	if s.ttl > 0 {
+		i.Expires = e.Received.Add(s.ttl).Unix()
+	}
+
+	return i
+}
+
+// fromItem converts the attributes of an item from the table back into an
+// event.
+func fromItem(attributes map[string]types.AttributeValue) (*event.Event, error) {
+	i := &item{}
+	if err := attributevalue.UnmarshalMap(attributes, i); err != nil {
+		return nil, fmt.Errorf("unable to decode the event: %w", err)
+	}
+
+	e := &event.Event{
+		ID:        i.ID,
+		Status:    i.Status,
+		Message:   i.Message,
+		Source:    i.Source,
+		Group:     i.Group,
+		Labels:    i.Labels,
+		Timestamp: time.Unix(0, i.Timestamp).UTC(),
+		Received:  time.Unix(0, i.Received).UTC(),
+		Reopen:    i.Reopen,
+		Duration:  i.Duration,
+		Heartbeat: i.Heartbeat,
+		TTL:       i.TTL,
+	}
+
+	if i.Started != 0 {
+		started := time.Unix(0, i.Started).UTC()
+		e.Started = &started
+	}
+
+	return e, nil
+}
+
+// historyKey returns the sort key for the update `e` in the history of the
+// event, ordered by the time of the update, and then by its digest, so that
+// the same update submitted more than once replaces the same item.
+func historyKey(e *event.Event) string {
+	return fmt.Sprintf("%s%020d#%s", historyPrefix, e.Timestamp.UnixNano(), e.Digest()[:digestLength])
+}
+
+// encodeCursor converts the last key evaluated by a query into an opaque
+// cursor which can be returned to the client.
+func encodeCursor(key map[string]types.AttributeValue) (string, error) {
+	values := map[string]string{}
+
+	for name, value := range key {
+		if s, ok := value.(*types.AttributeValueMemberS); ok {
+			values[name] = s.Value
+		}
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("unable to encode the cursor: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor converts an opaque cursor back into the key from which the next
+// query should start.
+func decodeCursor(cursor string) (map[string]types.AttributeValue, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, store.ErrInvalidCursor
+	}
+
+	values := map[string]string{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, store.ErrInvalidCursor
+	}
+
+	key := make(map[string]types.AttributeValue, len(values))
+	for name, value := range values {
+		key[name] = &types.AttributeValueMemberS{Value: value}
+	}
+
+	return key, nil
+}
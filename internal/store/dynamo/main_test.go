@@ -0,0 +1,51 @@
+//nolint:paralleltest // these tests cannot operate in parallel
+package dynamo_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/store"
+	"github.com/n3tuk/dashboard/internal/store/dynamo"
+	"github.com/n3tuk/dashboard/internal/store/storetest"
+)
+
+// endpointVariable is the name of the environment variable which must be set
+// to the endpoint of DynamoDB Local (such as from docker-compose) for these
+// tests to run, as they cannot be run without a DynamoDB service.
+const endpointVariable = "DASHBOARD_TEST_DYNAMODB_ENDPOINT"
+
+// TestDynamo runs the conformance tests against the DynamoDB event store, using
+// a new table for each test.
+func TestDynamo(t *testing.T) {
+	endpoint, ok := os.LookupEnv(endpointVariable)
+	if !ok {
+		t.Skipf("%s is not set", endpointVariable)
+	}
+
+	// DynamoDB Local accepts any credentials, but they must be provided
+	t.Setenv("AWS_ACCESS_KEY_ID", "dashboard")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "dashboard")
+	t.Setenv("AWS_REGION", "eu-west-2")
+
+	storetest.Run(t, func(t *testing.T) store.EventStore {
+		t.Helper()
+
+		viper.Reset()
+		viper.Set("store.dynamodb.endpoint", endpoint)
+		viper.Set("store.dynamodb.table", fmt.Sprintf("dashboard-test-%d", time.Now().UnixNano()))
+		viper.Set("store.dynamodb.create", true)
+		viper.Set("store.dynamodb.ttl", 3600)
+
+		s, err := dynamo.New(context.Background())
+		require.NoError(t, err)
+
+		return s
+	})
+}
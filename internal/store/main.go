@@ -0,0 +1,214 @@
+// The `store` package provides the interface for persisting events, and the
+// history of those events, for the dashboard web service, along with the
+// registry of the drivers which implement it, allowing the backend to be
+// selected through the `store.driver` configuration setting.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/n3tuk/dashboard/internal/event"
+)
+
+const (
+	// DefaultLimit is the number of events returned in a single page of results
+	// when no limit is requested.
+	DefaultLimit = 100
+	// MaxLimit is the maximum number of events which can be returned in a single
+	// page of results.
+	MaxLimit = 1000
+)
+
+var (
+	// ErrNotFound is returned when the requested event does not exist.
+	ErrNotFound = errors.New("event not found")
+	// ErrUnknownDriver is returned when the configured driver does not exist.
+	ErrUnknownDriver = errors.New("unknown store driver")
+	// ErrInvalidCursor is returned when the pagination cursor cannot be used.
+	ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+	// drivers holds the registered drivers which can be used to create a new
+	// `EventStore`, keyed by the name used in the `store.driver` setting.
+	drivers = map[string]Driver{}
+	// lock protects access to `drivers`.
+	lock sync.RWMutex
+)
+
+// EventStore is the interface which all backends used to persist events must
+// implement. All implementations must be safe for concurrent use, and must
+// pass the conformance tests in the `storetest` package.
+type EventStore interface {
+	// Put saves the event `e`, recording it in the history for the event, and
+	// replacing the current state of the event only if the `Timestamp` is not
+	// older than the one currently stored, so that updates which arrive out of
+	// order do not overwrite newer updates. An update which is the same as one
+	// already in the history (ignoring the time it was received) must not be
+	// recorded in the history again.
+	Put(ctx context.Context, e *event.Event) error
+	// Get returns the current state of the event with the given `id`, or
+	// `ErrNotFound` if it does not exist.
+	Get(ctx context.Context, id string) (*event.Event, error)
+	// List returns a page of the current state of the events in the `group`
+	// (or all events if `group` is empty), ordered by their ID.
+	List(ctx context.Context, group string, page Page) (*Result, error)
+	// History returns all the updates recorded for the event with the given
+	// `id`, ordered from the oldest to the newest, or `ErrNotFound` if it does
+	// not exist.
+	History(ctx context.Context, id string) ([]*event.Event, error)
+	// Delete removes the event with the given `id`, along with its history, or
+	// returns `ErrNotFound` if it does not exist.
+	Delete(ctx context.Context, id string) error
+	// Expire removes all the events, along with their history, which have not
+	// been updated since `before`, returning the number of events removed.
+	Expire(ctx context.Context, before time.Time) (int, error)
+	// Ready checks that the store can be reached and is ready to be used,
+	// returning an error describing the problem if not.
+	Ready(ctx context.Context) error
+	// Close releases any resources held by the store.
+	Close() error
+}
+
+// Page describes which page of results should be returned from `List`.
+type Page struct {
+	// Limit is the maximum number of events to return, defaulting to
+	// `DefaultLimit` if zero, and capped at `MaxLimit`.
+	Limit int
+	// Cursor is the opaque value returned in `Result.Next` from the previous
+	// page of results, or empty for the first page.
+	Cursor string
+}
+
+// Result holds a single page of events returned from `List`.
+type Result struct {
+	// Events is the list of events in this page of results.
+	Events []*event.Event
+	// Next is the cursor to request the next page of results, or empty if there
+	// are no more results.
+	Next string
+}
+
+// Driver creates a new `EventStore` from the current configuration.
+type Driver func(ctx context.Context) (EventStore, error)
+
+// Register makes the driver available for selection through the `store.driver`
+// configuration setting under the given `name`. It is expected to be called
+// from the `init()` function of the package providing the driver.
+func Register(name string, driver Driver) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	drivers[name] = driver
+}
+
+// Drivers returns the sorted names of all the registered drivers.
+func Drivers() []string {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// New creates the `EventStore` using the driver configured in the
+// `store.driver` setting, returning `ErrUnknownDriver` if it does not exist.
+func New(ctx context.Context) (EventStore, error) {
+	name := viper.GetString("store.driver")
+
+	lock.RLock()
+	driver, ok := drivers[name]
+	lock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDriver, name)
+	}
+
+	return driver(ctx)
+}
+
+// Size returns the number of events which should be returned for the page,
+// applying the default and maximum limits to the requested `Limit`.
+func (p Page) Size() int {
+	switch {
+	case p.Limit <= 0:
+		return DefaultLimit
+	case p.Limit > MaxLimit:
+		return MaxLimit
+	default:
+		return p.Limit
+	}
+}
+
+// All returns the current state of all the events in the `group` (or all events
+// if `group` is empty) from the store `s`, reading every page of the results.
+func All(ctx context.Context, s EventStore, group string) ([]*event.Event, error) {
+	var events []*event.Event
+
+	page := Page{Limit: MaxLimit}
+
+	for {
+		result, err := s.List(ctx, group, page)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, result.Events...)
+
+		if result.Next == "" {
+			return events, nil
+		}
+
+		page.Cursor = result.Next
+	}
+}
+
+// Watch checks the readiness of the store `s` every `interval` until `ctx` is
+// cancelled, reporting the result of each check to `health`, and logging each
+// time the store changes between being ready and not.
+func Watch(ctx context.Context, s EventStore, interval time.Duration, health func(bool)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	ready := true
+
+	for {
+		check, cancel := context.WithTimeout(ctx, interval)
+		err := s.Ready(check)
+
+		cancel()
+
+		switch {
+		case err != nil && ready:
+			slog.Error(
+				"Event store is not ready",
+				slog.Group("error",
+					slog.String("message", err.Error()),
+				),
+			)
+		case err == nil && !ready:
+			slog.Info("Event store is ready")
+		}
+
+		ready = err == nil
+		health(ready)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/n3tuk/dashboard/internal/event"
+)
+
+// MemoryDriver is the name of the driver for the in-memory event store.
+const MemoryDriver = "memory"
+
+// Memory provides a thread-safe, in-memory implementation of `EventStore`,
+// which is suitable for running a single dashboard instance, or for testing,
+// but does not persist events between restarts, nor share them between
+// instances.
+type Memory struct {
+	mutex  sync.RWMutex
+	events map[string]*record
+}
+
+// record holds the current state and the history of a single event, along
+// with the set of updates already in the history, keyed by their timestamp and
+// digest, so that duplicates can be found without rehashing the history.
+type record struct {
+	current *event.Event
+	history []*event.Event
+	seen    map[string]struct{}
+}
+
+// init will register the in-memory driver as the default store driver.
+func init() {
+	Register(MemoryDriver, func(_ context.Context) (EventStore, error) {
+		return NewMemory(), nil
+	})
+}
+
+// NewMemory creates a new, empty, in-memory event store.
+func NewMemory() *Memory {
+	return &Memory{
+		events: map[string]*record{},
+	}
+}
+
+// Put saves the event `e`, recording it in the history for the event, and
+// replacing the current state of the event only if it is not older than the
+// one currently stored. Updates which are already in the history are ignored.
+func (m *Memory) Put(_ context.Context, e *event.Event) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	r, ok := m.events[e.ID]
+	if !ok {
+		r = &record{seen: map[string]struct{}{}}
+		m.events[e.ID] = r
+	}
+
+	key := e.Timestamp.UTC().Format(time.RFC3339Nano) + "+" + e.Digest()
+	if _, ok := r.seen[key]; ok {
+		return nil
+	}
+
+	r.seen[key] = struct{}{}
+	r.history = append(r.history, e.Clone())
+	sort.SliceStable(r.history, func(i, j int) bool {
+		return r.history[i].Timestamp.Before(r.history[j].Timestamp)
+	})
+
+	if r.current == nil || !e.Timestamp.Before(r.current.Timestamp) {
+		r.current = e.Clone()
+	}
+
+	return nil
+}
+
+// Get returns the current state of the event with the given `id`.
+func (m *Memory) Get(_ context.Context, id string) (*event.Event, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	r, ok := m.events[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return r.current.Clone(), nil
+}
+
+// List returns a page of the current state of the events in the `group`, or
+// all events if `group` is empty, ordered by their ID.
+func (m *Memory) List(_ context.Context, group string, page Page) (*Result, error) {
+	after := ""
+
+	if page.Cursor != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(page.Cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+
+		after = string(decoded)
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	ids := make([]string, 0, len(m.events))
+
+	for id, r := range m.events {
+		if after != "" && id <= after {
+			continue
+		}
+
+		if group != "" && r.current.Group != group {
+			continue
+		}
+
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	size := page.Size()
+	result := &Result{}
+
+	if len(ids) > size {
+		ids = ids[:size]
+		result.Next = base64.RawURLEncoding.EncodeToString([]byte(ids[size-1]))
+	}
+
+	result.Events = make([]*event.Event, 0, len(ids))
+	for _, id := range ids {
+		result.Events = append(result.Events, m.events[id].current.Clone())
+	}
+
+	return result, nil
+}
+
+// History returns all the updates recorded for the event with the given `id`,
+// ordered from the oldest to the newest.
+func (m *Memory) History(_ context.Context, id string) ([]*event.Event, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	r, ok := m.events[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	history := make([]*event.Event, 0, len(r.history))
+	for _, e := range r.history {
+		history = append(history, e.Clone())
+	}
+
+	return history, nil
+}
+
+// Delete removes the event with the given `id`, along with its history.
+func (m *Memory) Delete(_ context.Context, id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.events[id]; !ok {
+		return ErrNotFound
+	}
+
+	delete(m.events, id)
+
+	return nil
+}
+
+// Expire removes all the events, along with their history, which have not been
+// updated since `before`, returning the number of events removed.
+func (m *Memory) Expire(_ context.Context, before time.Time) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	count := 0
+
+	for id, r := range m.events {
+		if r.current.Timestamp.Before(before) {
+			delete(m.events, id)
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// Ready always reports that the in-memory store is ready.
+func (m *Memory) Ready(_ context.Context) error {
+	return nil
+}
+
+// Close releases the events held in memory.
+func (m *Memory) Close() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.events = map[string]*record{}
+
+	return nil
+}
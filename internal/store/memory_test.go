@@ -0,0 +1,46 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/store"
+	"github.com/n3tuk/dashboard/internal/store/storetest"
+)
+
+// TestMemory runs the conformance tests against the in-memory event store.
+func TestMemory(t *testing.T) {
+	t.Parallel()
+
+	storetest.Run(t, func(_ *testing.T) store.EventStore {
+		return store.NewMemory()
+	})
+}
+
+// TestAll tests that all the events are returned from every page of results.
+func TestAll(t *testing.T) {
+	t.Parallel()
+
+	s := store.NewMemory()
+
+	for i := range store.MaxLimit + 5 {
+		require.NoError(t, s.Put(context.Background(), &event.Event{
+			ID:     fmt.Sprintf("event-%04d", i),
+			Status: event.StatusPass,
+			Group:  "service",
+		}))
+	}
+
+	events, err := store.All(context.Background(), s, "service")
+	require.NoError(t, err)
+	assert.Len(t, events, store.MaxLimit+5)
+
+	events, err = store.All(context.Background(), s, "other")
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
@@ -0,0 +1,306 @@
+// The `storetest` package provides the conformance tests which every
+// implementation of `store.EventStore` must pass, ensuring that all the
+// backends behave the same way regardless of how they persist the events.
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/n3tuk/dashboard/internal/event"
+	"github.com/n3tuk/dashboard/internal/store"
+)
+
+// Factory creates a new, empty, `EventStore` for each of the conformance
+// tests to be run against.
+type Factory func(t *testing.T) store.EventStore
+
+// base is the time used as the starting point for all the events created in
+// the conformance tests.
+var base = time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+// Run runs all the conformance tests against new stores created by `factory`.
+func Run(t *testing.T, factory Factory) {
+	t.Helper()
+
+	tests := map[string]func(*testing.T, store.EventStore){
+		"Ready":       testReady,
+		"PutAndGet":   testPutAndGet,
+		"GetMissing":  testGetMissing,
+		"OutOfOrder":  testOutOfOrder,
+		"History":     testHistory,
+		"Duplicate":   testDuplicate,
+		"List":        testList,
+		"ListGroup":   testListGroup,
+		"ListCursor":  testListCursor,
+		"Delete":      testDelete,
+		"Expire":      testExpire,
+		"Isolation":   testIsolation,
+		"Concurrency": testConcurrency,
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := factory(t)
+			t.Cleanup(func() { _ = s.Close() })
+
+			test(t, s)
+		})
+	}
+}
+
+// newEvent creates a new event with the given `id`, `status`, and `group`,
+// with the timestamp set `offset` seconds after the base time.
+func newEvent(id, status, group string, offset int) *event.Event {
+	timestamp := base.Add(time.Duration(offset) * time.Second)
+
+	return &event.Event{
+		ID:        id,
+		Status:    status,
+		Group:     group,
+		Labels:    map[string]string{"test": "conformance"},
+		Timestamp: timestamp,
+		Received:  timestamp,
+	}
+}
+
+// testReady checks that the store reports it is ready to be used.
+func testReady(t *testing.T, s store.EventStore) {
+	require.NoError(t, s.Ready(context.Background()))
+}
+
+// testPutAndGet checks that an event can be stored and retrieved.
+func testPutAndGet(t *testing.T, s store.EventStore) {
+	ctx := context.Background()
+
+	require.NoError(t, s.Put(ctx, newEvent("put", "running", "", 0)))
+
+	e, err := s.Get(ctx, "put")
+	require.NoError(t, err)
+
+	assert.Equal(t, "put", e.ID)
+	assert.Equal(t, "running", e.Status)
+	assert.Equal(t, "conformance", e.Labels["test"])
+	assert.True(t, base.Equal(e.Timestamp))
+}
+
+// testGetMissing checks that a missing event returns `store.ErrNotFound`.
+func testGetMissing(t *testing.T, s store.EventStore) {
+	ctx := context.Background()
+
+	_, err := s.Get(ctx, "missing")
+	require.ErrorIs(t, err, store.ErrNotFound)
+
+	_, err = s.History(ctx, "missing")
+	require.ErrorIs(t, err, store.ErrNotFound)
+
+	require.ErrorIs(t, s.Delete(ctx, "missing"), store.ErrNotFound)
+}
+
+// testOutOfOrder checks that an older update does not replace a newer one.
+func testOutOfOrder(t *testing.T, s store.EventStore) {
+	ctx := context.Background()
+
+	require.NoError(t, s.Put(ctx, newEvent("order", "pass", "", 10)))
+	require.NoError(t, s.Put(ctx, newEvent("order", "running", "", 5)))
+
+	e, err := s.Get(ctx, "order")
+	require.NoError(t, err)
+	assert.Equal(t, "pass", e.Status)
+
+	history, err := s.History(ctx, "order")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, "running", history[0].Status)
+	assert.Equal(t, "pass", history[1].Status)
+}
+
+// testHistory checks that every update is recorded, oldest first.
+func testHistory(t *testing.T, s store.EventStore) {
+	ctx := context.Background()
+
+	for i, status := range []string{"queued", "running", "pass"} {
+		require.NoError(t, s.Put(ctx, newEvent("history", status, "", i)))
+	}
+
+	history, err := s.History(ctx, "history")
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+
+	assert.Equal(t, "queued", history[0].Status)
+	assert.Equal(t, "running", history[1].Status)
+	assert.Equal(t, "pass", history[2].Status)
+}
+
+// testDuplicate checks that the same update submitted more than once is only
+// recorded once in the history, even when received at different times.
+func testDuplicate(t *testing.T, s store.EventStore) {
+	ctx := context.Background()
+
+	for i := range 3 {
+		e := newEvent("duplicate", "running", "", 0)
+		e.Received = e.Received.Add(time.Duration(i) * time.Second)
+
+		require.NoError(t, s.Put(ctx, e))
+	}
+
+	// The same time, but a different update, is still recorded
+	require.NoError(t, s.Put(ctx, newEvent("duplicate", "pass", "", 0)))
+
+	history, err := s.History(ctx, "duplicate")
+	require.NoError(t, err)
+	assert.Len(t, history, 2)
+}
+
+// testList checks that all events are listed in order of their ID.
+func testList(t *testing.T, s store.EventStore) {
+	ctx := context.Background()
+
+	for _, id := range []string{"c", "a", "b"} {
+		require.NoError(t, s.Put(ctx, newEvent(id, "pass", "", 0)))
+	}
+
+	result, err := s.List(ctx, "", store.Page{})
+	require.NoError(t, err)
+	require.Len(t, result.Events, 3)
+	assert.Empty(t, result.Next)
+
+	assert.Equal(t, "a", result.Events[0].ID)
+	assert.Equal(t, "b", result.Events[1].ID)
+	assert.Equal(t, "c", result.Events[2].ID)
+}
+
+// testListGroup checks that only the events in the group are listed.
+func testListGroup(t *testing.T, s store.EventStore) {
+	ctx := context.Background()
+
+	require.NoError(t, s.Put(ctx, newEvent("one", "pass", "service/production", 0)))
+	require.NoError(t, s.Put(ctx, newEvent("two", "fail", "service/development", 0)))
+	require.NoError(t, s.Put(ctx, newEvent("three", "pass", "service/production", 0)))
+
+	result, err := s.List(ctx, "service/production", store.Page{})
+	require.NoError(t, err)
+	require.Len(t, result.Events, 2)
+
+	assert.Equal(t, "one", result.Events[0].ID)
+	assert.Equal(t, "three", result.Events[1].ID)
+}
+
+// testListCursor checks that the events can be paged through with the cursor
+// without any being missed or repeated.
+func testListCursor(t *testing.T, s store.EventStore) {
+	ctx := context.Background()
+
+	for i := range 7 {
+		require.NoError(t, s.Put(ctx, newEvent(fmt.Sprintf("page-%02d", i), "pass", "", i)))
+	}
+
+	seen := []string{}
+	page := store.Page{Limit: 3}
+
+	for range 10 {
+		result, err := s.List(ctx, "", page)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(result.Events), 3)
+
+		for _, e := range result.Events {
+			seen = append(seen, e.ID)
+		}
+
+		if result.Next == "" {
+			break
+		}
+
+		page.Cursor = result.Next
+	}
+
+	require.Len(t, seen, 7)
+
+	for i, id := range seen {
+		assert.Equal(t, fmt.Sprintf("page-%02d", i), id)
+	}
+}
+
+// testDelete checks that deleting an event removes it and its history.
+func testDelete(t *testing.T, s store.EventStore) {
+	ctx := context.Background()
+
+	require.NoError(t, s.Put(ctx, newEvent("delete", "pass", "", 0)))
+	require.NoError(t, s.Delete(ctx, "delete"))
+
+	_, err := s.Get(ctx, "delete")
+	require.ErrorIs(t, err, store.ErrNotFound)
+
+	_, err = s.History(ctx, "delete")
+	require.ErrorIs(t, err, store.ErrNotFound)
+}
+
+// testExpire checks that only events not updated since the cut-off are removed.
+func testExpire(t *testing.T, s store.EventStore) {
+	ctx := context.Background()
+
+	require.NoError(t, s.Put(ctx, newEvent("old", "pass", "", 0)))
+	require.NoError(t, s.Put(ctx, newEvent("new", "pass", "", 60)))
+
+	count, err := s.Expire(ctx, base.Add(30*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	_, err = s.Get(ctx, "old")
+	require.ErrorIs(t, err, store.ErrNotFound)
+
+	_, err = s.Get(ctx, "new")
+	require.NoError(t, err)
+}
+
+// testIsolation checks that changes to events after they have been stored, or
+// returned, do not change the events held in the store.
+func testIsolation(t *testing.T, s store.EventStore) {
+	ctx := context.Background()
+
+	e := newEvent("isolation", "pass", "", 0)
+	require.NoError(t, s.Put(ctx, e))
+
+	e.Status = "fail"
+	e.Labels["test"] = "changed"
+
+	stored, err := s.Get(ctx, "isolation")
+	require.NoError(t, err)
+	assert.Equal(t, "pass", stored.Status)
+	assert.Equal(t, "conformance", stored.Labels["test"])
+
+	stored.Labels["test"] = "changed"
+
+	stored, err = s.Get(ctx, "isolation")
+	require.NoError(t, err)
+	assert.Equal(t, "conformance", stored.Labels["test"])
+}
+
+// testConcurrency checks that the store can be safely updated concurrently.
+func testConcurrency(t *testing.T, s store.EventStore) {
+	ctx := context.Background()
+	done := make(chan error)
+
+	for i := range 10 {
+		go func(i int) {
+			done <- s.Put(ctx, newEvent("concurrent", "running", "", i))
+		}(i)
+	}
+
+	for range 10 {
+		require.NoError(t, <-done)
+	}
+
+	e, err := s.Get(ctx, "concurrent")
+	require.NoError(t, err)
+	assert.True(t, base.Add(9*time.Second).Equal(e.Timestamp))
+
+	history, err := s.History(ctx, "concurrent")
+	require.NoError(t, err)
+	assert.Len(t, history, 10)
+}
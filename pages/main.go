@@ -0,0 +1,21 @@
+// The `pages` package provides the assets shared between the documentation
+// site and the dashboard web service, such as the logo and the stylesheet, so
+// that both have the same look without needing to keep copies in sync.
+package pages
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed docs/assets/logo.svg docs/assets/custom.css
+var assets embed.FS
+
+// Assets returns the file system holding the shared assets, with the files at
+// the root of the file system (such as `logo.svg`).
+func Assets() fs.FS {
+	// The path is embedded above, so this cannot fail
+	sub, _ := fs.Sub(assets, "docs/assets")
+
+	return sub
+}
@@ -0,0 +1,22 @@
+// The `schemas` package provides the JSON schemas for the configuration files
+// used by the dashboard application, and for the events sent to it, embedded
+// into the application so that the configuration and the event files can be
+// validated against them when they are loaded.
+package schemas
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// BaseURL is the URL from which the `$id` of each of the schemas is built.
+const BaseURL = "https://github.com/n3tuk/dashboard/blob/main/schemas/"
+
+//go:embed *.json
+var files embed.FS
+
+// Files returns the file system holding all the schemas, with each schema at
+// the root of the file system (such as `serve.json`).
+func Files() fs.FS {
+	return files
+}